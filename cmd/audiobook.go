@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/manifest"
+)
+
+// audiobookTrack はトラックごとの変換計画から、順に連結して1ファイルにまとめる変換計画を作成します。
+// チャプター名は各トラックのタイトル（トラック一覧と対応付けられなかったファイルはファイル名）で、
+// ファイル全体のタイトルは作品名です。出力ファイル名は name に拡張子を付けたものです。
+func audiobookTrack(plan *albumPlan, targetDir, name string, metadata audioconverter.MP3Metadata, format audioconverter.Format) trackPlan {
+	chapters := make([]audioconverter.Chapter, 0, len(plan.Tracks))
+	records := make([]manifest.Chapter, 0, len(plan.Tracks))
+	sampleRate, known := 0, true
+	for _, track := range plan.Tracks {
+		chapter := audioconverter.Chapter{InputFile: track.InputFile, Title: track.Metadata.TrackName}
+		if track.Source != nil {
+			chapter.Duration = time.Duration(track.Source.Duration * float64(time.Second))
+			sampleRate = max(sampleRate, track.Source.SampleRate)
+		} else {
+			known = false
+		}
+		chapters = append(chapters, chapter)
+		records = append(records, manifest.Chapter{Title: chapter.Title, Source: track.Record.Source})
+	}
+	// 最もサンプリングレートが高い変換元に合わせる（不明な変換元がある場合は設定のまま）
+	if known {
+		format = format.ForSource(sampleRate)
+	}
+
+	metadata.TrackName = metadata.AlbumTitle
+	output := name + format.Extension
+	first := plan.Tracks[0].Record
+	return trackPlan{
+		InputFile:  targetDir,
+		OutputFile: filepath.Join(plan.OutputDir, output),
+		Metadata:   metadata,
+		Format:     format,
+		Chapters:   chapters,
+		Record: manifest.Track{
+			Output:       output,
+			Cover:        first.Cover,
+			CoverOptions: first.CoverOptions,
+			Metadata:     metadata.TagMap(),
+			Encoder:      format.Signature(),
+			Chapters:     records,
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/manifest"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestConvertFilesAudiobook(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Audiobook = config.AudiobookM4B
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two", "おまけ/01.wav": "bonus"})
+	value := model.IndividualData{
+		AlbumTitle: "テスト作品",
+		Sources: []model.AudioSource{
+			{File: "01.wav", SampleRate: 44100, Duration: 60},
+			{File: "02.wav", SampleRate: 44100, Duration: 30.5},
+			{File: "おまけ/01.wav", SampleRate: 44100, Duration: 10},
+		},
+		TrackMatches: []model.TrackMatch{{File: "01.wav", Track: 1, Title: "プロローグ"}},
+	}
+
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if len(plan.Tracks) != 1 {
+		t.Fatalf("オーディオブックは1ファイルにまとめるべき: got %d", len(plan.Tracks))
+	}
+	track := plan.Tracks[0]
+	if track.Record.Output != "テスト作品.m4b" || track.Metadata.TrackName != "テスト作品" {
+		t.Errorf("出力ファイル名とタイトルは作品名にすべき: %s, %s", track.Record.Output, track.Metadata.TrackName)
+	}
+	var titles []string
+	for _, chapter := range track.Chapters {
+		titles = append(titles, chapter.Title)
+	}
+	if got := strings.Join(titles, ","); got != "プロローグ,02,01" {
+		t.Errorf("チャプター名はトラックタイトルまたはファイル名にすべき: %s", got)
+	}
+	if track.Chapters[1].Duration.Seconds() != 30.5 {
+		t.Errorf("チャプターの長さには調べた再生時間を使用すべき: %v", track.Chapters[1].Duration)
+	}
+
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	if n := countCalls(t, callLog); n != 1 {
+		t.Fatalf("ffmpegの呼び出し回数: got %d, want 1", n)
+	}
+	content, _ := os.ReadFile(callLog)
+	if !strings.Contains(string(content), "concat=n=3:v=0:a=1") || !strings.Contains(string(content), "-ar 44100") {
+		t.Errorf("3ファイルを連結し、変換元のサンプリングレートでエンコードすべき: %s", content)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "テスト作品.m4b")); err != nil {
+		t.Errorf("オーディオブックが出力されていません: %v", err)
+	}
+	record, err := manifest.Load(plan.OutputDir)
+	if err != nil || record == nil || len(record.Tracks) != 1 || len(record.Tracks[0].Chapters) != 3 {
+		t.Fatalf("チャプターごとの変換元を記録すべき: %+v, %v", record, err)
+	}
+
+	// 変更がなければ再エンコードしない
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if n := countCalls(t, callLog); n != 1 {
+		t.Errorf("変更がない場合は再エンコードしない: got %d calls", n)
+	}
+
+	// チャプター名が変わった場合はまとめて再エンコードする
+	value.TrackMatches[0].Title = "序章"
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if n := countCalls(t, callLog); n != 2 {
+		t.Errorf("チャプター名が変わった場合は再エンコードすべき: got %d calls", n)
+	}
+}
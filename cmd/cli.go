@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/generator"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/storage"
+)
+
+// subcommand はサブコマンドの定義です。
+type subcommand struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// subcommands は利用可能なサブコマンドの一覧を表示順に返します。
+func subcommands() []subcommand {
+	return []subcommand{
+		{"encode", "encode [フラグ] [作品キー...]", "HTMLを解析して音声ファイルを変換します（サブコマンド省略時の既定）", runEncodeCommand},
+		{"parse", "parse [フラグ] [作品キー...]", "HTMLを解析して結果をJSONに保存します（変換は行いません）", runParseCommand},
+		{"watch", "watch [フラグ] [作品キー...]", "source_dir などを監視し、追加された作品を自動で変換します", runWatchCommand},
+		{"create-html", "create-html [フラグ]", "HTMLファイルを対話形式で作成します", runCreateHTMLCommand},
+		{"inspect", "inspect [フラグ] <作品キー>", "1作品の解析結果と変換計画を表示します", runInspectCommand},
+		{"verify", "verify [フラグ] [作品キー...]", "出力アルバムが変換元・メタデータと一致しているか検証します", runVerifyCommand},
+		{"retag", "retag [フラグ] [作品キー...]", "HTMLを解析し直し、出力済みのMP3のタグを再エンコードせずに書き換えます", runRetagCommand},
+		{"config", "config check [フラグ]", "設定ファイルを検証して有効な設定値を表示します", runConfigCommand},
+	}
+}
+
+// runCLI はコマンドライン引数を解釈してサブコマンドを実行し、終了コードを返します。
+// サブコマンドを省略した場合や、サブコマンドの代わりに作品キーを指定した場合は encode を実行します。
+// 従来の -create-html フラグも create-html サブコマンドとして受け付けます。
+func runCLI(ctx context.Context, args []string) int {
+	name := "encode"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+		// サブコマンドを導入する前の、作品キーだけを指定する形式は encode として実行する
+		if workKeyPattern.MatchString(name) {
+			fmt.Fprintf(os.Stderr, "サブコマンドが指定されていないため encode として実行します（今後は `dls-encoder encode %s` を使用してください）\n", name)
+			// 作品キーの後に続くフラグも解釈できるよう、作品キーは引数の最後に移す
+			name, args = "encode", append(args, name)
+		}
+	} else if legacyArgs, ok := stripLegacyCreateHTML(args); ok {
+		name, args = "create-html", legacyArgs
+	}
+
+	switch name {
+	case "help", "-h", "--help":
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	for _, cmd := range subcommands() {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(ctx, args)
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		if err != nil {
+			fmt.Printf("エラー: %v\n", err)
+		}
+		// 一部の作品のみ失敗した場合は、処理全体の失敗と区別できる終了コードで終了する
+		return exitCode(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "不明なサブコマンドです: %s\n", name)
+	fmt.Fprintf(os.Stderr, "作品キーを指定して変換する場合は `dls-encoder encode <作品キー>` を使用してください。\n\n")
+	printUsage(os.Stderr)
+	return exitFatal
+}
+
+// workKeyPattern はサブコマンドの代わりに指定された作品キー（RJ01234567、d_123456 など）に一致するパターンです。
+var workKeyPattern = regexp.MustCompile(`^(?:[A-Za-z]{2}\d{6,}|d_\d+)$`)
+
+// stripLegacyCreateHTML は従来の -create-html フラグを取り除いた引数を返します。
+func stripLegacyCreateHTML(args []string) ([]string, bool) {
+	found := false
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "-create-html" || arg == "--create-html" || arg == "-create-html=true" || arg == "--create-html=true" {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, found
+}
+
+// printUsage はサブコマンドの一覧を表示します。
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "使い方: dls-encoder <サブコマンド> [フラグ] [引数]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "サブコマンド:")
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(w, "  %-34s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "各サブコマンドのフラグは `dls-encoder <サブコマンド> -h` で確認できます。")
+}
+
+// commonFlags は全サブコマンドで共通のフラグです。
+type commonFlags struct {
+	configPath string
+	profile    string
+	debug      bool
+}
+
+// newFlagSet はサブコマンド用のフラグセットを作成し、共通フラグを登録します。
+func newFlagSet(cmd string, common *commonFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.StringVar(&common.configPath, "config", "", "設定ファイルのパス（省略時は ./config/config.toml、~/.config/dls-encoder/config.toml の順に検索）")
+	fs.StringVar(&common.profile, "profile", "", "適用する [profile.<名前>] セクション（環境変数 DLS_ENCODER_PROFILE でも指定可）")
+	fs.BoolVar(&common.debug, "debug", false, "デバッグログを出力します（設定ファイルの debug より優先）")
+	fs.Usage = func() {
+		for _, c := range subcommands() {
+			if c.name == cmd {
+				fmt.Fprintf(fs.Output(), "使い方: dls-encoder %s\n  %s\n\nフラグ:\n", c.usage, c.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// listFlag はカンマ区切りまたは複数回の指定で値を追加するフラグです。
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// targetFlags は処理対象の作品を絞り込むフラグです。
+type targetFlags struct {
+	only     listFlag
+	match    listFlag
+	exclude  listFlag
+	fromFile string
+}
+
+// addTargetFlags は作品の絞り込み用フラグをフラグセットに登録します。
+func addTargetFlags(fs *flag.FlagSet) *targetFlags {
+	t := &targetFlags{}
+	fs.Var(&t.only, "only", "対象とする作品キー（カンマ区切り、複数回指定可）")
+	fs.Var(&t.match, "match", "対象とする作品キーのglobパターン（例: 'RJ01*'、複数回指定可）")
+	fs.Var(&t.exclude, "exclude-key", "除外する作品キーまたはglobパターン（カンマ区切り、複数回指定可）")
+	fs.StringVar(&t.fromFile, "from-file", "", "対象とする作品キーを1行に1つ記述したファイル（- で標準入力）")
+	return t
+}
+
+// selector は位置引数の作品キーとフラグから絞り込み条件を作成します。
+func (t *targetFlags) selector(args []string, stdin io.Reader) (storage.TargetSelector, error) {
+	selector := storage.TargetSelector{
+		Only:    append(append([]string{}, t.only...), args...),
+		Match:   t.match,
+		Exclude: t.exclude,
+	}
+
+	if t.fromFile != "" {
+		r := stdin
+		if t.fromFile != "-" {
+			file, err := os.Open(t.fromFile)
+			if err != nil {
+				return selector, fmt.Errorf("作品キー一覧ファイルのオープンに失敗: %w", err)
+			}
+			defer file.Close()
+			r = file
+		}
+		keys, err := storage.ReadKeyList(r)
+		if err != nil {
+			return selector, err
+		}
+		if len(keys) == 0 {
+			return selector, fmt.Errorf("作品キー一覧に作品キーが含まれていません: %s", t.fromFile)
+		}
+		selector.Only = append(selector.Only, keys...)
+	}
+
+	if err := selector.Validate(); err != nil {
+		return selector, err
+	}
+	return selector, nil
+}
+
+// loadConfig は設定ファイルを読み込んで検証し、共通フラグを反映します。
+func (c *commonFlags) loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(config.LoadOptions{Path: c.configPath, Profile: c.profile})
+	if err != nil {
+		return nil, fmt.Errorf("設定ファイルの読み込みに失敗: %w", err)
+	}
+
+	// 設定値のバリデーション
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("設定値の検証に失敗: %w", err)
+	}
+	if err := validateEncoding(cfg); err != nil {
+		return nil, fmt.Errorf("設定値の検証に失敗: %w", err)
+	}
+
+	if c.debug {
+		cfg.Setting.Debug = true
+	}
+	return cfg, nil
+}
+
+// setupConsoleLogging はログファイルを作成しないサブコマンド向けにコンソール出力のみのロガーを設定します。
+func setupConsoleLogging(debug bool) {
+	level := logger.INFO
+	if debug {
+		level = logger.DEBUG
+	}
+	logger.SetDefaultLogger(logger.NewStandardLogger(level, debug))
+}
+
+// runEncodeCommand は encode サブコマンドを実行します。
+func runEncodeCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("encode", &common)
+	dryRun := fs.Bool("dry-run", false, "変換を行わずに出力先のツリーとffmpegコマンドを表示します")
+	continueOnError := fs.Bool("continue-on-error", false, "作品の変換に失敗しても残りの作品の処理を継続します")
+	workers := fs.Int("workers", 0, "並列実行するffmpegの数（設定ファイルの workers より優先）")
+	progress := fs.String("progress", "", "進捗の表示方法 auto / tty / log / off（設定ファイルの progress より優先）")
+	preset := fs.String("preset", "", "すべての作品に使用するエンコード設定のプリセット名（設定ファイルの preset・work_preset より優先）")
+	targets := addTargetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selector, err := targets.selector(fs.Args(), os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *continueOnError {
+		cfg.Setting.ContinueOnError = true
+	}
+	if *workers > 0 {
+		cfg.Setting.Workers = *workers
+	}
+	if err := applyProgressFlag(cfg, *progress); err != nil {
+		return err
+	}
+	if err := applyPresetFlag(cfg, *preset); err != nil {
+		return err
+	}
+
+	return runWithContext(ctx, cfg, runOptions{DryRun: *dryRun, Targets: selector})
+}
+
+// applyProgressFlag は -progress で指定された進捗の表示方法を設定に反映します。
+func applyProgressFlag(cfg *config.Config, progress string) error {
+	switch progress {
+	case "":
+		return nil
+	case config.ProgressAuto, config.ProgressTTY, config.ProgressLog, config.ProgressOff:
+		cfg.Setting.Progress = progress
+		return nil
+	default:
+		return fmt.Errorf("-progress には auto / tty / log / off のいずれかを指定してください: %s", progress)
+	}
+}
+
+// applyPresetFlag は -preset で指定されたプリセットをすべての作品に使用するよう設定します。
+func applyPresetFlag(cfg *config.Config, preset string) error {
+	if preset == "" {
+		return nil
+	}
+	cfg.PresetOverride = preset
+	if err := validateEncoding(cfg); err != nil {
+		return fmt.Errorf("-preset: %w", err)
+	}
+	return nil
+}
+
+// runParseCommand は parse サブコマンドを実行します。
+func runParseCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("parse", &common)
+	targets := addTargetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selector, err := targets.selector(fs.Args(), os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	return runParse(ctx, cfg, runOptions{Targets: selector})
+}
+
+// runWatchCommand は watch サブコマンドを実行します。
+func runWatchCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("watch", &common)
+	settle := fs.Duration("settle", 10*time.Second, "最後の書き込みからこの時間経過した作品を処理します")
+	continueOnError := fs.Bool("continue-on-error", false, "作品の変換に失敗しても同時に検知した残りの作品の処理を継続します")
+	workers := fs.Int("workers", 0, "並列実行するffmpegの数（設定ファイルの workers より優先）")
+	progress := fs.String("progress", "", "進捗の表示方法 auto / tty / log / off（設定ファイルの progress より優先）")
+	preset := fs.String("preset", "", "すべての作品に使用するエンコード設定のプリセット名（設定ファイルの preset・work_preset より優先）")
+	targets := addTargetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *settle <= 0 {
+		return fmt.Errorf("-settle には正の値を指定してください: %s", *settle)
+	}
+	selector, err := targets.selector(fs.Args(), os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *continueOnError {
+		cfg.Setting.ContinueOnError = true
+	}
+	if *workers > 0 {
+		cfg.Setting.Workers = *workers
+	}
+	if err := applyProgressFlag(cfg, *progress); err != nil {
+		return err
+	}
+	if err := applyPresetFlag(cfg, *preset); err != nil {
+		return err
+	}
+
+	return runWatch(ctx, cfg, watchOptions{
+		runOptions: runOptions{Targets: selector},
+		Settle:     *settle,
+		Interval:   min(time.Second, *settle),
+	})
+}
+
+// runCreateHTMLCommand は create-html サブコマンドを実行します。
+func runCreateHTMLCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("create-html", &common)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := generator.InteractiveHTMLGenerator(cfg.DirSetting.HtmlDir, cfg.DirSetting.ImageDir); err != nil {
+		return fmt.Errorf("HTMLファイルの生成に失敗しました: %w", err)
+	}
+	return nil
+}
+
+// runInspectCommand は inspect サブコマンドを実行します。
+func runInspectCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("inspect", &common)
+	asJSON := fs.Bool("json", false, "JSON形式で出力します")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("作品キーを1つ指定してください")
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	setupConsoleLogging(cfg.Setting.Debug)
+	return runInspect(ctx, cfg, fs.Arg(0), *asJSON, os.Stdout)
+}
+
+// runVerifyCommand は verify サブコマンドを実行します。
+func runVerifyCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("verify", &common)
+	targets := addTargetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selector, err := targets.selector(fs.Args(), os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	setupConsoleLogging(cfg.Setting.Debug)
+	return runVerify(ctx, cfg, runOptions{Targets: selector}, os.Stdout)
+}
+
+// runRetagCommand は retag サブコマンドを実行します。
+func runRetagCommand(ctx context.Context, args []string) error {
+	var common commonFlags
+	fs := newFlagSet("retag", &common)
+	dryRun := fs.Bool("dry-run", false, "ファイルを変更せずに、タグを書き換えるトラックと移動先を表示します")
+	targets := addTargetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	selector, err := targets.selector(fs.Args(), os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	return runRetag(ctx, cfg, runOptions{DryRun: *dryRun, Targets: selector})
+}
+
+// runConfigCommand は config サブコマンドを実行します。
+func runConfigCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("config のサブコマンドを指定してください（利用可能: check）")
+	}
+
+	var common commonFlags
+	fs := newFlagSet("config", &common)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("設定ファイル: %s\n", cfg.File)
+	if cfg.Profile != "" {
+		fmt.Printf("プロファイル: %s\n", cfg.Profile)
+	}
+	fmt.Println("設定ファイルの検証に成功しました。有効な設定値:")
+	for _, field := range cfg.Fields() {
+		// 環境変数で上書きされた項目は変数名を併記する
+		if _, ok := os.LookupEnv(config.EnvName(field.Key)); ok {
+			fmt.Printf("  %s  (%s)\n", field, config.EnvName(field.Key))
+			continue
+		}
+		fmt.Printf("  %s\n", field)
+	}
+
+	if err := printEncodingSettings(os.Stdout, cfg); err != nil {
+		return err
+	}
+	if len(cfg.WorkPresets) > 0 {
+		fmt.Println("作品ごとのプリセット:")
+		for _, work := range cfg.WorkPresets {
+			fmt.Printf("  %s = %s\n", work.Match, work.Preset)
+		}
+	}
+	if rules := cfg.TagRules(); len(rules) > 0 {
+		fmt.Println("追加のタグ（タグ名 = 作品データの項目）:")
+		for _, rule := range rules {
+			line := fmt.Sprintf("  %s = %s", rule.Tag, strings.Join(rule.Source, " / "))
+			if rule.Convert != "" {
+				line += " (" + rule.Convert + ")"
+			}
+			if rule.Multiple {
+				line += fmt.Sprintf(" (複数の値を %q で区切る)", rule.SeparatorOrDefault())
+			}
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+// printEncodingSettings は既定のエンコード設定と、各プリセットを適用したエンコード設定を表示します。
+// audiobook が有効な場合は output_format ではなく、オーディオブックの形式での設定を表示します。
+func printEncodingSettings(w io.Writer, cfg *config.Config) error {
+	format, err := baseFormat(cfg)
+	if err != nil {
+		return err
+	}
+	if effective, err := outputFormat(cfg, ""); err == nil {
+		fmt.Fprintf(w, "既定のエンコード設定（出力形式 %s）: %s\n", format.Name, effective.Signature())
+	}
+	if len(cfg.Presets) > 0 {
+		fmt.Fprintln(w, "プリセット（出力形式 "+format.Name+" でのエンコード設定）:")
+		for _, name := range cfg.PresetNames() {
+			preset, err := format.WithPreset(name, cfg.Presets[name])
+			if err != nil {
+				fmt.Fprintf(w, "  %s = (この出力形式では使用できません: %v)\n", name, err)
+				continue
+			}
+			fmt.Fprintf(w, "  %s = %s\n", name, preset.Signature())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/storage"
+)
+
+func TestStripLegacyCreateHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		want  []string
+		found bool
+	}{
+		{"フラグなし", []string{"-debug"}, []string{"-debug"}, false},
+		{"単独指定", []string{"-create-html"}, []string{}, true},
+		{"他のフラグと併用", []string{"-debug", "--create-html=true"}, []string{"-debug"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := stripLegacyCreateHTML(tt.args)
+			if found != tt.found {
+				t.Errorf("found: got %v, want %v", found, tt.found)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCLIUnknownSubcommand(t *testing.T) {
+	if got := runCLI(context.Background(), []string{"unknown"}); got != exitFatal {
+		t.Errorf("不明なサブコマンドの終了コード: got %d, want %d", got, exitFatal)
+	}
+}
+
+func TestRunCLILegacyWorkKey(t *testing.T) {
+	for _, key := range []string{"RJ01234567", "BJ123456", "d_123456"} {
+		if !workKeyPattern.MatchString(key) {
+			t.Errorf("%q はサブコマンドを省略した encode として扱うべき", key)
+		}
+	}
+	for _, name := range []string{"unknown", "encod", "RJ", "d_", "config"} {
+		if workKeyPattern.MatchString(name) {
+			t.Errorf("%q を作品キーとして扱ってはいけない", name)
+		}
+	}
+
+	// 作品キーのみの指定は encode として実行する（-h で encode の使い方を表示して終了する）
+	if got := runCLI(context.Background(), []string{"RJ01234567", "-h"}); got != exitOK {
+		t.Errorf("作品キーのみを指定した場合の終了コード: got %d, want %d", got, exitOK)
+	}
+}
+
+func TestPrintEncodingSettingsAudiobook(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	cfg.Presets = map[string]config.Preset{"low": {Bitrate: "64k"}}
+
+	var buf bytes.Buffer
+	if err := printEncodingSettings(&buf, cfg); err != nil {
+		t.Fatalf("エンコード設定の表示に失敗: %v", err)
+	}
+	if !strings.Contains(buf.String(), "出力形式 mp3") {
+		t.Errorf("output_format の設定を表示すべき:\n%s", buf.String())
+	}
+
+	// audiobook が有効な場合は、実際に使用するオーディオブックの形式での設定を表示する
+	cfg.Setting.Audiobook = config.AudiobookM4B
+	buf.Reset()
+	if err := printEncodingSettings(&buf, cfg); err != nil {
+		t.Fatalf("エンコード設定の表示に失敗: %v", err)
+	}
+	if !strings.Contains(buf.String(), "既定のエンコード設定（出力形式 m4b）: m4b: -c:a aac") || !strings.Contains(buf.String(), "low = m4b: -c:a aac -b:a 64k") || strings.Contains(buf.String(), "mp3") {
+		t.Errorf("オーディオブックの形式での設定を表示すべき:\n%s", buf.String())
+	}
+}
+
+func TestLoadTargetsFiltersKeys(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	writeSourceFiles(t, cfg, "RJ01234567", map[string]string{"01.wav": "one"})
+	writeSourceFiles(t, cfg, "d_123456", map[string]string{"01.wav": "one"})
+
+	got, err := loadTargets(cfg, runOptions{Targets: storage.TargetSelector{Only: []string{"d_123456", "RJ99999999"}}})
+	if err != nil {
+		t.Fatalf("loadTargetsの実行に失敗: %v", err)
+	}
+	if want := []string{"d_123456"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("絞り込み結果: got %v, want %v", got, want)
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	keys := []string{"d_123456", "d_654321"}
+	if err := os.MkdirAll(cfg.DirSetting.HtmlDir, 0755); err != nil {
+		t.Fatalf("HTMLディレクトリの作成に失敗: %v", err)
+	}
+	for _, key := range keys {
+		writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+		htmlContent := `<!DOCTYPE html><html><head><title>【作品】テスト作品` + key + `【テスト声優】(テストサークル)｜同人</title></head><body></body></html>`
+		if err := os.WriteFile(filepath.Join(cfg.DirSetting.HtmlDir, key+".html"), []byte(htmlContent), 0644); err != nil {
+			t.Fatalf("HTMLファイルの作成に失敗: %v", err)
+		}
+	}
+
+	// 一致した作品が1つもない場合は処理全体の失敗
+	var buf bytes.Buffer
+	err := runVerify(ctx, cfg, runOptions{}, &buf)
+	if got := exitCode(err); got != exitFatal {
+		t.Fatalf("すべて未変換の場合の終了コード: got %d, want %d (%v)", got, exitFatal, err)
+	}
+	if !strings.Contains(buf.String(), "変換記録がありません") {
+		t.Errorf("未変換であることが表示されていません:\n%s", buf.String())
+	}
+
+	data, _, _, err := processDirectories(ctx, cfg, keys)
+	if err != nil {
+		t.Fatalf("HTMLの解析に失敗: %v", err)
+	}
+	for _, key := range keys {
+		if err := convertFiles(ctx, cfg, key, data[key]); err != nil {
+			t.Fatalf("変換に失敗: %v", err)
+		}
+	}
+
+	buf.Reset()
+	if err := runVerify(ctx, cfg, runOptions{}, &buf); err != nil {
+		t.Fatalf("変換直後の検証で問題が検出されました: %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "OK   "+keys[0]) {
+		t.Errorf("検証結果に OK が含まれていません:\n%s", buf.String())
+	}
+
+	// 一致した作品がある場合は一部の失敗
+	writeSourceFiles(t, cfg, keys[0], map[string]string{"02.wav": "two (fixed)"})
+	buf.Reset()
+	err = runVerify(ctx, cfg, runOptions{}, &buf)
+	if got := exitCode(err); got != exitPartialFailure {
+		t.Fatalf("変更後の検証の終了コード: got %d, want %d (%v)", got, exitPartialFailure, err)
+	}
+	if !strings.Contains(buf.String(), "- 02.mp3") || strings.Contains(buf.String(), "- 01.mp3") || !strings.Contains(buf.String(), "OK   "+keys[1]) {
+		t.Errorf("再エンコードが必要なトラックの表示が正しくありません:\n%s", buf.String())
+	}
+}
+
+func TestTargetFlagsSelector(t *testing.T) {
+	fs := newFlagSet("encode", &commonFlags{})
+	targets := addTargetFlags(fs)
+	args := []string{"--only", "RJ01234567,d_123456", "--match", "RJ02*", "--exclude-key", "d_123456", "--from-file", "-", "RJ03000000"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("フラグの解析に失敗: %v", err)
+	}
+
+	selector, err := targets.selector(fs.Args(), strings.NewReader("# コメント\nRJ04000000\n\n"))
+	if err != nil {
+		t.Fatalf("絞り込み条件の作成に失敗: %v", err)
+	}
+	want := storage.TargetSelector{
+		Only:    []string{"RJ01234567", "d_123456", "RJ03000000", "RJ04000000"},
+		Match:   []string{"RJ02*"},
+		Exclude: []string{"d_123456"},
+	}
+	if !reflect.DeepEqual(selector, want) {
+		t.Errorf("絞り込み条件: got %+v, want %+v", selector, want)
+	}
+
+	fs = newFlagSet("encode", &commonFlags{})
+	targets = addTargetFlags(fs)
+	if err := fs.Parse([]string{"--from-file", "-"}); err != nil {
+		t.Fatalf("フラグの解析に失敗: %v", err)
+	}
+	if _, err := targets.selector(fs.Args(), strings.NewReader("\n")); err == nil {
+		t.Error("作品キーを含まない一覧を指定した場合はエラーになるべき")
+	}
+}
@@ -0,0 +1,804 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/cover"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/loudness"
+	"github.com/kkryama/dls-encoder/internal/manifest"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"github.com/kkryama/dls-encoder/internal/worker"
+)
+
+// albumPlan は1作品分の変換計画です。
+type albumPlan struct {
+	Key       string               // 作品キー（RJxxxxxxxx や d_xxxxxx）
+	Data      model.IndividualData // HTMLから抽出した作品データ
+	OutputDir string               // 出力先ディレクトリ
+	Tracks    []trackPlan          // 変換対象のトラック一覧
+	Previous  *manifest.Manifest   // 前回の変換記録（存在しない場合はnil）
+	Partial   *manifest.Manifest   // 中断された前回の実行が作業用ディレクトリに残した変換記録（存在しない場合はnil）
+
+	CoverImage   string         // メイン画像のパス（画像なしの場合は空）
+	CoverOptions *cover.Options // 埋め込む前のメイン画像の加工方法（cover_processing が無効な場合はnil）
+	CoverFiles   []string       // アルバムのディレクトリに保存するフォルダ画像のファイル名
+
+	Skipped []model.SkippedFile // 変換対象から外した音声ファイルと理由
+}
+
+// trackPlan は1ファイル分の変換計画です。
+type trackPlan struct {
+	InputFile  string                     // 変換元ファイルのパス
+	OutputFile string                     // 変換後ファイルのパス
+	Metadata   audioconverter.MP3Metadata // 設定するメタデータ
+	Format     audioconverter.Format      // 出力形式（変換元のサンプリングレートに合わせて調整済み）
+	Source     *model.AudioSource         // ffprobe で調べた変換元の情報（不明な場合はnil）
+	Loudness   *loudness.Measurement      // ラウドネスの測定結果（loudness が無効または未測定の場合はnil）
+	Record     manifest.Track             // マニフェストに記録する内容
+	Skip       bool                       // 前回から変更がなく再エンコードを省略するかどうか
+	Resumed    bool                       // 中断された前回の実行で作業用ディレクトリに変換済みのため、そのまま再利用するかどうか
+
+	// Chapters はオーディオブックとして1ファイルにまとめる音声ファイルです（audiobook が無効な場合はnil）。
+	// まとめる場合の InputFile は作品のディレクトリです。
+	Chapters []audioconverter.Chapter
+}
+
+// pendingTracks は再エンコードが必要なトラックを返します。
+func (p *albumPlan) pendingTracks() []trackPlan {
+	var pending []trackPlan
+	for _, track := range p.Tracks {
+		if !track.Skip {
+			pending = append(pending, track)
+		}
+	}
+	return pending
+}
+
+// upToDate は出力アルバムが前回の変換記録と完全に一致しており、何も変更する必要がないかどうかを返します。
+func (p *albumPlan) upToDate() bool {
+	if p.Previous == nil || len(p.Previous.Tracks) != len(p.Tracks) || !slices.Equal(p.Previous.CoverFiles, p.CoverFiles) {
+		return false
+	}
+	for _, track := range p.Tracks {
+		if !track.Skip || track.Resumed {
+			return false
+		}
+	}
+	return true
+}
+
+// keepFiles は作業用ディレクトリに前回の出力から取り込むファイルの、アルバムのディレクトリからの相対パス（区切りは "/"）を返します。
+func (p *albumPlan) keepFiles() map[string]bool {
+	keep := make(map[string]bool)
+	for _, track := range p.Tracks {
+		if track.Skip && !track.Resumed {
+			keep[track.Record.Output] = true
+		}
+	}
+	if len(keep) > 0 {
+		keep[manifest.FileName] = true
+	}
+	return keep
+}
+
+// resumedFiles は中断された前回の実行が作業用ディレクトリに残した出力のうち、再利用するファイルの相対パス（区切りは "/"）を返します。
+func (p *albumPlan) resumedFiles() map[string]bool {
+	resumed := make(map[string]bool)
+	for _, track := range p.Tracks {
+		if track.Resumed {
+			resumed[track.Record.Output] = true
+		}
+	}
+	if len(resumed) > 0 {
+		resumed[manifest.FileName] = true
+	}
+	return resumed
+}
+
+// buildAlbumPlan は作品データから出力先ディレクトリとトラックごとの変換計画を組み立てます。
+// 音声ファイルが見つからない場合はエラーを返します。
+func buildAlbumPlan(cfg *config.Config, key string, value model.IndividualData) (*albumPlan, error) {
+	format, err := outputFormat(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	targetDir := filepath.Join(cfg.DirSetting.SourceDir, key)
+	selection := audioSelection(cfg, key, value)
+	audioFiles := selection.Files
+
+	if len(audioFiles) == 0 {
+		return nil, fmt.Errorf("%w: %s", errNoAudioFiles, targetDir)
+	}
+
+	// Actor が複数の場合、省略してディレクトリ名を短くする
+	actors := splitActorNames(value.Actor)
+	if len(actors) == 0 {
+		trimmed := strings.TrimSpace(value.Actor)
+		if trimmed != "" {
+			actors = []string{trimmed}
+		}
+	}
+	if len(actors) > 2 {
+		actors = append(actors[:2], "他")
+	}
+	actorDir := sanitizeDirName(strings.Join(actors, "・"), cfg)
+
+	shortAlbumTitle := sanitizeDirName(truncateAlbumTitle(value.AlbumTitle), cfg)
+
+	mp3OutputDir := filepath.Join(cfg.DirSetting.OutputDir, cfg.DirSetting.Mp3OutputDirName, actorDir, sanitizeDirName(value.Brand, cfg), fmt.Sprintf("【%s】%s", key, shortAlbumTitle))
+
+	var coverImage *string
+	if value.MainImage != "" {
+		coverImage = &value.MainImage
+	}
+
+	baseMetaData := audioconverter.MP3Metadata{
+		Artist:      value.Actor,
+		AlbumArtist: value.Brand,
+		AlbumTitle:  value.AlbumTitle,
+		CoverImage:  coverImage,
+		Work:        supportedTags(key, format, workTags(cfg, key, value)),
+	}
+
+	var coverSource *manifest.Source
+	if coverImage != nil {
+		source, err := manifest.Fingerprint(*coverImage, cfg.Setting.IncrementalChecksum)
+		if err != nil {
+			return nil, fmt.Errorf("メイン画像の情報取得に失敗: %w", err)
+		}
+		coverSource = &source
+	}
+
+	plan := &albumPlan{
+		Key:       key,
+		Data:      value,
+		OutputDir: mp3OutputDir,
+		Tracks:    make([]trackPlan, 0, len(audioFiles)),
+		Skipped:   selection.Skipped,
+	}
+	var coverSignature string
+	if coverImage != nil {
+		plan.CoverImage = *coverImage
+		plan.CoverOptions = coverOptions(cfg)
+		plan.CoverFiles = cfg.Setting.CoverFiles
+		if plan.CoverOptions != nil {
+			coverSignature = plan.CoverOptions.Signature()
+		}
+	}
+	sources := sourceIndex(targetDir, value)
+	titles := trackTitles(targetDir, value)
+	numbers := audioconverter.NumberTracks(targetDir, audioFiles)
+	audiobook := cfg.Setting.AudiobookMode() != config.AudiobookOff
+	outputs := make([]string, len(audioFiles))
+	if !audiobook {
+		if outputs, err = outputNames(cfg, targetDir, audioFiles, format.Extension); err != nil {
+			return nil, err
+		}
+	}
+	for i, inputFile := range audioFiles {
+		name := path.Base(inputFile)
+		nameWithoutExt := strings.TrimSuffix(name, filepath.Ext(name))
+
+		metaData := baseMetaData
+		metaData.TrackName = nameWithoutExt
+		if title, ok := titles[filepath.Clean(inputFile)]; ok {
+			// トラック一覧と対応付けられたファイルは、作品ページのトラックタイトルを使用する
+			metaData.TrackName = title
+		}
+		metaData.Track, metaData.TrackTotal = numbers[i].Track, numbers[i].TrackTotal
+		metaData.Disc, metaData.DiscTotal = numbers[i].Disc, numbers[i].DiscTotal
+
+		source, err := manifest.Fingerprint(inputFile, cfg.Setting.IncrementalChecksum)
+		if err != nil {
+			return nil, fmt.Errorf("音声ファイルの情報取得に失敗: %w", err)
+		}
+
+		trackFormat := format
+		probed := sources[filepath.Clean(inputFile)]
+		if probed != nil {
+			trackFormat = format.ForSource(probed.SampleRate)
+		}
+		if !audiobook {
+			trackFormat = passthroughFormat(cfg, key, inputFile, probed, trackFormat)
+		}
+
+		outputFile := filepath.Join(mp3OutputDir, filepath.FromSlash(outputs[i]))
+		plan.Tracks = append(plan.Tracks, trackPlan{
+			InputFile:  inputFile,
+			OutputFile: outputFile,
+			Metadata:   metaData,
+			Format:     trackFormat,
+			Source:     probed,
+			Record: manifest.Track{
+				Output:       outputs[i],
+				Source:       source,
+				Cover:        coverSource,
+				CoverOptions: coverSignature,
+				Metadata:     metaData.TagMap(),
+				Encoder:      trackFormat.Signature(),
+			},
+		})
+	}
+
+	if audiobook {
+		name := shortAlbumTitle
+		if name == "" {
+			name = key
+		}
+		plan.Tracks = []trackPlan{audiobookTrack(plan, targetDir, name, baseMetaData, format)}
+	}
+
+	loadManifest(plan)
+	applyLoudness(cfg, plan)
+	applyManifest(cfg, plan)
+
+	// MP3メタデータのデバッグログを出力
+	logger.LogDebugEvent("mp3_metadata_prepared", map[string]interface{}{
+		"key":        key,
+		"coverImage": value.MainImage,
+		"artist":     value.Actor,
+		"albumTitle": value.AlbumTitle,
+		"outputDir":  mp3OutputDir,
+		"format":     format.Name,
+		"preset":     format.Preset,
+		"trackCount": len(plan.Tracks),
+	})
+
+	return plan, nil
+}
+
+// outputNames は音声ファイルごとの出力ファイルの、アルバムのディレクトリからの相対パス（区切りは "/"）を返します。
+// output_layout が subdir の場合はディスクのディレクトリと同じ構成にします。flat の場合はアルバムのディレクトリ直下に出力し、
+// 別のディレクトリに同名のファイルがある場合のみディスクのディレクトリ名をファイル名の前に付けます（"おまけ - 01.mp3"）。
+func outputNames(cfg *config.Config, targetDir string, files []string, extension string) ([]string, error) {
+	names := make([]string, len(files))
+	dirs := make([][]string, len(files))
+	counts := make(map[string]int)
+	for i, file := range files {
+		base := filepath.Base(file)
+		names[i] = strings.TrimSuffix(base, filepath.Ext(base))
+		if dir := audioconverter.DiscDir(targetDir, file); dir != "" {
+			for _, part := range strings.Split(dir, "/") {
+				dirs[i] = append(dirs[i], sanitizeDirName(part, cfg))
+			}
+		}
+		counts[strings.ToLower(names[i])]++
+	}
+
+	outputs := make([]string, len(files))
+	seen := make(map[string]string)
+	for i, file := range files {
+		name := names[i]
+		switch {
+		case len(dirs[i]) == 0:
+		case cfg.Setting.OutputLayoutMode() == config.OutputLayoutSubdir:
+			name = path.Join(append(dirs[i], name)...)
+		case counts[strings.ToLower(name)] > 1:
+			name = strings.Join(append(dirs[i], name), " - ")
+		}
+		name += extension
+
+		// 大文字小文字を区別しないファイルシステムでも上書きしないよう、大文字小文字を区別せずに比較する
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			return nil, fmt.Errorf("出力ファイル名 %s が重複しています: %s, %s", name, other, file)
+		}
+		seen[strings.ToLower(name)] = file
+		outputs[i] = name
+	}
+	return outputs, nil
+}
+
+// baseFormat はプリセットを適用する前の出力形式を返します。
+// audiobook が有効な場合は output_format にかかわらず、オーディオブックの形式です。
+func baseFormat(cfg *config.Config) (audioconverter.Format, error) {
+	if mode := cfg.Setting.AudiobookMode(); mode != config.AudiobookOff {
+		return audioconverter.AudiobookFormat(mode)
+	}
+	return audioconverter.LookupFormat(cfg.Setting.OutputFormat)
+}
+
+// outputFormat は作品に使用する出力形式を、プリセットを適用して返します。
+func outputFormat(cfg *config.Config, key string) (audioconverter.Format, error) {
+	format, err := baseFormat(cfg)
+	if err != nil {
+		return audioconverter.Format{}, err
+	}
+	name := cfg.PresetFor(key)
+	if name == "" {
+		return format, nil
+	}
+	preset, err := cfg.LookupPreset(name)
+	if err != nil {
+		return audioconverter.Format{}, err
+	}
+	return format.WithPreset(name, preset)
+}
+
+// validateEncoding は出力形式と、使用するプリセットがその出力形式に適用できるかどうかを確認します。
+func validateEncoding(cfg *config.Config) error {
+	format, err := baseFormat(cfg)
+	if err != nil {
+		return fmt.Errorf("output_format: %w", err)
+	}
+
+	if cfg.Setting.LoudnessMode() == config.LoudnessReplayGain && !format.CustomTags() {
+		return fmt.Errorf("loudness: %s には ReplayGain のタグを書き込めません（loudnorm を使用してください）", format.Name)
+	}
+
+	names := []string{cfg.Setting.Preset, cfg.PresetOverride}
+	for _, work := range cfg.WorkPresets {
+		names = append(names, work.Preset)
+	}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		preset, err := cfg.LookupPreset(name)
+		if err != nil {
+			return err
+		}
+		if _, err := format.WithPreset(name, preset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadManifest は出力先に残っている前回の変換記録と、中断された前回の実行が作業用ディレクトリに残した変換記録を読み込みます。
+func loadManifest(plan *albumPlan) {
+	previous, err := manifest.Load(plan.OutputDir)
+	if err != nil {
+		logger.LogWarnEvent("manifest_load_error", map[string]interface{}{
+			"key":       plan.Key,
+			"outputDir": plan.OutputDir,
+			"error":     err.Error(),
+			"message":   fmt.Sprintf("[%s] の変換記録を読み込めないため、すべてのファイルを再エンコードします: %v", plan.Key, err),
+		})
+	} else {
+		plan.Previous = previous
+	}
+
+	partial, err := manifest.Load(stagingDir(plan.OutputDir))
+	if err != nil {
+		// 作業用ディレクトリは変換開始時に削除されるため、再エンコードするだけで済む
+		logger.LogDebugEvent("partial_manifest_load_error", map[string]interface{}{
+			"key":       plan.Key,
+			"outputDir": plan.OutputDir,
+			"error":     err.Error(),
+		})
+		return
+	}
+	plan.Partial = partial
+}
+
+// applyManifest は前回の変換記録と比較し、変更のないトラックを再エンコード対象から外します。
+// 中断された前回の実行で作業用ディレクトリに変換済みのトラックも、変更がなければ再エンコードせずに再利用します。
+// incremental が無効な場合は、すべてのトラックを再エンコードします。
+func applyManifest(cfg *config.Config, plan *albumPlan) {
+	if !cfg.Setting.Incremental || (plan.Previous == nil && plan.Partial == nil) {
+		return
+	}
+
+	staging := stagingDir(plan.OutputDir)
+	skipped, resumed := 0, 0
+	for i := range plan.Tracks {
+		track := &plan.Tracks[i]
+		track.Skip = false
+		track.Resumed = false
+		switch {
+		case matchesRecord(plan.Previous, track.Record, track.OutputFile):
+			track.Skip = true
+			skipped++
+		case matchesRecord(plan.Partial, track.Record, filepath.Join(staging, filepath.FromSlash(track.Record.Output))):
+			track.Skip = true
+			track.Resumed = true
+			resumed++
+		}
+	}
+
+	logger.LogDebugEvent("manifest_applied", map[string]interface{}{
+		"key":     plan.Key,
+		"tracks":  len(plan.Tracks),
+		"skipped": skipped,
+		"resumed": resumed,
+	})
+}
+
+// matchesRecord は変換記録にトラックと同じ内容の出力が記録されており、その出力ファイルが存在するかどうかを返します。
+func matchesRecord(record *manifest.Manifest, track manifest.Track, outputFile string) bool {
+	if record == nil {
+		return false
+	}
+	prev, ok := record.Find(track.Output)
+	if !ok || !prev.Equal(track) {
+		return false
+	}
+	_, err := os.Stat(outputFile)
+	return err == nil
+}
+
+// albumRun は変換中の1作品の進捗と結果を保持します。
+// 同じ作品のトラックは複数のワーカーから並行して処理されるため、状態はmutexで保護します。
+type albumRun struct {
+	plan        *albumPlan
+	pending     []trackPlan
+	prepareOnce sync.Once
+	prepareErr  error
+	finishOnce  sync.Once
+	staging     string // 変換中の出力を書き込む作業用ディレクトリ
+	coverPath   string // 加工したメイン画像の一時ファイル（画像を加工しない場合は空）
+
+	mu        sync.Mutex
+	admitted  bool // いずれかのトラックに着手したかどうか
+	started   bool
+	processed int
+	completed int
+	tracks    map[string]trackResult
+	err       error
+
+	progress *progressTracker // 進捗の集計先（進捗を表示しない場合はnil）
+}
+
+// albumResult は1作品分の変換結果です。
+type albumResult struct {
+	Err    error                  // 作品全体の結果
+	Tracks map[string]trackResult // 出力ファイル名ごとの結果（変換を試みたトラックのみ）
+}
+
+// trackResult は1トラック分の変換結果です。
+type trackResult struct {
+	Duration time.Duration // 変換に要した時間
+	Err      error         // 変換に失敗した場合のエラー
+}
+
+// newAlbumRun は変換計画から作品ごとの実行状態を作成します。
+func newAlbumRun(plan *albumPlan) *albumRun {
+	return &albumRun{
+		plan:    plan,
+		pending: plan.pendingTracks(),
+		tracks:  make(map[string]trackResult),
+	}
+}
+
+// admit は作品のトラックに着手してよいかどうかを返します。
+// halted の後は未着手の作品を開始せず、既に着手した作品は最後まで変換します。
+func (r *albumRun) admit(halted *atomic.Bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.admitted && halted.Load() {
+		return false
+	}
+	r.admitted = true
+	return true
+}
+
+// prepare は作品の最初のトラックに着手する時点で一度だけ作業用ディレクトリを準備します。
+// 出力先ディレクトリは全トラックの変換に成功するまで変更しません。
+// 準備に失敗した場合も作業用ディレクトリは削除しません。中断された前回の実行から引き継いだトラックを含むことがあり、
+// 次回の実行で再利用するか、再利用できなければ削除します。
+func (r *albumRun) prepare(ctx context.Context) error {
+	r.prepareOnce.Do(func() {
+		staging, err := prepareStaging(r.plan)
+		var coverPath string
+		if err == nil {
+			coverPath, err = prepareCover(ctx, r.plan, staging)
+		}
+		r.mu.Lock()
+		r.staging = staging
+		r.coverPath = coverPath
+		r.prepareErr = err
+		r.started = err == nil
+		r.mu.Unlock()
+	})
+	return r.prepareErr
+}
+
+// stagedTrack は出力先を作業用ディレクトリに置き換えたトラックを返します。
+func (r *albumRun) stagedTrack(track trackPlan) trackPlan {
+	track.OutputFile = filepath.Join(r.staging, filepath.FromSlash(track.Record.Output))
+	if r.coverPath != "" {
+		track.Metadata.CoverImage = &r.coverPath
+		track.Metadata.CoverReady = true
+	}
+	return track
+}
+
+// prepared は作業用ディレクトリの準備が完了しているかどうかを返します。
+func (r *albumRun) prepared() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started
+}
+
+// failed は作品の変換が既に失敗しているかどうかを返します。
+func (r *albumRun) failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err != nil
+}
+
+// convertTrack は作品内の1トラックを変換し、作品ごとの進捗を記録します。
+// 作品内の全トラックを処理し終えた時点で変換記録を保存します。
+func (r *albumRun) convertTrack(ctx context.Context, track trackPlan) error {
+	err := r.convert(ctx, track)
+
+	r.mu.Lock()
+	r.processed++
+	done := r.processed == len(r.pending)
+	r.mu.Unlock()
+
+	if done {
+		r.finish()
+	}
+	return err
+}
+
+// convert はトラックを変換し、成功した場合は完了数を更新します。
+func (r *albumRun) convert(ctx context.Context, track trackPlan) error {
+	// 同じ作品の別トラックが失敗している場合、残りは変換しても成果物にならないため省略する
+	if r.failed() {
+		return nil
+	}
+
+	key := r.plan.Key
+	if err := r.prepare(ctx); err != nil {
+		return r.fail(err)
+	}
+
+	r.progress.begin(key, track)
+	start := time.Now()
+	err := convertSingleFile(ctx, r.stagedTrack(track), r.progress.options(track))
+	r.progress.end(track)
+	if err != nil {
+		err = fmt.Errorf("ファイル変換に失敗: %w", err)
+		r.mu.Lock()
+		r.tracks[track.Record.Output] = trackResult{Duration: time.Since(start), Err: err}
+		r.mu.Unlock()
+		return r.fail(err)
+	}
+
+	r.mu.Lock()
+	r.tracks[track.Record.Output] = trackResult{Duration: time.Since(start)}
+	r.completed++
+	completed := r.completed
+	r.mu.Unlock()
+
+	total := len(r.pending)
+	logger.LogMessage(fmt.Sprintf("[%s] のファイル [%s] の%s変換が完了 (%d/%d)", key, path.Base(track.InputFile), strings.ToUpper(track.Format.Name), completed, total))
+	logger.LogDebugEvent("mp3_conversion_completed", map[string]interface{}{
+		"key":       key,
+		"file":      path.Base(track.InputFile),
+		"inputPath": track.InputFile,
+		"completed": completed,
+		"total":     total,
+	})
+	if completed == total {
+		logger.LogMessage(fmt.Sprintf("[%s] の変換が完了しました (%dファイル)", key, total))
+	}
+	return nil
+}
+
+// fail は作品の最初のエラーを記録して返します。
+func (r *albumRun) fail(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// finish は全トラックの変換に成功した場合、作業用ディレクトリに変換記録を保存して出力先と入れ替えます。
+// 失敗や中断で完了しなかった場合は前回の出力をそのまま残し、変換済みのトラックを次回の実行で再利用できるよう
+// 作業用ディレクトリに途中までの変換記録を保存します。
+func (r *albumRun) finish() {
+	r.finishOnce.Do(func() {
+		if !r.prepared() {
+			return
+		}
+		if r.coverPath != "" {
+			os.Remove(r.coverPath)
+		}
+
+		r.mu.Lock()
+		complete := r.err == nil && r.completed == len(r.pending)
+		r.mu.Unlock()
+
+		if !complete {
+			r.suspend()
+			return
+		}
+
+		records := make([]manifest.Track, 0, len(r.plan.Tracks))
+		for _, track := range r.plan.Tracks {
+			records = append(records, track.Record)
+		}
+		record := manifest.New(r.plan.Key, records)
+		record.CoverFiles = r.plan.CoverFiles
+		if err := record.Save(r.staging); err != nil {
+			// 記録がなくても次回すべてを再エンコードするだけなので、警告に留める
+			logger.LogWarnEvent("manifest_save_error", map[string]interface{}{
+				"key":       r.plan.Key,
+				"outputDir": r.plan.OutputDir,
+				"error":     err.Error(),
+				"message":   fmt.Sprintf("[%s] の変換記録の保存に失敗: %v", r.plan.Key, err),
+			})
+		}
+
+		if err := swapAlbumDir(r.staging, r.plan.OutputDir); err != nil {
+			os.RemoveAll(r.staging)
+			r.fail(err)
+		}
+	})
+}
+
+// suspend は完了しなかった作品の作業用ディレクトリに、出力済みのトラックだけの変換記録を保存します。
+// 再利用できるトラックがない場合や記録を保存できない場合は、作業用ディレクトリを削除します。
+func (r *albumRun) suspend() {
+	key := r.plan.Key
+	r.mu.Lock()
+	var records []manifest.Track
+	for _, track := range r.plan.Tracks {
+		if result, ok := r.tracks[track.Record.Output]; track.Skip || (ok && result.Err == nil) {
+			records = append(records, track.Record)
+		}
+	}
+	r.mu.Unlock()
+
+	saved := false
+	if len(records) > 0 {
+		if err := manifest.New(key, records).Save(r.staging); err != nil {
+			logger.LogWarnMessage(fmt.Sprintf("[%s] の途中までの変換記録の保存に失敗: %v", key, err))
+		} else {
+			saved = true
+		}
+	}
+	if !saved {
+		if err := os.RemoveAll(r.staging); err != nil {
+			logger.LogWarnMessage(fmt.Sprintf("[%s] の作業用ディレクトリの削除に失敗: %v", key, err))
+		}
+	}
+
+	exists, _ := audioconverter.DirExists(r.plan.OutputDir)
+	switch {
+	case saved && exists:
+		logger.LogMessage(fmt.Sprintf("[%s] の変換が完了しなかったため、前回の出力を残します。変換済みの %d ファイルは次回の実行で再利用します", key, len(records)))
+	case saved:
+		logger.LogMessage(fmt.Sprintf("[%s] の変換が完了しなかったため、変換済みの %d ファイルを次回の実行で再利用します", key, len(records)))
+	case exists:
+		logger.LogMessage(fmt.Sprintf("[%s] の変換が完了しなかったため、前回の出力を残します", key))
+	}
+}
+
+// result は作品全体の変換結果を返します。
+// 全トラックが完了していない場合は、中断の原因となったエラーを返します。
+func (r *albumRun) result(ctxErr error) albumResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tracks := make(map[string]trackResult, len(r.tracks))
+	for output, track := range r.tracks {
+		tracks[output] = track
+	}
+	result := albumResult{Err: r.err, Tracks: tracks}
+	if result.Err == nil && r.completed < len(r.pending) {
+		if ctxErr == nil {
+			ctxErr = context.Canceled
+		}
+		result.Err = fmt.Errorf("変換処理がキャンセルされました: %w", ctxErr)
+	}
+	return result
+}
+
+// convertAlbums は複数作品のトラックをワーカープールで並列に変換し、作品キーごとの結果を返します。
+// continue_on_error が無効な場合、いずれかのトラックが失敗した時点で未着手の作品の開始を止めます。
+// 着手済みの作品は最後まで変換し、それぞれの結果を返します。
+// 有効な場合は失敗した作品の残りのトラックのみを省略し、他の作品の変換は継続します。
+func convertAlbums(ctx context.Context, cfg *config.Config, plans []*albumPlan) map[string]albumResult {
+	var halted atomic.Bool
+	runs := make([]*albumRun, 0, len(plans))
+	var jobs []worker.Job
+	for _, plan := range plans {
+		run := newAlbumRun(plan)
+		runs = append(runs, run)
+
+		if plan.upToDate() {
+			logger.LogMessage(fmt.Sprintf("[%s] は前回の変換から変更がないためスキップします", plan.Key))
+			continue
+		}
+		if len(run.pending) == 0 {
+			// 再エンコードは不要だが、削除されたトラックの出力を除いて記録を更新する
+			if err := run.prepare(ctx); err != nil {
+				run.fail(err)
+			}
+			run.finish()
+			continue
+		}
+		if skipped := len(plan.Tracks) - len(run.pending); skipped > 0 {
+			logger.LogMessage(fmt.Sprintf("[%s] は %d/%d ファイルが前回から変更されていないため、残りのみ変換します", plan.Key, skipped, len(plan.Tracks)))
+		}
+
+		for _, track := range run.pending {
+			jobs = append(jobs, func(ctx context.Context) error {
+				if !run.admit(&halted) {
+					// 他の作品の失敗により開始しない（結果はキャンセルとして扱う）
+					return nil
+				}
+				err := run.convertTrack(ctx, track)
+				if err != nil && !cfg.Setting.ContinueOnError {
+					halted.Store(true)
+				}
+				return err
+			})
+		}
+	}
+
+	var pending []trackPlan
+	for _, run := range runs {
+		pending = append(pending, run.pending...)
+	}
+	progress, stopProgress := startProgress(ctx, cfg, pending)
+	for _, run := range runs {
+		run.progress = progress
+	}
+
+	workers := cfg.Setting.WorkerCount()
+	logger.LogDebugEvent("convertAlbums_called", map[string]interface{}{
+		"albumCount": len(plans),
+		"jobCount":   len(jobs),
+		"workers":    workers,
+	})
+	worker.Run(ctx, workers, jobs)
+	stopProgress()
+
+	results := make(map[string]albumResult, len(runs))
+	for _, run := range runs {
+		// 最後まで処理されなかった作品は、変換済みのトラックを次回再利用できるよう作業用ディレクトリに記録を残す
+		run.finish()
+		results[run.plan.Key] = run.result(ctx.Err())
+	}
+	return results
+}
+
+// conversionErrors は作品キーごとの変換結果からエラーのみを取り出します。
+func conversionErrors(results map[string]albumResult) map[string]error {
+	errs := make(map[string]error, len(results))
+	for key, result := range results {
+		errs[key] = result.Err
+	}
+	return errs
+}
+
+// firstConversionError は変換結果から失敗の原因となったエラーを作品キー順で探して返します。
+// 他の作品の失敗に伴うキャンセルは原因ではないため、キャンセル以外のエラーを優先します。
+func firstConversionError(keys []string, results map[string]error) error {
+	var canceled error
+	for _, key := range keys {
+		err := results[key]
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, context.Canceled) {
+			if canceled == nil {
+				canceled = fmt.Errorf("[%s]の変換に失敗: %w", key, err)
+			}
+			continue
+		}
+		return fmt.Errorf("[%s]の変換に失敗: %w", key, err)
+	}
+	return canceled
+}
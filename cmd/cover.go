@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/cover"
+	"github.com/kkryama/dls-encoder/internal/logger"
+)
+
+// coverOptions は cover_processing が有効な場合に、メイン画像の加工方法を返します。無効な場合は nil です。
+func coverOptions(cfg *config.Config) *cover.Options {
+	if !cfg.Setting.CoverProcessing {
+		return nil
+	}
+	return &cover.Options{
+		MaxSize: cfg.Setting.CoverMaxSize,
+		Square:  cfg.Setting.CoverSquare,
+		Quality: cfg.Setting.CoverQuality,
+	}
+}
+
+// coverJPEG は作品のメイン画像を、埋め込み・フォルダ画像に使用する JPEG のデータとして返します。
+// cover_processing が有効な場合は加工した画像、無効な場合は元の画像（JPEG 以外は JPEG に変換したもの）です。
+func coverJPEG(ctx context.Context, plan *albumPlan) ([]byte, error) {
+	if plan.CoverImage == "" {
+		return nil, nil
+	}
+	if plan.CoverOptions != nil {
+		data, err := cover.Process(plan.CoverImage, *plan.CoverOptions)
+		if err != nil {
+			return nil, fmt.Errorf("メイン画像の加工に失敗: %w", err)
+		}
+		return data, nil
+	}
+	return audioconverter.CoverJPEG(ctx, plan.CoverImage)
+}
+
+// writeCoverFiles はアルバムのディレクトリに cover_files のフォルダ画像を保存します。
+func writeCoverFiles(plan *albumPlan, dir string, data []byte) error {
+	for _, name := range plan.CoverFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return fmt.Errorf("フォルダ画像の保存に失敗: %w", err)
+		}
+	}
+	return nil
+}
+
+// prepareCover は作業用ディレクトリにフォルダ画像を保存し、画像を加工する場合は埋め込み用の画像を一時ファイルに保存してそのパスを返します。
+// 画像を加工しない場合は空文字列を返し、ffmpeg が元の画像を埋め込みます。
+func prepareCover(ctx context.Context, plan *albumPlan, staging string) (string, error) {
+	if plan.CoverImage == "" || (plan.CoverOptions == nil && len(plan.CoverFiles) == 0) {
+		return "", nil
+	}
+	data, err := coverJPEG(ctx, plan)
+	if err != nil {
+		return "", err
+	}
+	if err := writeCoverFiles(plan, staging, data); err != nil {
+		return "", err
+	}
+	if plan.CoverOptions == nil {
+		return "", nil
+	}
+
+	file, err := os.CreateTemp("", "dls-encoder-cover-*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("一時ファイルの書き込みに失敗: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("一時ファイルの書き込みに失敗: %w", err)
+	}
+	logger.LogDebugEvent("cover_prepared", map[string]interface{}{
+		"key":        plan.Key,
+		"coverImage": plan.CoverImage,
+		"options":    plan.CoverOptions.Signature(),
+		"size":       len(data),
+		"coverFiles": plan.CoverFiles,
+	})
+	return file.Name(), nil
+}
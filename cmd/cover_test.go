@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestConvertFilesCoverProcessing(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.CoverProcessing = true
+	cfg.Setting.CoverMaxSize = 100
+	cfg.Setting.CoverFiles = []string{"cover.jpg", "folder.jpg"}
+	ctx := context.Background()
+
+	mainImage := filepath.Join(t.TempDir(), "RJ01234567_img_main.png")
+	file, err := os.Create(mainImage)
+	if err != nil {
+		t.Fatalf("メイン画像の作成に失敗: %v", err)
+	}
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatalf("PNG のエンコードに失敗: %v", err)
+	}
+	file.Close()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テストアルバム", Actor: "テスト声優", Brand: "テストサークル", MainImage: mainImage}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	content, err := os.ReadFile(callLog)
+	if err != nil {
+		t.Fatalf("呼び出しログの読み込みに失敗: %v", err)
+	}
+	if strings.Contains(string(content), mainImage) || !strings.Contains(string(content), "-c:v copy") {
+		t.Errorf("加工した画像を再エンコードせずに埋め込むべき: %s", content)
+	}
+
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	for _, name := range cfg.Setting.CoverFiles {
+		cover, err := os.Open(filepath.Join(plan.OutputDir, name))
+		if err != nil {
+			t.Fatalf("フォルダ画像 %s がありません: %v", name, err)
+		}
+		config, err := jpeg.DecodeConfig(cover)
+		cover.Close()
+		if err != nil || config.Width != 100 || config.Height != 50 {
+			t.Errorf("フォルダ画像 %s は縮小した JPEG にするべき: %+v, %v", name, config, err)
+		}
+	}
+	if !plan.upToDate() {
+		t.Error("変更がない場合は変換済みと判定されるべき")
+	}
+
+	// フォルダ画像の変更だけでは再エンコードしない
+	cfg.Setting.CoverFiles = []string{"folder.jpg"}
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("2回目の変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 2 {
+		t.Errorf("フォルダ画像の変更で再エンコードするべきではない: got %d, want 2", got)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "cover.jpg")); !os.IsNotExist(err) {
+		t.Errorf("cover_files から外したフォルダ画像が残っています: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "folder.jpg")); err != nil {
+		t.Errorf("フォルダ画像がありません: %v", err)
+	}
+
+	// 加工方法を変えると埋め込む画像が変わるため再エンコードする
+	cfg.Setting.CoverMaxSize = 50
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("3回目の変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 4 {
+		t.Errorf("加工方法の変更で全トラックを再エンコードするべき: got %d, want 4", got)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+)
+
+// planNode はドライラン表示用の出力ディレクトリツリーの1ノードです。
+type planNode struct {
+	name     string
+	children map[string]*planNode
+	album    *albumPlan // アルバムディレクトリの場合のみ設定
+}
+
+// child は指定された名前の子ノードを返します。存在しない場合は作成します。
+func (n *planNode) child(name string) *planNode {
+	if n.children == nil {
+		n.children = make(map[string]*planNode)
+	}
+	if c, ok := n.children[name]; ok {
+		return c
+	}
+	c := &planNode{name: name}
+	n.children[name] = c
+	return c
+}
+
+// sortedChildren は子ノードを名前順で返します。
+func (n *planNode) sortedChildren() []*planNode {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	children := make([]*planNode, 0, len(names))
+	for _, name := range names {
+		children = append(children, n.children[name])
+	}
+	return children
+}
+
+// printDryRun は変換計画を出力ディレクトリのツリーと ffmpeg コマンドとして表示します。
+// ディスク上のファイルは一切変更しません。
+func printDryRun(w io.Writer, cfg *config.Config, plans []*albumPlan) {
+	rootDir := filepath.Join(cfg.DirSetting.OutputDir, cfg.DirSetting.Mp3OutputDirName)
+	root := &planNode{name: rootDir}
+	for _, plan := range plans {
+		node := root
+		rel, err := filepath.Rel(rootDir, plan.OutputDir)
+		if err != nil {
+			rel = plan.OutputDir
+		}
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			node = node.child(part)
+		}
+		node.album = plan
+	}
+
+	fmt.Fprintln(w, "=== 出力ディレクトリ ===")
+	fmt.Fprintln(w, root.name+string(filepath.Separator))
+	printPlanTree(w, root, "")
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "=== ffmpeg コマンド ===")
+	for _, plan := range plans {
+		fmt.Fprintf(w, "[%s] %s\n", plan.Key, plan.OutputDir)
+		for _, warning := range plan.Data.Warnings {
+			fmt.Fprintf(w, "  注意: %s\n", warning)
+		}
+		if n := loudnessPending(cfg, plan); n > 0 {
+			fmt.Fprintf(w, "  ラウドネス: %d ファイルが未測定のため、変換前に測定します（表示中のコマンドは測定前のものです）\n", n)
+		}
+		if plan.CoverImage != "" && plan.CoverOptions != nil {
+			fmt.Fprintf(w, "  メイン画像: 加工して埋め込みます（%s）\n", plan.CoverOptions.Signature())
+		}
+		if plan.CoverImage != "" && len(plan.CoverFiles) > 0 {
+			fmt.Fprintf(w, "  フォルダ画像: %s\n", strings.Join(plan.CoverFiles, ", "))
+		}
+		pending := plan.pendingTracks()
+		if len(pending) == 0 {
+			fmt.Fprintln(w, "  (再エンコード不要)")
+		}
+		for _, track := range pending {
+			var pictureMetadata string
+			if track.Format.UsesPictureMetadata() && track.Metadata.CoverImage != nil {
+				// 実際の変換では一時ディレクトリに作成するファイルのため、名前のみを表示する
+				pictureMetadata = dryRunPictureMetadata
+			}
+			if plan.CoverOptions != nil && track.Metadata.CoverImage != nil {
+				// 加工した画像も一時ディレクトリに作成するため、名前のみを表示する
+				coverImage := dryRunCoverImage
+				track.Metadata.CoverImage = &coverImage
+				track.Metadata.CoverReady = true
+			}
+			args := track.Format.Args(track.InputFile, track.OutputFile, track.Metadata, pictureMetadata)
+			if len(track.Chapters) > 0 {
+				for i, chapter := range track.Chapters {
+					fmt.Fprintf(w, "  チャプター %d: %s <- %s\n", i+1, chapter.Title, chapter.InputFile)
+				}
+				args = track.Format.AudiobookArgs(track.Chapters, track.OutputFile, track.Metadata, dryRunChapterMetadata)
+			}
+			fmt.Fprintf(w, "  ffmpeg %s\n", shellJoin(args))
+		}
+	}
+}
+
+// dryRunPictureMetadata はドライランで表示する、カバー画像を記述した ffmetadata ファイルの名前です。
+const dryRunPictureMetadata = "cover.ffmetadata"
+
+// dryRunChapterMetadata はドライランで表示する、チャプターを記述した ffmetadata ファイルの名前です。
+const dryRunChapterMetadata = "chapters.ffmetadata"
+
+// dryRunCoverImage はドライランで表示する、加工したメイン画像のファイルの名前です。
+const dryRunCoverImage = "cover.jpg"
+
+// printPlanTree は planNode 以下を罫線付きのツリーとして表示します。
+func printPlanTree(w io.Writer, node *planNode, indent string) {
+	children := node.sortedChildren()
+	for i, child := range children {
+		branch, next := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		if child.album == nil {
+			fmt.Fprintf(w, "%s%s%s/\n", indent, branch, child.name)
+			printPlanTree(w, child, indent+next)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s%s%s/ [%s] %s\n", indent, branch, child.name, child.album.Key, albumStatus(child.album))
+		tracks := child.album.Tracks
+		for j, track := range tracks {
+			trackBranch := "├── "
+			if j == len(tracks)-1 {
+				trackBranch = "└── "
+			}
+			status := "エンコード"
+			switch {
+			case track.Resumed:
+				status = "中断した変換から再利用"
+			case track.Skip:
+				status = "変更なし"
+			}
+			fmt.Fprintf(w, "%s%s%s%s <- %s (%s)\n", indent, next, trackBranch, track.Record.Output, track.InputFile, status)
+		}
+	}
+}
+
+// albumStatus は出力アルバムが実行時にどう扱われるかを表す文字列を返します。
+func albumStatus(plan *albumPlan) string {
+	if plan.upToDate() {
+		return "(変更なし: スキップ)"
+	}
+	if _, err := os.Stat(plan.OutputDir); err == nil {
+		if len(plan.keepFiles()) > 0 {
+			return "(既存: 変更分のみ更新)"
+		}
+		return "(既存: 再作成して置き換え)"
+	}
+	return "(新規作成)"
+}
+
+// shellJoin は引数をシェルにそのまま貼り付けられる形式で連結します。
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote は必要な場合に引数をシングルクォートで囲みます。
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+,@%", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// runDryRun は変換を行わずに変換計画を表示します。
+// HTML解析・メイン画像検索・音声ファイル検索までを実行し、ログファイルやJSONの保存、出力先のクリーンアップ、エンコードは行いません。
+func runDryRun(ctx context.Context, cfg *config.Config, opts runOptions, w io.Writer) error {
+	targetDirs, err := loadTargets(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	// 解析結果のJSONも保存しない
+	dryCfg := *cfg
+	dryCfg.Setting.SaveParsedData = false
+
+	data, notApplicableData, missingImageData, err := processDirectories(ctx, &dryCfg, targetDirs)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの処理に失敗: %w", err)
+	}
+
+	var plans []*albumPlan
+	for _, key := range getSortedKeys(data) {
+		plan, err := buildAlbumPlan(&dryCfg, key, data[key])
+		if err != nil {
+			logger.LogWarnMessage(fmt.Sprintf("[%s] の変換計画を作成できません: %v", key, err))
+			continue
+		}
+		plans = append(plans, plan)
+	}
+
+	printDryRun(w, &dryCfg, plans)
+
+	if len(notApplicableData) > 0 || len(missingImageData) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "=== 処理対象外 ===")
+		if len(notApplicableData) > 0 {
+			fmt.Fprintf(w, "HTMLなし・解析失敗: %v\n", notApplicableData)
+		}
+		if len(missingImageData) > 0 {
+			fmt.Fprintf(w, "メイン画像なし: %v\n", missingImageData)
+		}
+	}
+	return nil
+}
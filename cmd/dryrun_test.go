@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDryRunTouchesNothing(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.SaveParsedData = true
+
+	key := "d_123456"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "本編/02.flac": "two"})
+	if err := os.MkdirAll(cfg.DirSetting.HtmlDir, 0755); err != nil {
+		t.Fatalf("HTMLディレクトリの作成に失敗: %v", err)
+	}
+	htmlContent := `<!DOCTYPE html><html><head><title>【作品】テスト作品【テスト声優】(テストサークル)｜同人</title></head><body></body></html>`
+	if err := os.WriteFile(filepath.Join(cfg.DirSetting.HtmlDir, key+".html"), []byte(htmlContent), 0644); err != nil {
+		t.Fatalf("HTMLファイルの作成に失敗: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := runDryRun(context.Background(), cfg, runOptions{}, &buf); err != nil {
+		t.Fatalf("runDryRunの実行に失敗: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		"テスト声優/",
+		"テストサークル/",
+		"【d_123456】作品テスト作品/ [d_123456] (新規作成)",
+		"01.mp3 <- " + filepath.Join(cfg.DirSetting.SourceDir, key, "01.wav"),
+		"02.mp3 <- " + filepath.Join(cfg.DirSetting.SourceDir, key, "本編", "02.flac"),
+		"ffmpeg -i " + filepath.Join(cfg.DirSetting.SourceDir, key, "01.wav"),
+		"-metadata 'album=作品テスト作品'",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("出力に %q が含まれていません:\n%s", want, output)
+		}
+	}
+
+	if got := countCalls(t, callLog); got != 0 {
+		t.Errorf("ドライランでffmpegが呼び出されました: %d回", got)
+	}
+	if _, err := os.Stat(cfg.DirSetting.OutputDir); !os.IsNotExist(err) {
+		t.Error("ドライランで出力ディレクトリが作成されました")
+	}
+	if _, err := os.Stat(cfg.DirSetting.LogDir); !os.IsNotExist(err) {
+		t.Error("ドライランで解析結果のJSONが保存されました")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{"-i", "-i"},
+		{"/path/to/file.wav", "/path/to/file.wav"},
+		{"", "''"},
+		{"with space", "'with space'"},
+		{"it's", `'it'\''s'`},
+		{"【RJ01】タイトル", "'【RJ01】タイトル'"},
+	}
+
+	for _, tc := range testCases {
+		if got := shellQuote(tc.input); got != tc.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// inspectTrack は inspect サブコマンドで表示する1トラック分の情報です。
+type inspectTrack struct {
+	Input  string `json:"input"`  // 変換元ファイル
+	Output string `json:"output"` // 変換後ファイル
+	Title  string `json:"title"`  // タグに設定するトラックタイトル
+	Skip   bool   `json:"skip"`   // 前回から変更がなく再エンコードを省略するかどうか
+}
+
+// inspectResult は inspect サブコマンドの出力内容です。
+type inspectResult struct {
+	Key       string               `json:"key"`
+	HTML      string               `json:"html"`
+	Data      model.IndividualData `json:"data"`
+	OutputDir string               `json:"output_dir,omitempty"`
+	Tracks    []inspectTrack       `json:"tracks,omitempty"`
+	Problem   string               `json:"problem,omitempty"` // 変換計画を組み立てられなかった理由
+}
+
+// runInspect は1作品分の解析結果と変換計画を表示します。ファイルの書き込みは行いません。
+func runInspect(ctx context.Context, cfg *config.Config, key string, asJSON bool, w io.Writer) error {
+	inspectCfg := *cfg
+	inspectCfg.Setting.SaveParsedData = false
+
+	targetHtml := filepath.Join(cfg.DirSetting.HtmlDir, key+".html")
+	data := make(map[string]model.IndividualData)
+	var notApplicableData, missingImageData []string
+	if err := processDirectory(ctx, &inspectCfg, targetHtml, key, data, &notApplicableData, &missingImageData); err != nil {
+		return fmt.Errorf("作品 [%s] の解析に失敗: %w", key, err)
+	}
+
+	result := inspectResult{Key: key, HTML: targetHtml, Data: data[key]}
+	if _, err := os.Stat(filepath.Join(cfg.DirSetting.SourceDir, key)); err != nil {
+		result.Problem = fmt.Sprintf("音声ディレクトリにアクセスできません: %v", err)
+	} else if plan, err := buildAlbumPlan(&inspectCfg, key, result.Data); err != nil {
+		result.Problem = err.Error()
+	} else {
+		result.OutputDir = plan.OutputDir
+		for _, track := range plan.Tracks {
+			result.Tracks = append(result.Tracks, inspectTrack{Input: track.InputFile, Output: track.OutputFile, Title: track.Metadata.TrackName, Skip: track.Skip})
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("JSONの出力に失敗: %w", err)
+		}
+		return nil
+	}
+
+	printInspectResult(w, result)
+	return nil
+}
+
+// printInspectResult は inspect の結果を人が読みやすい形式で出力します。
+func printInspectResult(w io.Writer, result inspectResult) {
+	fmt.Fprintf(w, "作品キー:     %s\n", result.Key)
+	fmt.Fprintf(w, "HTML:         %s\n", result.HTML)
+	fmt.Fprintf(w, "タイトル:     %s\n", result.Data.AlbumTitle)
+	fmt.Fprintf(w, "声優:         %s\n", result.Data.Actor)
+	fmt.Fprintf(w, "ブランド:     %s\n", result.Data.Brand)
+	if result.Data.MainImage != "" {
+		fmt.Fprintf(w, "メイン画像:   %s\n", result.Data.MainImage)
+	}
+	if len(result.Data.TrackList) > 0 {
+		fmt.Fprintln(w, "トラック一覧:")
+		for i, track := range result.Data.TrackList {
+			fmt.Fprintf(w, "  %2d. %s (%s)\n", i+1, track.TrackTitle, track.TrackDuration)
+		}
+	}
+	if len(result.Data.Additional) > 0 {
+		fmt.Fprintln(w, "追加情報:")
+		names := make([]string, 0, len(result.Data.Additional))
+		for name := range result.Data.Additional {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s: %s\n", name, result.Data.Additional[name])
+		}
+	}
+
+	if len(result.Data.Sources) > 0 {
+		fmt.Fprintln(w, "変換元の音声:")
+		for _, source := range result.Data.Sources {
+			fmt.Fprintf(w, "  %s: %s\n", source.File, formatSource(source))
+		}
+	}
+	for _, warning := range result.Data.Warnings {
+		fmt.Fprintf(w, "注意:         %s\n", warning)
+	}
+
+	if result.Problem != "" {
+		fmt.Fprintf(w, "変換計画:     作成できません (%s)\n", result.Problem)
+		return
+	}
+	fmt.Fprintf(w, "出力先:       %s\n", result.OutputDir)
+	fmt.Fprintln(w, "変換対象:")
+	for _, track := range result.Tracks {
+		status := "変換"
+		if track.Skip {
+			status = "変更なし"
+		}
+		fmt.Fprintf(w, "  [%s] %s -> %s (%s)\n", status, track.Input, filepath.Base(track.Output), track.Title)
+	}
+}
+
+// runVerify は出力済みのアルバムが現在の入力・設定と一致しているかを確認します。
+// 再エンコードが必要な作品がある場合は partialFailureError を返し、一致した作品が1つもない場合は処理全体の失敗とします。
+func runVerify(ctx context.Context, cfg *config.Config, opts runOptions, w io.Writer) error {
+	verifyCfg := *cfg
+	verifyCfg.Setting.SaveParsedData = false
+	verifyCfg.Setting.Incremental = true
+
+	targetDirs, err := loadTargets(&verifyCfg, opts)
+	if err != nil {
+		return err
+	}
+
+	data, notApplicableData, missingImageData, err := processDirectories(ctx, &verifyCfg, targetDirs)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの処理に失敗: %w", err)
+	}
+
+	problems := len(notApplicableData) + len(missingImageData)
+	verified := 0
+	for _, key := range getSortedKeys(data) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		plan, err := buildAlbumPlan(&verifyCfg, key, data[key])
+		if err != nil {
+			fmt.Fprintf(w, "NG   %s: %v\n", key, err)
+			problems++
+			continue
+		}
+
+		switch {
+		case plan.Previous == nil:
+			fmt.Fprintf(w, "NG   %s: 変換記録がありません（未変換） %s\n", key, plan.OutputDir)
+			problems++
+		case plan.upToDate():
+			fmt.Fprintf(w, "OK   %s\n", key)
+			verified++
+		default:
+			pending := plan.pendingTracks()
+			fmt.Fprintf(w, "NG   %s: %d トラックの再エンコードが必要です\n", key, len(pending))
+			for _, track := range pending {
+				fmt.Fprintf(w, "       - %s\n", track.Record.Output)
+			}
+			if len(pending) == 0 {
+				fmt.Fprintln(w, "       - 変換記録とトラック構成が一致しません")
+			}
+			problems++
+		}
+	}
+	for _, key := range notApplicableData {
+		fmt.Fprintf(w, "NG   %s: HTMLファイルが存在しないか、解析に失敗しました\n", key)
+	}
+	for _, key := range missingImageData {
+		fmt.Fprintf(w, "NG   %s: メイン画像が見つかりません\n", key)
+	}
+
+	if problems == 0 {
+		return nil
+	}
+	if verified == 0 {
+		return fmt.Errorf("すべての作品（%d 作品）で問題が見つかりました", problems)
+	}
+	return &partialFailureError{Failed: problems, Total: verified + problems}
+}
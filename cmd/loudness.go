@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/loudness"
+	"github.com/kkryama/dls-encoder/internal/manifest"
+	"github.com/kkryama/dls-encoder/internal/probe"
+	"github.com/kkryama/dls-encoder/internal/worker"
+)
+
+// loudnessTarget は設定された正規化の目標値を返します。
+func loudnessTarget(cfg *config.Config) loudness.Target {
+	return loudness.Target{
+		Integrated: cfg.Setting.LoudnessTargetLUFS(),
+		TruePeak:   cfg.Setting.LoudnessTruePeakDB(),
+	}
+}
+
+// applyLoudness は loudness の設定とトラックごとの測定結果を変換計画に反映します。
+// 測定結果がないトラックは、変換元が前回から変わっていなければ変換記録の測定結果を再利用します。
+// 何度呼び出しても同じ結果になるため、測定後に改めて呼び出して反映し直せます。
+func applyLoudness(cfg *config.Config, plan *albumPlan) {
+	mode := cfg.Setting.LoudnessMode()
+	if mode == config.LoudnessOff {
+		return
+	}
+
+	target := loudnessTarget(cfg)
+	for i := range plan.Tracks {
+		track := &plan.Tracks[i]
+		if track.Loudness == nil {
+			track.Loudness = cachedLoudness(plan.Previous, track.Record, mode, target)
+		}
+		track.Record.Loudness = track.Loudness
+	}
+
+	switch mode {
+	case config.LoudnessLoudnorm:
+		// 未測定のトラックは1パスの指定になる（ドライランでの表示のみに使用し、変換前に測定する）
+		for i := range plan.Tracks {
+			track := &plan.Tracks[i]
+			sampleRate := 0
+			if track.Source != nil {
+				sampleRate = track.Source.SampleRate
+			}
+			track.Format = track.Format.WithFilter(target.Filter(track.Loudness), sampleRate)
+			track.Record.Encoder = track.Format.Signature()
+		}
+	case config.LoudnessReplayGain:
+		measurements := make([]loudness.Measurement, 0, len(plan.Tracks))
+		for _, track := range plan.Tracks {
+			if track.Loudness == nil {
+				// アルバムのゲインは全トラックの測定結果から求めるため、未測定のトラックがある間はタグを設定しない
+				return
+			}
+			measurements = append(measurements, *track.Loudness)
+		}
+		album := loudness.Album(measurements)
+		for i := range plan.Tracks {
+			track := &plan.Tracks[i]
+			track.Metadata.Extra = nil
+			for _, tag := range loudness.ReplayGain(*track.Loudness, album) {
+				track.Metadata.Extra = append(track.Metadata.Extra, audioconverter.Tag{Name: tag.Name, Value: tag.Value})
+			}
+			track.Record.Metadata = track.Metadata.TagMap()
+		}
+	}
+}
+
+// cachedLoudness は前回の変換記録から、変換元が同じトラックの測定結果を返します。
+// 目標値は loudnorm の2パス目に渡すため loudnorm の場合のみ比較し、固定の基準を使う replaygain では比較しません。
+func cachedLoudness(previous *manifest.Manifest, record manifest.Track, mode string, target loudness.Target) *loudness.Measurement {
+	prev, ok := previous.Find(record.Output)
+	if !ok || prev.Loudness == nil || !prev.Source.Equal(record.Source) {
+		return nil
+	}
+	if mode == config.LoudnessLoudnorm && prev.Loudness.Target != target {
+		return nil
+	}
+	return prev.Loudness
+}
+
+// measureLoudness は loudness が有効な場合に、測定結果のないトラックのラウドネスをワーカープールで並列に測定し、変換計画に反映します。
+// 測定に失敗した作品の作品キーとエラーを返します。continue_on_error が無効な場合は、失敗した時点で残りの測定をキャンセルします。
+func measureLoudness(ctx context.Context, cfg *config.Config, plans []*albumPlan) map[string]error {
+	if cfg.Setting.LoudnessMode() == config.LoudnessOff {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	target := loudnessTarget(cfg)
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	var jobs []worker.Job
+	for _, plan := range plans {
+		count := 0
+		for i := range plan.Tracks {
+			track := &plan.Tracks[i]
+			if track.Loudness != nil {
+				continue
+			}
+			count++
+			jobs = append(jobs, func(ctx context.Context) error {
+				m, err := loudness.Measure(ctx, track.InputFile, target)
+				if err != nil {
+					mu.Lock()
+					if errs[plan.Key] == nil {
+						errs[plan.Key] = err
+					}
+					mu.Unlock()
+					if !cfg.Setting.ContinueOnError {
+						cancel()
+					}
+					return err
+				}
+				m.Duration = sourceDuration(ctx, *track)
+				track.Loudness = &m
+				return nil
+			})
+		}
+		if count > 0 {
+			logger.LogMessage(fmt.Sprintf("[%s] の %d ファイルのラウドネスを測定します", plan.Key, count))
+		}
+	}
+
+	workers := cfg.Setting.WorkerCount()
+	logger.LogDebugEvent("measureLoudness_called", map[string]interface{}{
+		"albumCount": len(plans),
+		"jobCount":   len(jobs),
+		"workers":    workers,
+		"mode":       cfg.Setting.LoudnessMode(),
+		"target":     target.Integrated,
+		"truePeak":   target.TruePeak,
+	})
+	worker.Run(ctx, workers, jobs)
+
+	for _, plan := range plans {
+		if errs[plan.Key] != nil {
+			continue
+		}
+		for _, track := range plan.Tracks {
+			if track.Loudness == nil {
+				errs[plan.Key] = fmt.Errorf("ラウドネスの測定が中断されました: %w", context.Cause(ctx))
+				break
+			}
+		}
+		if errs[plan.Key] != nil {
+			continue
+		}
+		applyLoudness(cfg, plan)
+		applyManifest(cfg, plan)
+	}
+	return errs
+}
+
+// sourceDuration は変換元の再生時間（秒）を返します。ffprobe の調査結果がない場合は改めて取得し、取得できない場合は0を返します。
+func sourceDuration(ctx context.Context, track trackPlan) float64 {
+	if track.Source != nil && track.Source.Duration > 0 {
+		return track.Source.Duration
+	}
+	duration, err := probe.Duration(ctx, track.InputFile)
+	if err != nil {
+		return 0
+	}
+	return duration.Seconds()
+}
+
+// loudnessPending は変換前にラウドネスを測定する必要があるトラックの数を返します。
+func loudnessPending(cfg *config.Config, plan *albumPlan) int {
+	if cfg.Setting.LoudnessMode() == config.LoudnessOff {
+		return 0
+	}
+	count := 0
+	for _, track := range plan.Tracks {
+		if track.Loudness == nil {
+			count++
+		}
+	}
+	return count
+}
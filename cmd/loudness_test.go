@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// encodeCalls はダミー ffmpeg の呼び出しのうち、ラウドネスの測定を除いたエンコードの引数を返します。
+func encodeCalls(t *testing.T, callLog string) (encodes []string, measures int) {
+	t.Helper()
+
+	content, err := os.ReadFile(callLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if strings.Contains(line, "print_format=json") {
+			measures++
+			continue
+		}
+		encodes = append(encodes, line)
+	}
+	return encodes, measures
+}
+
+func TestConvertFilesReplayGain(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Loudness = config.LoudnessReplayGain
+	cfg.Setting.LoudnessTarget = -14 // loudnorm の目標値は ReplayGain の基準に影響しない
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テスト作品"}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+
+	encodes, measures := encodeCalls(t, callLog)
+	if measures != 2 || len(encodes) != 2 {
+		t.Fatalf("測定とエンコードの回数: got %d, %d, want 2, 2", measures, len(encodes))
+	}
+	// ReplayGain 2.0 の基準 -18 LUFS に対して -23 LUFS なので +5 dB、ピークは -6 dBTP
+	for _, want := range []string{"REPLAYGAIN_TRACK_GAIN=5.00 dB", "REPLAYGAIN_TRACK_PEAK=0.501187", "REPLAYGAIN_ALBUM_GAIN=5.00 dB", "REPLAYGAIN_ALBUM_PEAK=0.501187"} {
+		if !strings.Contains(encodes[0], want) {
+			t.Errorf("ReplayGainのタグ %q が設定されていません: %s", want, encodes[0])
+		}
+	}
+	if strings.Contains(encodes[0], "loudnorm") {
+		t.Errorf("replaygain では音量を変更しない: %s", encodes[0])
+	}
+
+	// 変換元が変わらなければ前回の測定結果を再利用し、再エンコードもしない
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 4 {
+		t.Errorf("再実行時のffmpegの呼び出し回数: got %d, want 0", got-4)
+	}
+
+	// loudness_target は ReplayGain のゲインに影響しないため、変更しても測定し直さない
+	cfg.Setting.LoudnessTarget = -16
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("目標値の変更後の再実行に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 4 {
+		t.Errorf("目標値の変更後のffmpegの呼び出し回数: got %d, want 0", got-4)
+	}
+
+	// トラックを追加した場合は追加分のみ測定する。既存のトラックもトラック数（"n/N" の N）が変わるため作り直す
+	writeSourceFiles(t, cfg, key, map[string]string{"03.wav": "three"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("トラック追加後の変換に失敗: %v", err)
+	}
+	encodes, measures = encodeCalls(t, callLog)
+	if measures != 3 || len(encodes) != 5 {
+		t.Errorf("トラック追加後の測定とエンコードの回数: got %d, %d, want 3, 5", measures, len(encodes))
+	}
+}
+
+func TestConvertFilesLoudnorm(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Loudness = config.LoudnessLoudnorm
+	cfg.Setting.LoudnessTarget = -16
+	cfg.Setting.OutputFormat = "flac"
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+	if err := convertFiles(ctx, cfg, key, model.IndividualData{AlbumTitle: "テスト作品"}); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+
+	encodes, measures := encodeCalls(t, callLog)
+	if measures != 1 || len(encodes) != 1 {
+		t.Fatalf("測定とエンコードの回数: got %d, %d, want 1, 1", measures, len(encodes))
+	}
+	want := "-af loudnorm=I=-16:TP=-1:LRA=13:measured_I=-23:measured_TP=-6:measured_LRA=12.3:measured_thresh=-33.5:offset=0.2:linear=true"
+	if !strings.Contains(encodes[0], want) {
+		t.Errorf("2パス目の loudnorm が指定されていません:\ngot  %s\nwant %s", encodes[0], want)
+	}
+	// loudnorm は 192kHz で出力するため、FLAC でもサンプリングレートを指定する
+	if !strings.Contains(encodes[0], "-ar 48000") {
+		t.Errorf("FLACでもサンプリングレートを指定すべき: %s", encodes[0])
+	}
+
+	// 目標値を変えると測定し直す
+	cfg.Setting.LoudnessTarget = -20
+	if err := convertFiles(ctx, cfg, key, model.IndividualData{AlbumTitle: "テスト作品"}); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if _, measures := encodeCalls(t, callLog); measures != 2 {
+		t.Errorf("目標値の変更後の測定回数: got %d, want 2", measures)
+	}
+}
+
+func TestConvertFilesLoudnessMeasureFailure(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Loudness = config.LoudnessReplayGain
+
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01_broken.wav": "one", "02.wav": "two"})
+	err := convertFiles(context.Background(), cfg, key, model.IndividualData{AlbumTitle: "テスト作品"})
+	if err == nil {
+		t.Fatal("測定に失敗した場合はエラーになるべき")
+	}
+	if got := classifyFailure(err); got != reasonFFmpegFailure {
+		t.Errorf("測定の失敗の分類: got %s, want %s", got, reasonFFmpegFailure)
+	}
+}
+
+func TestValidateEncodingReplayGain(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Loudness = config.LoudnessReplayGain
+	cfg.Setting.OutputFormat = "m4a"
+	if err := validateEncoding(cfg); err == nil {
+		t.Error("M4AでReplayGainを指定した場合はエラーになるべき")
+	}
+	cfg.Setting.Loudness = config.LoudnessLoudnorm
+	if err := validateEncoding(cfg); err != nil {
+		t.Errorf("M4Aでloudnormを指定した場合はエラーにならない: %v", err)
+	}
+}
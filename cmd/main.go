@@ -4,12 +4,10 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -18,18 +16,19 @@ import (
 
 	"github.com/kkryama/dls-encoder/internal/audioconverter"
 	"github.com/kkryama/dls-encoder/internal/config"
-	"github.com/kkryama/dls-encoder/internal/generator"
 	"github.com/kkryama/dls-encoder/internal/logger"
 	"github.com/kkryama/dls-encoder/internal/model"
 	"github.com/kkryama/dls-encoder/internal/parser"
+	"github.com/kkryama/dls-encoder/internal/report"
 	"github.com/kkryama/dls-encoder/internal/storage"
 )
 
 const (
-	logFileFormat   = "results_%s.log"
-	timeStampFormat = "20060102_150405"
-	mp3Extension    = ".mp3"
-	version         = "20251209-073257" // 作業日時で更新
+	logFilePrefix    = "results_"
+	reportFilePrefix = "report_"
+	logFileFormat    = logFilePrefix + "%s.log"
+	timeStampFormat  = "20060102_150405"
+	version          = "20251209-073257" // 作業日時で更新
 )
 
 // truncateAlbumTitle はアルバムタイトルが長すぎる場合に省略します。
@@ -60,11 +59,10 @@ func sanitizeDirName(name string, cfg *config.Config) string {
 }
 
 // main はプログラムのエントリーポイントです。
-// エラーが発生した場合は、エラーメッセージを表示して終了します。
+// サブコマンドを実行し、結果に応じた終了コードで終了します。
 func main() {
 	// コンテキストとシグナルハンドリングの設定
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
 	// シグナルハンドラーでグレースフルシャットダウン
 	sigChan := make(chan os.Signal, 1)
@@ -75,43 +73,26 @@ func main() {
 		cancel()
 	}()
 
-	// フラグの定義
-	createHTML := flag.Bool("create-html", false, "HTMLファイルを対話形式で作成します")
-	flag.Parse()
-
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		fmt.Printf("設定ファイルの読み込みに失敗: %v\n", err)
-		os.Exit(1)
-	}
-
-	// 設定値のバリデーション
-	if err := cfg.Validate(); err != nil {
-		fmt.Printf("設定値の検証に失敗: %v\n", err)
-		os.Exit(1)
-	}
-
-	if *createHTML {
-		// HTML生成モード
-		if err := generator.InteractiveHTMLGenerator(cfg.DirSetting.HtmlDir, cfg.DirSetting.ImageDir); err != nil {
-			fmt.Printf("HTMLファイルの生成に失敗しました: %v\n", err)
-			os.Exit(1)
-		}
-		return
-	}
+	code := runCLI(ctx, os.Args[1:])
+	cancel()
+	os.Exit(code)
+}
 
-	// 通常のエンコード処理
-	if err := runWithContext(ctx, cfg); err != nil {
-		fmt.Printf("エラー: %v\n", err)
-		os.Exit(1)
-	}
+// runOptions はコマンドライン引数で指定された実行オプションです。
+type runOptions struct {
+	DryRun  bool                   // 変換計画の表示のみ行うかどうか
+	Targets storage.TargetSelector // 処理対象の絞り込み条件（空の場合は source_dir 内のすべて）
 }
 
 // runWithContext はコンテキストを使用して変換処理の全体フローを制御します。
 // 依存関係の確認、ログの初期化、HTMLの解析、MP3変換を実行します。
-func runWithContext(ctx context.Context, cfg *config.Config) error {
+func runWithContext(ctx context.Context, cfg *config.Config, opts runOptions) error {
 	logger.LogMessage("dls-encoder version: " + version)
 
+	if opts.DryRun {
+		return runDryRun(ctx, cfg, opts, os.Stdout)
+	}
+
 	if err := validateDependencies(); err != nil {
 		return fmt.Errorf("依存関係の確認に失敗: %w", err)
 	}
@@ -128,27 +109,93 @@ func runWithContext(ctx context.Context, cfg *config.Config) error {
 		}
 	}()
 
+	cleanupInterruptedOutputs(cfg)
+
+	targetDirs, err := loadTargets(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	data, notApplicableData, missingImageData, err := processDirectories(ctx, cfg, targetDirs)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの処理に失敗: %w", err)
+	}
+
+	rep := report.New(version, "encode")
+	err = handleConversion(ctx, cfg, data, notApplicableData, missingImageData, rep)
+	saveReport(cfg, rep, logFile, err)
+	if err != nil {
+		return fmt.Errorf("変換処理に失敗: %w", err)
+	}
+
+	logger.LogMessage("処理が正常に終了しました。")
+	return nil
+}
+
+// loadTargets は source_dir 内の対象ディレクトリを読み込み、指定された作品キーで絞り込みます。
+func loadTargets(cfg *config.Config, opts runOptions) ([]string, error) {
 	targetDirs, err := storage.LoadTargets(cfg.DirSetting.SourceDir)
 	if err != nil {
-		return fmt.Errorf("対象ディレクトリ一覧の読み込みに失敗: %w", err)
+		return nil, fmt.Errorf("対象ディレクトリ一覧の読み込みに失敗: %w", err)
+	}
+
+	if !opts.Targets.Empty() {
+		selected, missing := opts.Targets.Select(targetDirs)
+		for _, key := range missing {
+			logger.LogWarnMessage(fmt.Sprintf("指定された作品キー [%s] は %s に存在しません", key, cfg.DirSetting.SourceDir))
+		}
+		targetDirs = selected
 	}
+
 	logger.LogMessage(fmt.Sprintf("対象ディレクトリ: %v", targetDirs))
 	logger.LogDebugEvent("target_directories_loaded", map[string]interface{}{
 		"directories": targetDirs,
 		"count":       len(targetDirs),
+		"only":        opts.Targets.Only,
+		"match":       opts.Targets.Match,
+		"exclude":     opts.Targets.Exclude,
 	})
+	return targetDirs, nil
+}
 
-	data, notApplicableData, missingImageData, err := processDirectories(ctx, cfg, targetDirs)
+// runParse はHTMLの解析結果をJSONとして保存します。音声ファイルの変換は行いません。
+func runParse(ctx context.Context, cfg *config.Config, opts runOptions) error {
+	logger.LogMessage("dls-encoder version: " + version)
+
+	logFile, err := setupLogging(cfg.DirSetting.LogDir, cfg.Setting.Debug)
 	if err != nil {
-		return fmt.Errorf("ディレクトリの処理に失敗: %w", err)
+		return fmt.Errorf("ログ設定の初期化に失敗: %w", err)
 	}
+	defer func() {
+		if logFile != nil {
+			if err := logFile.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "ログファイルのクローズでエラー: %v\n", err)
+			}
+		}
+	}()
 
-	if err := handleConversion(ctx, cfg, data, notApplicableData, missingImageData); err != nil {
-		return fmt.Errorf("変換処理に失敗: %w", err)
+	targetDirs, err := loadTargets(cfg, opts)
+	if err != nil {
+		return err
 	}
 
-	logger.LogMessage("処理が正常に終了しました。")
-	return nil
+	parseCfg := *cfg
+	parseCfg.Setting.SaveParsedData = true
+	data, notApplicableData, missingImageData, err := processDirectories(ctx, &parseCfg, targetDirs)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの処理に失敗: %w", err)
+	}
+	logger.LogMessage(fmt.Sprintf("解析結果を %s に保存しました: %v", cfg.DirSetting.LogDir, getSortedKeys(data)))
+
+	summary := &runSummary{Succeeded: getSortedKeys(data)}
+	for _, key := range notApplicableData {
+		summary.addFailure(key, reasonParseError, "HTMLファイルが存在しないか、解析に失敗しました")
+	}
+	for _, key := range missingImageData {
+		summary.addFailure(key, reasonMissingImage, "メイン画像が見つかりません")
+	}
+	summary.print()
+	return summary.err()
 }
 
 // validateDependencies はffmpegコマンドが利用可能かを確認します。
@@ -195,7 +242,7 @@ func processDirectories(ctx context.Context, cfg *config.Config, targetDirs []st
 		key := filepath.Base(targetDir)
 		targetHtml := filepath.Join(cfg.DirSetting.HtmlDir, targetDir+".html")
 
-		if err := processDirectory(cfg, targetHtml, key, data, &notApplicableData, &missingImageData); err != nil {
+		if err := processDirectory(ctx, cfg, targetHtml, key, data, &notApplicableData, &missingImageData); err != nil {
 			logger.LogWarnEvent("directory_processing_error", map[string]interface{}{
 				"error":      err.Error(),
 				"key":        key,
@@ -216,9 +263,10 @@ func processDirectories(ctx context.Context, cfg *config.Config, targetDirs []st
 }
 
 // processDirectory は単一のディレクトリのHTMLファイルを処理します。
-// HTMLファイルの存在確認、解析、メイン画像の処理を行います。
+// HTMLファイルの存在確認、解析、メイン画像の処理と、ffprobe による変換元の音声ファイルの調査、
+// トラック一覧と音声ファイルの対応付けを行います。
 // エラー発生時は処理対象外リストに追加します。
-func processDirectory(cfg *config.Config, targetHtml, key string, data map[string]model.IndividualData, notApplicableData, missingImageData *[]string) error {
+func processDirectory(ctx context.Context, cfg *config.Config, targetHtml, key string, data map[string]model.IndividualData, notApplicableData, missingImageData *[]string) error {
 	logger.LogDebugEvent("processDirectory_called", map[string]interface{}{
 		"targetHtml": targetHtml,
 		"key":        key,
@@ -241,6 +289,11 @@ func processDirectory(cfg *config.Config, targetHtml, key string, data map[strin
 		}
 	}
 
+	// 変換対象の音声ファイルは作品ごとに1回だけ選択し、調査・対応付け・変換計画で共有する
+	selection := audioconverter.SelectAudioFiles(filepath.Join(cfg.DirSetting.SourceDir, key), cfg)
+	individualData.Selection = &selection
+	probeSources(ctx, cfg, key, &individualData)
+	matchTrackList(cfg, key, &individualData)
 	data[key] = individualData
 	return nil
 }
@@ -277,7 +330,8 @@ func processMainImage(imageDir string, key string, individualData *model.Individ
 
 // handleConversion は音声ファイルのMP3変換を実行します。
 // 設定に基づいて変換処理の実行可否を判断し、処理結果をログ出力します。
-func handleConversion(ctx context.Context, cfg *config.Config, data map[string]model.IndividualData, notApplicableData, missingImageData []string) error {
+// rep が nil でない場合は、作品ごとの処理結果を rep に記録します。
+func handleConversion(ctx context.Context, cfg *config.Config, data map[string]model.IndividualData, notApplicableData, missingImageData []string, rep *report.Report) error {
 	logger.LogDebugEvent("handleConversion_called", map[string]interface{}{
 		"dataCount":          len(data),
 		"notApplicableCount": len(notApplicableData),
@@ -285,7 +339,18 @@ func handleConversion(ctx context.Context, cfg *config.Config, data map[string]m
 		"conversionEnabled":  cfg.Setting.Convert,
 	})
 
+	for _, key := range notApplicableData {
+		rep.Add(failedWork(key, nil, reasonParseError, "HTMLファイルが存在しないか、解析に失敗しました"))
+	}
+	for _, key := range missingImageData {
+		rep.Add(failedWork(key, nil, reasonMissingImage, "メイン画像が見つかりません"))
+	}
+
 	if !cfg.Setting.Convert {
+		for _, key := range getSortedKeys(data) {
+			value := data[key]
+			rep.Add(report.Work{Key: key, Status: report.StatusNotConverted, Metadata: &value})
+		}
 		logger.LogMessage("変換処理がOFFに設定されているため、処理を終了します")
 		return nil
 	}
@@ -307,142 +372,123 @@ func handleConversion(ctx context.Context, cfg *config.Config, data map[string]m
 		})
 	}
 
+	summary := &runSummary{}
+	plans := make([]*albumPlan, 0, len(keys))
+	planErrs := make(map[string]error)
 	for _, key := range keys {
-		if err := convertFiles(ctx, cfg, key, data[key]); err != nil {
-			return fmt.Errorf("[%s]の変換に失敗: %w", key, err)
+		plan, err := buildAlbumPlan(cfg, key, data[key])
+		if err != nil {
+			value := data[key]
+			rep.Add(failedWork(key, &value, classifyFailure(err), err.Error()))
+			summary.addError(key, err)
+			planErrs[key] = err
+			if !cfg.Setting.ContinueOnError {
+				// 以降の作品には着手しないが、計画済みの作品は変換してそれぞれの結果を記録する
+				break
+			}
+			continue
 		}
+		plans = append(plans, plan)
 	}
 
-	printResults(cfg, notApplicableData, missingImageData)
-	return nil
-}
-
-// getSortedKeys はマップのキーをソートしたスライスを返します。
-func getSortedKeys(data map[string]model.IndividualData) []string {
-	keys := make([]string, 0, len(data))
-	for k := range data {
-		keys = append(keys, k)
+	measureErrs := measureLoudness(ctx, cfg, plans)
+	measured := plans[:0]
+	for _, plan := range plans {
+		err := measureErrs[plan.Key]
+		if err == nil {
+			measured = append(measured, plan)
+			continue
+		}
+		rep.Add(failedWork(plan.Key, &plan.Data, classifyFailure(err), err.Error()))
+		summary.addError(plan.Key, err)
+	}
+	plans = measured
+	if !cfg.Setting.ContinueOnError {
+		// 失敗による測定のキャンセルより、失敗そのものを優先して返す
+		if firstConversionError(keys, measureErrs) != nil {
+			return firstConversionError(keys, mergeErrors(planErrs, measureErrs))
+		}
 	}
-	sort.Strings(keys)
-	return keys
-}
-
-// convertFiles は指定されたディレクトリ内の音声ファイルをMP3に変換します。
-// 出力先の準備、メタデータの設定、ファイルの変換を行います。
-func convertFiles(ctx context.Context, cfg *config.Config, key string, value model.IndividualData) error {
-	logger.LogDebugEvent("convertFiles_called", map[string]interface{}{
-		"key":        key,
-		"albumTitle": value.AlbumTitle,
-		"actor":      value.Actor,
-		"brand":      value.Brand,
-		"sourceDir":  cfg.DirSetting.SourceDir,
-		"outputDir":  cfg.DirSetting.OutputDir,
-	})
 
-	targetDir := filepath.Join(cfg.DirSetting.SourceDir, key)
-	audioFiles := audioconverter.FindAudioFiles(targetDir, cfg)
-
-	if len(audioFiles) == 0 {
-		return fmt.Errorf("音声ファイルが見つかりません: %s", targetDir)
+	results := convertAlbums(ctx, cfg, plans)
+	for _, plan := range plans {
+		rep.Add(albumWork(plan, results[plan.Key]))
 	}
-
-	// Actor が複数の場合、省略してディレクトリ名を短くする
-	actors := splitActorNames(value.Actor)
-	if len(actors) == 0 {
-		trimmed := strings.TrimSpace(value.Actor)
-		if trimmed != "" {
-			actors = []string{trimmed}
+	if !cfg.Setting.ContinueOnError {
+		if err := firstConversionError(keys, mergeErrors(planErrs, conversionErrors(results))); err != nil {
+			return err
 		}
+		printResults(cfg, notApplicableData, missingImageData)
+		return nil
 	}
-	if len(actors) > 2 {
-		actors = append(actors[:2], "他")
-	}
-	actorDir := sanitizeDirName(strings.Join(actors, "・"), cfg)
-
-	shortAlbumTitle := sanitizeDirName(truncateAlbumTitle(value.AlbumTitle), cfg)
 
-	mp3OutputDir := filepath.Join(cfg.DirSetting.OutputDir, cfg.DirSetting.Mp3OutputDirName, actorDir, sanitizeDirName(value.Brand, cfg), fmt.Sprintf("【%s】%s", key, shortAlbumTitle))
-
-	if err := prepareOutputDirectory(mp3OutputDir); err != nil {
-		return err
+	for _, plan := range plans {
+		if err := results[plan.Key].Err; err != nil {
+			summary.addError(plan.Key, err)
+			continue
+		}
+		summary.Succeeded = append(summary.Succeeded, plan.Key)
 	}
-
-	var coverImage *string
-	if value.MainImage != "" {
-		coverImage = &value.MainImage
+	for _, key := range notApplicableData {
+		summary.addFailure(key, reasonParseError, "HTMLファイルが存在しないか、解析に失敗しました")
 	}
-
-	baseMetaData := audioconverter.MP3Metadata{
-		Artist:      value.Actor,
-		AlbumArtist: value.Brand,
-		AlbumTitle:  value.AlbumTitle,
-		CoverImage:  coverImage,
+	for _, key := range missingImageData {
+		summary.addFailure(key, reasonMissingImage, "メイン画像が見つかりません")
 	}
 
-	// MP3メタデータのデバッグログを出力
-	logger.LogDebugEvent("mp3_metadata_prepared", map[string]interface{}{
-		"key":        key,
-		"coverImage": value.MainImage,
-		"artist":     value.Actor,
-		"albumTitle": value.AlbumTitle,
-	})
+	printResults(cfg, notApplicableData, missingImageData)
+	summary.print()
 
-	for _, inputFile := range audioFiles {
-		if err := convertSingleFile(ctx, inputFile, mp3OutputDir, baseMetaData); err != nil {
-			return fmt.Errorf("ファイル変換に失敗: %w", err)
-		}
-		logger.LogMessage(fmt.Sprintf("[%s] のファイル [%s] のMP3変換が完了", key, path.Base(inputFile)))
-		logger.LogDebugEvent("mp3_conversion_completed", map[string]interface{}{
-			"key":       key,
-			"file":      path.Base(inputFile),
-			"inputPath": inputFile,
-		})
+	// シグナルによる中断は個別の作品の失敗ではなく、処理全体の失敗として扱う
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("変換処理が中断されました: %w", err)
 	}
-
-	return nil
+	return summary.err()
 }
 
-// prepareOutputDirectory は出力ディレクトリの準備を行います。
-// ディレクトリが存在する場合はクリーンアップを、存在しない場合は作成を行います。
-func prepareOutputDirectory(dir string) error {
-	exists, err := audioconverter.DirExists(dir)
-	if err != nil {
-		return fmt.Errorf("ディレクトリの確認に失敗: %w", err)
-	}
-
-	if exists {
-		if err := audioconverter.CleanUp(dir); err != nil {
-			return fmt.Errorf("ディレクトリのクリーンアップに失敗: %w", err)
-		}
-	} else {
-		if err := audioconverter.EnsureDirExists(dir); err != nil {
-			return fmt.Errorf("ディレクトリの作成に失敗: %w", err)
+// mergeErrors は作品キーごとのエラーを1つのマップにまとめます。
+func mergeErrors(errs ...map[string]error) map[string]error {
+	merged := make(map[string]error)
+	for _, m := range errs {
+		for key, err := range m {
+			if err != nil {
+				merged[key] = err
+			}
 		}
 	}
+	return merged
+}
 
-	return nil
+// getSortedKeys はマップのキーをソートしたスライスを返します。
+func getSortedKeys(data map[string]model.IndividualData) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// convertSingleFile は単一の音声ファイルをMP3に変換します。
-// 出力パスの生成、メタデータの設定、ファイルの変換を行います。
-func convertSingleFile(ctx context.Context, inputFile, outputDir string, baseMetaData audioconverter.MP3Metadata) error {
+// convertSingleFile は単一の音声ファイルを変換計画の出力形式に変換します。opts で変換の進捗を受け取れます。
+// 変換計画に従って出力パスとメタデータを設定し、ファイルの変換を行います。
+func convertSingleFile(ctx context.Context, track trackPlan, opts audioconverter.ConvertOptions) error {
 	logger.LogDebugEvent("convertSingleFile_called", map[string]interface{}{
-		"inputFile":  inputFile,
-		"outputDir":  outputDir,
-		"artist":     baseMetaData.Artist,
-		"albumTitle": baseMetaData.AlbumTitle,
-		"coverImage": baseMetaData.CoverImage,
+		"inputFile":  track.InputFile,
+		"outputFile": track.OutputFile,
+		"artist":     track.Metadata.Artist,
+		"albumTitle": track.Metadata.AlbumTitle,
+		"coverImage": track.Metadata.CoverImage,
+		"format":     track.Format.Name,
 	})
 
-	name := path.Base(inputFile)
-	nameWithoutExt := strings.TrimSuffix(name, filepath.Ext(name))
-	mp3OutputPath := filepath.Join(outputDir, nameWithoutExt+mp3Extension)
-
-	metaData := baseMetaData
-	metaData.TrackName = nameWithoutExt
-
-	if err := audioconverter.ConvertFileToMp3WithContext(ctx, inputFile, mp3OutputPath, metaData); err != nil {
-		return fmt.Errorf("MP3変換に失敗: %w", err)
+	var err error
+	if len(track.Chapters) > 0 {
+		err = audioconverter.ConvertAudiobook(ctx, track.Chapters, track.OutputFile, track.Metadata, track.Format, opts)
+	} else {
+		err = audioconverter.ConvertFile(ctx, track.InputFile, track.OutputFile, track.Metadata, track.Format, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("%s変換に失敗: %w", strings.ToUpper(track.Format.Name), err)
 	}
 
 	return nil
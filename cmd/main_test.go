@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
 	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"github.com/kkryama/dls-encoder/internal/report"
 )
 
 func TestProcessDirectoriesBuildsHtmlPathWithJoin(t *testing.T) {
@@ -59,6 +65,40 @@ func TestProcessDirectoriesBuildsHtmlPathWithJoin(t *testing.T) {
 	}
 }
 
+func TestProcessDirectoriesSelectsAudioFilesOnce(t *testing.T) {
+	ctx := context.Background()
+	cfg := newConversionTestConfig(t)
+
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "01.mp3": "one", "02.wav": "two"})
+	if err := os.MkdirAll(cfg.DirSetting.HtmlDir, 0755); err != nil {
+		t.Fatalf("HTMLディレクトリの作成に失敗: %v", err)
+	}
+	htmlContent := `<!DOCTYPE html><html><head><title>【作品】テスト作品【テスト声優】(テストサークル)｜同人</title></head><body></body></html>`
+	if err := os.WriteFile(filepath.Join(cfg.DirSetting.HtmlDir, key+".html"), []byte(htmlContent), 0644); err != nil {
+		t.Fatalf("HTMLファイルの作成に失敗: %v", err)
+	}
+
+	data, _, _, err := processDirectories(ctx, cfg, []string{key})
+	if err != nil {
+		t.Fatalf("processDirectoriesの実行に失敗: %v", err)
+	}
+	selection := data[key].Selection
+	if selection == nil || len(selection.Files) != 2 || len(selection.Skipped) != 1 {
+		t.Fatalf("作品データに音声ファイルの選択結果を保存すべき: %+v", selection)
+	}
+
+	// 変換計画は保存した選択結果を使用し、作品ディレクトリを改めて検索しない
+	writeSourceFiles(t, cfg, key, map[string]string{"03.wav": "three"})
+	plan, err := buildAlbumPlan(cfg, key, data[key])
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if len(plan.Tracks) != 2 || len(plan.Skipped) != 1 {
+		t.Errorf("変換計画は保存した選択結果と一致すべき: %d tracks, skipped %+v", len(plan.Tracks), plan.Skipped)
+	}
+}
+
 func TestSplitActorNames(t *testing.T) {
 	t.Parallel()
 
@@ -126,3 +166,480 @@ func TestSanitizeDirName(t *testing.T) {
 		})
 	}
 }
+
+func TestFirstConversionErrorPrefersCause(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("ffmpeg failed")
+	keys := []string{"RJ01", "RJ02", "RJ03"}
+	results := map[string]error{
+		"RJ01": fmt.Errorf("変換処理がキャンセルされました: %w", context.Canceled),
+		"RJ02": cause,
+		"RJ03": nil,
+	}
+
+	err := firstConversionError(keys, results)
+	if !errors.Is(err, cause) {
+		t.Fatalf("firstConversionError should return the cause, got %v", err)
+	}
+
+	if err := firstConversionError(keys, map[string]error{}); err != nil {
+		t.Fatalf("firstConversionError without failures should return nil, got %v", err)
+	}
+}
+
+// installFakeFFmpeg は引数を記録して出力ファイルを作成するだけの ffmpeg を PATH の先頭に配置します。
+// 引数に "broken" を含む場合は変換失敗として終了コード1で終了します。
+// loudnorm の測定（print_format=json）の場合は、固定の測定結果を標準エラー出力に出力します。
+// 戻り値は ffmpeg の呼び出しごとに1行追記されるログファイルのパスです。
+func installFakeFFmpeg(t *testing.T) string {
+	t.Helper()
+
+	binDir := t.TempDir()
+	callLog := filepath.Join(binDir, "calls.log")
+	script := `#!/bin/sh
+echo "$@" >> "` + callLog + `"
+case "$*" in *slow*) sleep 0.3 ;; esac
+case "$*" in *broken*) exit 1 ;; esac
+case "$*" in *print_format=json*)
+	printf '[Parsed_loudnorm_0 @ 0x0] \n{\n\t"input_i" : "-23.00",\n\t"input_tp" : "-6.00",\n\t"input_lra" : "12.30",\n\t"input_thresh" : "-33.50",\n\t"target_offset" : "0.20"\n}\n' >&2
+	exit 0 ;;
+esac
+for last; do :; done
+echo "encoded" > "$last"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatalf("ダミーffmpegの作成に失敗: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return callLog
+}
+
+// countCalls はダミー ffmpeg の呼び出し回数を返します。
+func countCalls(t *testing.T, callLog string) int {
+	t.Helper()
+
+	content, err := os.ReadFile(callLog)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatalf("呼び出しログの読み込みに失敗: %v", err)
+	}
+	return strings.Count(string(content), "\n")
+}
+
+// newConversionTestConfig は一時ディレクトリ配下を使う変換テスト用の設定を作成します。
+func newConversionTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	return &config.Config{
+		Setting: config.Setting{
+			Workers:     2,
+			Incremental: true,
+		},
+		DirSetting: config.DirSetting{
+			SourceDir:        filepath.Join(tmpDir, "source"),
+			HtmlDir:          filepath.Join(tmpDir, "html"),
+			OutputDir:        filepath.Join(tmpDir, "output"),
+			LogDir:           filepath.Join(tmpDir, "log"),
+			Mp3OutputDirName: "mp3-output",
+			ImageDir:         filepath.Join(tmpDir, "image"),
+		},
+	}
+}
+
+// writeSourceFiles は作品キーのディレクトリに音声ファイルを作成します。
+func writeSourceFiles(t *testing.T, cfg *config.Config, key string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(cfg.DirSetting.SourceDir, key, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("ディレクトリの作成に失敗: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("音声ファイルの作成に失敗: %v", err)
+		}
+	}
+}
+
+// convertFiles は1作品の変換計画を作成し、ラウドネスの測定と変換を本番の処理と同じ順で行います。
+func convertFiles(ctx context.Context, cfg *config.Config, key string, value model.IndividualData) error {
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		return err
+	}
+	if err := measureLoudness(ctx, cfg, []*albumPlan{plan})[key]; err != nil {
+		return err
+	}
+	return convertAlbums(ctx, cfg, []*albumPlan{plan})[key].Err
+}
+
+func TestConvertFilesSkipsUnchangedTracks(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テストアルバム", Actor: "テスト声優", Brand: "テストサークル"}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("初回の変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 2 {
+		t.Fatalf("初回のffmpeg呼び出し回数: got %d, want 2", got)
+	}
+
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if !plan.upToDate() {
+		t.Fatal("変更がない場合は変換済みと判定されるべき")
+	}
+
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("2回目の変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 2 {
+		t.Fatalf("変更がない場合にffmpegが呼び出されました: got %d, want 2", got)
+	}
+
+	writeSourceFiles(t, cfg, key, map[string]string{"02.wav": "two (fixed)"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("3回目の変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 3 {
+		t.Fatalf("変更したトラックのみ再エンコードされるべき: got %d, want 3", got)
+	}
+
+	if err := os.Remove(filepath.Join(cfg.DirSetting.SourceDir, key, "01.wav")); err != nil {
+		t.Fatalf("音声ファイルの削除に失敗: %v", err)
+	}
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("4回目の変換に失敗: %v", err)
+	}
+	// 01 を削除すると 02 のトラック番号が 2/2 から 1/1 に変わるため、タグを書き直すために再エンコードする
+	if got := countCalls(t, callLog); got != 4 {
+		t.Fatalf("トラック番号が変わったトラックのみ再エンコードされるべき: got %d, want 4", got)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "01.mp3")); !os.IsNotExist(err) {
+		t.Error("削除されたトラックの出力が残っています")
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "02.mp3")); err != nil {
+		t.Errorf("変換済みトラックの出力が削除されています: %v", err)
+	}
+}
+
+func TestConvertFilesOutputFormat(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テストアルバム"}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("MP3への変換に失敗: %v", err)
+	}
+
+	// 出力形式を変更すると、変更のない作品も新しい形式で作り直す
+	cfg.Setting.OutputFormat = "opus"
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("Opusへの変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 2 {
+		t.Errorf("ffmpegの呼び出し回数: got %d, want 2", got)
+	}
+	content, err := os.ReadFile(callLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "libopus") {
+		t.Errorf("Opusのエンコーダが指定されていません: %s", content)
+	}
+
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "01.opus")); err != nil {
+		t.Errorf("Opusの出力がありません: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "01.mp3")); !os.IsNotExist(err) {
+		t.Error("以前の形式の出力が残っています")
+	}
+	if !plan.upToDate() {
+		t.Error("同じ形式で再実行した場合は変換済みと判定されるべき")
+	}
+}
+
+func TestConvertFilesOutputLayout(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テストアルバム"}
+	writeSourceFiles(t, cfg, key, map[string]string{"本編/01.wav": "one", "本編/02.wav": "two", "おまけ/01.wav": "bonus"})
+
+	// flat: 別のディレクトリに同名のファイルがある場合のみディレクトリ名をファイル名に含める
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 3 {
+		t.Errorf("同名のファイルもそれぞれ変換するべき: got %d, want 3", got)
+	}
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	for _, name := range []string{"本編 - 01.mp3", "02.mp3", "おまけ - 01.mp3"} {
+		if _, err := os.Stat(filepath.Join(plan.OutputDir, name)); err != nil {
+			t.Errorf("出力 %s がありません: %v", name, err)
+		}
+	}
+
+	// subdir: 変換元のサブディレクトリと同じ構成で出力する
+	cfg.Setting.OutputLayout = config.OutputLayoutSubdir
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("subdir での変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 6 {
+		t.Errorf("出力先が変わったトラックは再エンコードするべき: got %d, want 6", got)
+	}
+	if _, err := os.Stat(filepath.Join(plan.OutputDir, "02.mp3")); !os.IsNotExist(err) {
+		t.Error("以前の配置の出力が残っています")
+	}
+
+	writeSourceFiles(t, cfg, key, map[string]string{"おまけ/01.wav": "bonus (fixed)"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("3回目の変換に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 7 {
+		t.Errorf("変更したトラックのみ再エンコードするべき: got %d, want 7", got)
+	}
+	for _, name := range []string{"本編/01.mp3", "本編/02.mp3", "おまけ/01.mp3"} {
+		if _, err := os.Stat(filepath.Join(plan.OutputDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("出力 %s がありません: %v", name, err)
+		}
+	}
+	if plan, err := buildAlbumPlan(cfg, key, value); err != nil || !plan.upToDate() {
+		t.Errorf("変更がない場合は変換済みと判定されるべき: %v", err)
+	}
+}
+
+func TestConvertFilesWorkPreset(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	quality := 2.0
+	cfg.Presets = map[string]config.Preset{"v2": {Quality: &quality}}
+	cfg.WorkPresets = []config.WorkPreset{{Match: "RJ01*", Preset: "v2"}}
+	if err := validateEncoding(cfg); err != nil {
+		t.Fatalf("設定の検証に失敗: %v", err)
+	}
+
+	writeSourceFiles(t, cfg, "RJ01234567", map[string]string{"01.wav": "one"})
+	writeSourceFiles(t, cfg, "RJ02234567", map[string]string{"01.wav": "two"})
+	for _, key := range []string{"RJ01234567", "RJ02234567"} {
+		if err := convertFiles(ctx, cfg, key, model.IndividualData{AlbumTitle: key}); err != nil {
+			t.Fatalf("%s の変換に失敗: %v", key, err)
+		}
+	}
+
+	content, err := os.ReadFile(callLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ffmpegの呼び出し回数: got %d, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], "-q:a 2") || strings.Contains(lines[0], "320k") {
+		t.Errorf("work_preset に一致する作品ではプリセットを使用すべき: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "-b:a 320k") {
+		t.Errorf("work_preset に一致しない作品は既定の設定を使用すべき: %s", lines[1])
+	}
+
+	// -preset はすべての作品に優先して適用する
+	if err := applyPresetFlag(cfg, "missing"); err == nil {
+		t.Error("存在しないプリセットを指定した場合はエラーが発生すべき")
+	}
+	cfg.Setting.OutputFormat = "flac"
+	cfg.Presets["cbr"] = config.Preset{Bitrate: "192k"}
+	if err := applyPresetFlag(cfg, "cbr"); err == nil {
+		t.Error("出力形式に適用できないプリセットを指定した場合はエラーが発生すべき")
+	}
+}
+
+func TestHandleConversionContinueOnError(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Convert = true
+	cfg.Setting.ContinueOnError = true
+	cfg.Setting.Workers = 1
+	ctx := context.Background()
+
+	writeSourceFiles(t, cfg, "RJ01", map[string]string{"01.wav": "one"})
+	writeSourceFiles(t, cfg, "RJ02", map[string]string{"01_broken.wav": "broken", "02.wav": "two"})
+	writeSourceFiles(t, cfg, "RJ03", map[string]string{"readme.txt": "no audio"})
+	writeSourceFiles(t, cfg, "RJ04", map[string]string{"01.wav": "four"})
+
+	data := map[string]model.IndividualData{
+		"RJ01": {AlbumTitle: "作品1"},
+		"RJ02": {AlbumTitle: "作品2"},
+		"RJ03": {AlbumTitle: "作品3"},
+		"RJ04": {AlbumTitle: "作品4"},
+	}
+
+	err := handleConversion(ctx, cfg, data, []string{"RJ05"}, nil, nil)
+
+	var partial *partialFailureError
+	if !errors.As(err, &partial) {
+		t.Fatalf("一部の失敗はpartialFailureErrorとして返すべき: %v", err)
+	}
+	if partial.Failed != 3 || partial.Total != 5 {
+		t.Errorf("失敗件数: got %d/%d, want 3/5", partial.Failed, partial.Total)
+	}
+	if code := exitCode(err); code != exitPartialFailure {
+		t.Errorf("exitCode: got %d, want %d", code, exitPartialFailure)
+	}
+
+	for _, key := range []string{"RJ01", "RJ04"} {
+		plan, err := buildAlbumPlan(cfg, key, data[key])
+		if err != nil {
+			t.Fatalf("変換計画の作成に失敗: %v", err)
+		}
+		if _, err := os.Stat(plan.Tracks[0].OutputFile); err != nil {
+			t.Errorf("[%s] 失敗した作品の後も変換が継続されるべき: %v", key, err)
+		}
+	}
+}
+
+func TestHandleConversionAllFailedIsFatal(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Convert = true
+	cfg.Setting.ContinueOnError = true
+
+	writeSourceFiles(t, cfg, "RJ01", map[string]string{"01_broken.wav": "broken"})
+	writeSourceFiles(t, cfg, "RJ02", map[string]string{"readme.txt": "no audio"})
+	data := map[string]model.IndividualData{"RJ01": {}, "RJ02": {}}
+
+	err := handleConversion(context.Background(), cfg, data, []string{"RJ03"}, nil, nil)
+	if err == nil {
+		t.Fatal("すべての作品が失敗した場合はエラーを返すべき")
+	}
+	if code := exitCode(err); code != exitFatal {
+		t.Errorf("成功した作品がない場合の exitCode: got %d, want %d (%v)", code, exitFatal, err)
+	}
+}
+
+func TestHandleConversionStopsOnErrorByDefault(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Convert = true
+	cfg.Setting.Workers = 1
+
+	writeSourceFiles(t, cfg, "RJ01", map[string]string{"01_broken.wav": "broken"})
+	writeSourceFiles(t, cfg, "RJ02", map[string]string{"01.wav": "two"})
+	data := map[string]model.IndividualData{"RJ01": {}, "RJ02": {}}
+
+	err := handleConversion(context.Background(), cfg, data, nil, nil, nil)
+	if err == nil {
+		t.Fatal("変換に失敗した場合はエラーを返すべき")
+	}
+	if !errors.Is(err, audioconverter.ErrConversionFailed) {
+		t.Errorf("ffmpegの失敗が原因として返されるべき: %v", err)
+	}
+	if code := exitCode(err); code != exitFatal {
+		t.Errorf("exitCode: got %d, want %d", code, exitFatal)
+	}
+	if got := countCalls(t, callLog); got != 1 {
+		t.Errorf("最初の失敗で残りの作品の変換は中止されるべき: got %d calls, want 1", got)
+	}
+}
+
+func TestHandleConversionFinishesStartedAlbumsOnError(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Convert = true
+	cfg.Setting.Workers = 2
+
+	// RJ01 の変換中に RJ02 が失敗する。着手済みの RJ01 は最後まで変換し、未着手の RJ03 は開始しない
+	writeSourceFiles(t, cfg, "RJ01", map[string]string{"01_slow.wav": "one", "02.wav": "two"})
+	writeSourceFiles(t, cfg, "RJ02", map[string]string{"01_broken.wav": "broken"})
+	writeSourceFiles(t, cfg, "RJ03", map[string]string{"01.wav": "three"})
+	data := map[string]model.IndividualData{"RJ01": {}, "RJ02": {}, "RJ03": {}}
+
+	rep := report.New("test", "encode")
+	err := handleConversion(context.Background(), cfg, data, nil, nil, rep)
+	if !errors.Is(err, audioconverter.ErrConversionFailed) {
+		t.Fatalf("ffmpegの失敗が原因として返されるべき: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 3 {
+		t.Errorf("未着手の作品は開始しないべき: got %d calls, want 3", got)
+	}
+
+	statuses := make(map[string]report.Status)
+	for _, work := range rep.Works {
+		statuses[work.Key] = work.Status
+	}
+	if statuses["RJ01"] != report.StatusConverted || statuses["RJ02"] != report.StatusFailed || statuses["RJ03"] != report.StatusFailed {
+		t.Errorf("作品ごとの結果: %v", statuses)
+	}
+	plan, err := buildAlbumPlan(cfg, "RJ01", data["RJ01"])
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if _, err := os.Stat(plan.OutputDir); err != nil {
+		t.Errorf("他の作品の失敗前に着手した作品は出力すべき: %v", err)
+	}
+}
+
+func TestHandleConversionConvertsAlbumsBeforePlanError(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Convert = true
+
+	writeSourceFiles(t, cfg, "RJ01", map[string]string{"01.wav": "one"})
+	writeSourceFiles(t, cfg, "RJ02", map[string]string{"readme.txt": "no audio"})
+	writeSourceFiles(t, cfg, "RJ03", map[string]string{"01.wav": "three"})
+	data := map[string]model.IndividualData{"RJ01": {}, "RJ02": {}, "RJ03": {}}
+
+	err := handleConversion(context.Background(), cfg, data, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "[RJ02]") {
+		t.Fatalf("変換計画を作成できなかった作品のエラーを返すべき: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 1 {
+		t.Errorf("失敗した作品より前の作品のみ変換すべき: got %d calls, want 1", got)
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		err  error
+		want failureReason
+	}{
+		{"noAudio", fmt.Errorf("%w: /src/RJ01", errNoAudioFiles), reasonNoAudio},
+		{"ffmpeg", fmt.Errorf("MP3変換に失敗: %w", audioconverter.ErrConversionFailed), reasonFFmpegFailure},
+		{"canceled", fmt.Errorf("変換処理がキャンセルされました: %w", context.Canceled), reasonCanceled},
+		{"needsEncode", fmt.Errorf("%w: トラック構成が変換記録と一致しません", errNeedsEncode), reasonNeedsEncode},
+		{"other", errors.New("ディレクトリの作成に失敗"), reasonOther},
+	}
+
+	for _, tc := range testCases {
+		if got := classifyFailure(tc.err); got != tc.want {
+			t.Errorf("%s: classifyFailure = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
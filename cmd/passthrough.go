@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// MP3 の変換元を再エンコードする理由です（mp3_passthrough イベントの reason）。
+const (
+	passthroughReasonCodec    = "codec"    // ffprobe で調べたコーデックが MP3 ではない
+	passthroughReasonLoudnorm = "loudnorm" // loudnorm で音量を変更する
+	passthroughReasonBitrate  = "bitrate"  // ビットレートが mp3_passthrough_max_bitrate を超える、または不明
+	passthroughReasonVBR      = "vbr"      // mp3_passthrough_cbr_only が有効で、可変ビットレートまたは判定できない
+)
+
+// passthroughFormat は mp3_passthrough が有効で MP3 の変換元を再エンコードせずにコピーできる場合、コピーする出力形式を返します。
+// コピーできない場合は format をそのまま返します。判断の結果はデバッグログに出力します。
+func passthroughFormat(cfg *config.Config, key, inputFile string, source *model.AudioSource, format audioconverter.Format) audioconverter.Format {
+	if !cfg.Setting.MP3Passthrough || format.Name != audioconverter.FormatNameMP3 || !strings.EqualFold(filepath.Ext(inputFile), ".mp3") {
+		return format
+	}
+
+	reason, detail := passthroughBlocked(cfg, inputFile, source)
+	message := fmt.Sprintf("[%s] %s は再エンコードせずにコピーします", key, filepath.Base(inputFile))
+	if reason != "" {
+		message = fmt.Sprintf("[%s] %s は%sため再エンコードします", key, filepath.Base(inputFile), detail)
+	}
+	logger.LogDebugEvent("mp3_passthrough", map[string]interface{}{
+		"key":         key,
+		"inputFile":   inputFile,
+		"passthrough": reason == "",
+		"reason":      reason,
+		"message":     message,
+	})
+	if reason != "" {
+		return format
+	}
+	return format.Passthrough()
+}
+
+// passthroughBlocked は MP3 の変換元を再エンコードする必要がある場合に、その理由と説明を返します。
+// コピーできる場合は空文字列を返します。source は ffprobe で調べた変換元の情報（不明な場合はnil）です。
+func passthroughBlocked(cfg *config.Config, inputFile string, source *model.AudioSource) (string, string) {
+	if source != nil && source.Codec != "" && source.Codec != "mp3" {
+		return passthroughReasonCodec, fmt.Sprintf("コーデックが %s の", source.Codec)
+	}
+	if cfg.Setting.LoudnessMode() == config.LoudnessLoudnorm {
+		return passthroughReasonLoudnorm, "loudnorm で音量を変更する"
+	}
+	if limit := cfg.Setting.MP3PassthroughMaxBitrate; limit > 0 {
+		bitRate := audioBitRate(source)
+		if bitRate <= 0 {
+			return passthroughReasonBitrate, "ビットレートが不明な"
+		}
+		if bitRate > limit*1000 {
+			return passthroughReasonBitrate, fmt.Sprintf("ビットレート %dkbps が mp3_passthrough_max_bitrate（%dkbps）を超える", bitRate/1000, limit)
+		}
+	}
+	if cfg.Setting.MP3PassthroughCBROnly {
+		vbr, err := audioconverter.MP3IsVBR(inputFile)
+		if err != nil {
+			return passthroughReasonVBR, fmt.Sprintf("固定ビットレートかどうかを判定できない（%v）", err)
+		}
+		if vbr {
+			return passthroughReasonVBR, "可変ビットレートの"
+		}
+	}
+	return "", ""
+}
+
+// audioBitRate は変換元の音声ストリームのビットレート（bps）を返します。不明な場合は0を返します。
+// ファイル全体のビットレートは ID3 タグや埋め込み画像の分だけ大きくなるため、ストリームの値を優先します。
+func audioBitRate(source *model.AudioSource) int {
+	if source == nil {
+		return 0
+	}
+	if source.StreamBitRate > 0 {
+		return source.StreamBitRate
+	}
+	return source.BitRate
+}
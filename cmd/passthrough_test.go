@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestConvertFilesMP3Passthrough(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.MP3Passthrough = true
+	cfg.Setting.MP3PassthroughMaxBitrate = 256
+	cfg.Setting.MP3PassthroughCBROnly = true
+	ctx := context.Background()
+
+	frame := func(marker string) string {
+		return "\xff\xfb\x90\x00" + strings.Repeat("\x00", 32) + marker + strings.Repeat("\x00", 100)
+	}
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{
+		"01.mp3": frame("Info"),
+		"02.mp3": frame("Xing"),
+		"03.mp3": frame("Info"),
+		"04.wav": "wav",
+	})
+	value := model.IndividualData{
+		AlbumTitle: "テスト作品",
+		Sources: []model.AudioSource{
+			{File: "01.mp3", Codec: "mp3", BitRate: 192000},
+			{File: "02.mp3", Codec: "mp3", BitRate: 192000},
+			{File: "03.mp3", Codec: "mp3", BitRate: 320000},
+		},
+	}
+
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	content, err := os.ReadFile(callLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("ffmpegの呼び出し回数: got %d, want 4", len(lines))
+	}
+	want := map[string]bool{"01.mp3": true, "02.mp3": false, "03.mp3": false, "04.wav": false}
+	for name, copied := range want {
+		for _, line := range lines {
+			if !strings.Contains(line, name) {
+				continue
+			}
+			if got := strings.Contains(line, "-c:a copy"); got != copied {
+				t.Errorf("%s のコピー: got %v, want %v: %s", name, got, copied, line)
+			}
+		}
+	}
+
+	// コピーの有無はエンコード設定として記録するため、設定を変えると対象のトラックのみ再エンコードする
+	cfg.Setting.MP3Passthrough = false
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if n := countCalls(t, callLog); n != 5 {
+		t.Errorf("コピーしたトラックのみ再エンコードすべき: got %d calls, want 5", n)
+	}
+}
+
+func TestPassthroughBlocked(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.MP3Passthrough = true
+	testCases := []struct {
+		name     string
+		loudness string
+		maxRate  int
+		source   *model.AudioSource
+		want     string
+	}{
+		{"コピー可能", "", 0, nil, ""},
+		{"コーデックが異なる", "", 0, &model.AudioSource{Codec: "aac"}, passthroughReasonCodec},
+		{"loudnorm", config.LoudnessLoudnorm, 0, &model.AudioSource{Codec: "mp3"}, passthroughReasonLoudnorm},
+		{"ビットレートが不明", "", 256, nil, passthroughReasonBitrate},
+		{"ビットレートが上限以下", "", 256, &model.AudioSource{Codec: "mp3", BitRate: 256000}, ""},
+		{"画像を含むファイル全体のビットレートは使用しない", "", 320, &model.AudioSource{Codec: "mp3", BitRate: 410000, StreamBitRate: 320000}, ""},
+		{"ストリームのビットレートが上限を超える", "", 256, &model.AudioSource{Codec: "mp3", BitRate: 330000, StreamBitRate: 320000}, passthroughReasonBitrate},
+	}
+	for _, tc := range testCases {
+		cfg.Setting.Loudness = tc.loudness
+		cfg.Setting.MP3PassthroughMaxBitrate = tc.maxRate
+		if got, _ := passthroughBlocked(cfg, "/src/01.mp3", tc.source); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/probe"
+	"golang.org/x/text/width"
+)
+
+const (
+	progressRedrawInterval = 200 * time.Millisecond // 端末表示の更新間隔
+	progressLogInterval    = 30 * time.Second       // 進捗ログの出力間隔
+	progressBarWidth       = 20
+)
+
+// progressTracker はバッチ全体と変換中の各ファイルの進捗を集計します。
+// nil の場合、すべてのメソッドは何もしません。
+type progressTracker struct {
+	mu            sync.Mutex
+	started       time.Time
+	total         int                      // 変換対象のファイル数
+	totalDuration time.Duration            // 再生時間が分かったファイルの再生時間の合計
+	durations     map[string]time.Duration // 出力ファイルごとの変換元の再生時間（不明な場合は0）
+	done          int                      // 処理を終えたファイル数
+	doneDuration  time.Duration            // 処理を終えたファイルの再生時間の合計
+	active        map[string]*fileProgress // 変換中のファイル
+}
+
+// fileProgress は変換中の1ファイルの進捗です。
+type fileProgress struct {
+	key      string
+	name     string
+	duration time.Duration
+	position time.Duration
+	speed    float64
+}
+
+// newProgressTracker は変換対象のトラックの再生時間を取得し、進捗の集計を開始します。
+// 作品データの解析時に調べた再生時間がない場合は、ffprobe で取得します。
+// 再生時間を取得できなかったトラックは、ファイル数のみ集計に含めます。
+func newProgressTracker(ctx context.Context, tracks []trackPlan) *progressTracker {
+	t := &progressTracker{
+		started:   time.Now(),
+		total:     len(tracks),
+		durations: make(map[string]time.Duration, len(tracks)),
+		active:    make(map[string]*fileProgress),
+	}
+	for _, track := range tracks {
+		if len(track.Chapters) > 0 {
+			// オーディオブックは連結する全ファイルの再生時間の合計
+			chapters, err := audioconverter.ChapterDurations(ctx, track.Chapters)
+			if err != nil {
+				logger.LogDebugEvent("probe_duration_failed", map[string]interface{}{
+					"inputFile": track.InputFile,
+					"error":     err.Error(),
+				})
+				continue
+			}
+			for _, chapter := range chapters {
+				t.durations[track.OutputFile] += chapter.Duration
+				t.totalDuration += chapter.Duration
+			}
+			continue
+		}
+		if track.Source != nil && track.Source.Duration > 0 {
+			duration := time.Duration(track.Source.Duration * float64(time.Second))
+			t.durations[track.OutputFile] = duration
+			t.totalDuration += duration
+			continue
+		}
+		duration, err := probe.Duration(ctx, track.InputFile)
+		if err != nil {
+			logger.LogDebugEvent("probe_duration_failed", map[string]interface{}{
+				"inputFile": track.InputFile,
+				"error":     err.Error(),
+			})
+			continue
+		}
+		t.durations[track.OutputFile] = duration
+		t.totalDuration += duration
+	}
+	return t
+}
+
+// begin はトラックの変換開始を記録します。
+func (t *progressTracker) begin(key string, track trackPlan) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active[track.OutputFile] = &fileProgress{
+		key:      key,
+		name:     filepath.Base(track.InputFile),
+		duration: t.durations[track.OutputFile],
+	}
+}
+
+// options はトラックの変換時に進捗を受け取るためのオプションを返します。
+func (t *progressTracker) options(track trackPlan) audioconverter.ConvertOptions {
+	if t == nil {
+		return audioconverter.ConvertOptions{}
+	}
+	return audioconverter.ConvertOptions{OnProgress: func(p audioconverter.Progress) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if file, ok := t.active[track.OutputFile]; ok {
+			file.position = p.OutTime
+			file.speed = p.Speed
+		}
+	}}
+}
+
+// end はトラックの処理終了を記録します。失敗したトラックも処理済みとして数えます。
+func (t *progressTracker) end(track trackPlan) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.active, track.OutputFile)
+	t.done++
+	t.doneDuration += t.durations[track.OutputFile]
+}
+
+// progressSnapshot はある時点の進捗です。割合と残り時間は不明な場合は負の値になります。
+type progressSnapshot struct {
+	Done    int
+	Total   int
+	Percent float64
+	Elapsed time.Duration
+	ETA     time.Duration
+	Files   []fileSnapshot
+}
+
+// fileSnapshot はある時点の1ファイルの進捗です。
+type fileSnapshot struct {
+	Key      string
+	Name     string
+	Percent  float64
+	Position time.Duration
+	Duration time.Duration
+	Speed    float64
+	ETA      time.Duration
+}
+
+// snapshot は現在の進捗を返します。
+func (t *progressTracker) snapshot(now time.Time) progressSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := progressSnapshot{Done: t.done, Total: t.total, Elapsed: now.Sub(t.started), Percent: -1, ETA: -1}
+
+	processed := t.doneDuration
+	for _, file := range t.active {
+		f := fileSnapshot{Key: file.key, Name: file.name, Position: file.position, Duration: file.duration, Speed: file.speed, Percent: -1, ETA: -1}
+		if file.duration > 0 {
+			position := min(file.position, file.duration)
+			processed += position
+			f.Percent = float64(position) / float64(file.duration) * 100
+			if file.speed > 0 {
+				f.ETA = time.Duration(float64(file.duration-position) / file.speed)
+			}
+		}
+		s.Files = append(s.Files, f)
+	}
+	sort.Slice(s.Files, func(i, j int) bool {
+		if s.Files[i].Key != s.Files[j].Key {
+			return s.Files[i].Key < s.Files[j].Key
+		}
+		return s.Files[i].Name < s.Files[j].Name
+	})
+
+	// 再生時間が分かる場合は再生時間、分からない場合はファイル数で全体の割合を求める
+	switch {
+	case t.totalDuration > 0:
+		s.Percent = float64(processed) / float64(t.totalDuration) * 100
+	case t.total > 0:
+		s.Percent = float64(t.done) / float64(t.total) * 100
+	}
+	if s.Percent > 0 {
+		s.ETA = time.Duration(float64(s.Elapsed) * (100 - s.Percent) / s.Percent)
+	}
+	return s
+}
+
+// progressRenderer は進捗の出力先です。
+type progressRenderer interface {
+	render(s progressSnapshot)
+	close(s progressSnapshot)
+}
+
+// startProgress は設定に応じて進捗の表示を開始し、集計に使用する tracker と表示を終了する関数を返します。
+// 進捗表示が無効な場合、tracker は nil になります。
+func startProgress(ctx context.Context, cfg *config.Config, tracks []trackPlan) (*progressTracker, func()) {
+	mode := cfg.Setting.ProgressMode()
+	if mode == config.ProgressAuto {
+		mode = config.ProgressLog
+		if isTerminal(os.Stdout) {
+			mode = config.ProgressTTY
+		}
+	}
+	if mode == config.ProgressOff || len(tracks) == 0 {
+		return nil, func() {}
+	}
+
+	tracker := newProgressTracker(ctx, tracks)
+	var renderer progressRenderer
+	interval := progressLogInterval
+	if mode == config.ProgressTTY {
+		renderer = newTTYRenderer(os.Stdout)
+		interval = progressRedrawInterval
+	} else {
+		renderer = logRenderer{}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				renderer.render(tracker.snapshot(now))
+			}
+		}
+	}()
+
+	var once sync.Once
+	return tracker, func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			renderer.close(tracker.snapshot(time.Now()))
+		})
+	}
+}
+
+// isTerminal は f が端末かどうかを返します。
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// logRenderer は進捗を定期的に info レベルのイベントとしてログ出力します。
+type logRenderer struct{}
+
+func (logRenderer) render(s progressSnapshot) {
+	files := make([]map[string]interface{}, 0, len(s.Files))
+	for _, f := range s.Files {
+		files = append(files, map[string]interface{}{
+			"key":         f.Key,
+			"file":        f.Name,
+			"percent":     roundPercent(f.Percent),
+			"speed":       f.Speed,
+			"eta_seconds": durationSeconds(f.ETA),
+		})
+	}
+	logger.LogInfoEvent("encode_progress", map[string]interface{}{
+		"done":            s.Done,
+		"total":           s.Total,
+		"percent":         roundPercent(s.Percent),
+		"elapsed_seconds": durationSeconds(s.Elapsed),
+		"eta_seconds":     durationSeconds(s.ETA),
+		"files":           files,
+	})
+}
+
+func (r logRenderer) close(s progressSnapshot) {
+	r.render(s)
+}
+
+// roundPercent は割合を小数点以下1桁に丸めます。不明な場合は -1 を返します。
+func roundPercent(p float64) float64 {
+	if p < 0 {
+		return -1
+	}
+	return math.Round(p*10) / 10
+}
+
+// durationSeconds は時間を秒に変換します。不明な場合は -1 を返します。
+func durationSeconds(d time.Duration) int64 {
+	if d < 0 {
+		return -1
+	}
+	return int64(d.Round(time.Second) / time.Second)
+}
+
+// ttyRenderer は端末の末尾に進捗を表示し、更新のたびに書き換えます。
+// 表示中はロガーのコンソール出力を引き受け、ログの前に進捗を消して後から描き直します。
+// 各行は端末の幅に収まるように切り詰めます。折り返すと消去する行数が合わなくなるためです。
+type ttyRenderer struct {
+	mu      sync.Mutex
+	out     io.Writer
+	width   func() int // 端末の幅（桁数、0 以下の場合は切り詰めない）
+	lines   []string
+	restore func()
+}
+
+func newTTYRenderer(out *os.File) *ttyRenderer {
+	r := &ttyRenderer{out: out, width: func() int { return terminalWidth(out) }}
+	r.restore = logger.SetConsoleOutput(r)
+	return r
+}
+
+// Write はログ出力を進捗表示の上に書き込みます。
+func (r *ttyRenderer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.erase()
+	n, err := r.out.Write(p)
+	r.draw()
+	return n, err
+}
+
+func (r *ttyRenderer) render(s progressSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.erase()
+	r.lines = formatProgress(s)
+	if r.width != nil {
+		// 最後の桁まで書くと折り返す端末があるため、1桁空ける
+		for i, line := range r.lines {
+			r.lines[i] = truncateWidth(line, r.width()-1)
+		}
+	}
+	r.draw()
+}
+
+func (r *ttyRenderer) close(progressSnapshot) {
+	r.mu.Lock()
+	r.erase()
+	r.lines = nil
+	r.mu.Unlock()
+	r.restore()
+}
+
+// erase は表示中の進捗を消去します。呼び出し時は mu を保持している必要があります。
+func (r *ttyRenderer) erase() {
+	if len(r.lines) == 0 {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("\r\033[K")
+	for range len(r.lines) - 1 {
+		b.WriteString("\033[1A\033[K")
+	}
+	io.WriteString(r.out, b.String())
+}
+
+// draw は進捗を描画します。カーソルは最終行の末尾に残します。呼び出し時は mu を保持している必要があります。
+func (r *ttyRenderer) draw() {
+	if len(r.lines) == 0 {
+		return
+	}
+	io.WriteString(r.out, strings.Join(r.lines, "\n"))
+}
+
+// formatProgress は端末に表示する進捗の各行を返します。
+func formatProgress(s progressSnapshot) []string {
+	lines := []string{fmt.Sprintf("全体 %s %s %d/%dファイル 経過 %s 残り %s",
+		progressBar(s.Percent), formatPercent(s.Percent), s.Done, s.Total, formatClock(s.Elapsed), formatClock(s.ETA))}
+	for _, f := range s.Files {
+		line := fmt.Sprintf("  [%s] %s %s", f.Key, f.Name, formatPercent(f.Percent))
+		if f.Duration > 0 {
+			line += fmt.Sprintf(" %s/%s", formatClock(f.Position), formatClock(f.Duration))
+		}
+		if f.Speed > 0 {
+			line += fmt.Sprintf(" %.1fx", f.Speed)
+		}
+		line += " 残り " + formatClock(f.ETA)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncateWidth は表示幅が limit 桁を超える行を切り詰めます。全角文字は2桁として数えます。
+// limit が 0 以下の場合はそのまま返します。
+func truncateWidth(line string, limit int) string {
+	if limit <= 0 {
+		return line
+	}
+	columns := 0
+	for i, r := range line {
+		w := 1
+		if kind := width.LookupRune(r).Kind(); kind == width.EastAsianWide || kind == width.EastAsianFullwidth {
+			w = 2
+		}
+		if columns+w > limit {
+			return line[:i]
+		}
+		columns += w
+	}
+	return line
+}
+
+// progressBar は割合を棒グラフで表します。
+func progressBar(percent float64) string {
+	filled := 0
+	if percent > 0 {
+		filled = min(progressBarWidth, int(percent/100*progressBarWidth))
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled) + "]"
+}
+
+// formatPercent は割合を表示用に整形します。
+func formatPercent(percent float64) string {
+	if percent < 0 {
+		return "--.-%"
+	}
+	return fmt.Sprintf("%5.1f%%", percent)
+}
+
+// formatClock は時間を HH:MM:SS 形式に整形します。
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		return "--:--:--"
+	}
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+)
+
+// newTestTracker は ffprobe を使用せずに再生時間を指定した tracker を作成します。
+func newTestTracker(started time.Time, tracks []trackPlan, durations []time.Duration) *progressTracker {
+	t := &progressTracker{
+		started:   started,
+		total:     len(tracks),
+		durations: make(map[string]time.Duration),
+		active:    make(map[string]*fileProgress),
+	}
+	for i, track := range tracks {
+		if durations[i] > 0 {
+			t.durations[track.OutputFile] = durations[i]
+			t.totalDuration += durations[i]
+		}
+	}
+	return t
+}
+
+func TestProgressTrackerSnapshot(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracks := []trackPlan{
+		{InputFile: "/src/RJ01/01.wav", OutputFile: "/out/01.mp3"},
+		{InputFile: "/src/RJ01/02.wav", OutputFile: "/out/02.mp3"},
+	}
+	tracker := newTestTracker(started, tracks, []time.Duration{60 * time.Second, 40 * time.Second})
+
+	tracker.begin("RJ01", tracks[0])
+	tracker.end(tracks[0])
+	tracker.begin("RJ01", tracks[1])
+	tracker.options(tracks[1]).OnProgress(audioconverter.Progress{OutTime: 10 * time.Second, Speed: 5})
+
+	s := tracker.snapshot(started.Add(35 * time.Second))
+	if s.Done != 1 || s.Total != 2 {
+		t.Errorf("ファイル数: got %d/%d, want 1/2", s.Done, s.Total)
+	}
+	if s.Percent != 70 {
+		t.Errorf("全体の割合: got %v, want 70", s.Percent)
+	}
+	if s.ETA != 15*time.Second {
+		t.Errorf("全体の残り時間: got %s, want 15s", s.ETA)
+	}
+	if len(s.Files) != 1 {
+		t.Fatalf("変換中のファイル数: got %d, want 1", len(s.Files))
+	}
+	f := s.Files[0]
+	if f.Key != "RJ01" || f.Name != "02.wav" || f.Percent != 25 || f.ETA != 6*time.Second {
+		t.Errorf("ファイルの進捗: got %+v", f)
+	}
+}
+
+func TestProgressTrackerUnknownDuration(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracks := []trackPlan{
+		{InputFile: "/src/RJ01/01.wav", OutputFile: "/out/01.mp3"},
+		{InputFile: "/src/RJ01/02.wav", OutputFile: "/out/02.mp3"},
+	}
+	tracker := newTestTracker(started, tracks, []time.Duration{0, 0})
+
+	tracker.begin("RJ01", tracks[0])
+	s := tracker.snapshot(started.Add(time.Second))
+	if s.Percent != 0 || s.ETA >= 0 {
+		t.Errorf("完了前は残り時間が不明であるべき: got %v%%, %s", s.Percent, s.ETA)
+	}
+	if s.Files[0].Percent >= 0 || s.Files[0].ETA >= 0 {
+		t.Errorf("再生時間が不明なファイルの割合は不明であるべき: %+v", s.Files[0])
+	}
+
+	// 再生時間が分からない場合はファイル数で割合を求める
+	tracker.end(tracks[0])
+	s = tracker.snapshot(started.Add(10 * time.Second))
+	if s.Percent != 50 || s.ETA != 10*time.Second {
+		t.Errorf("ファイル数による割合: got %v%%, %s", s.Percent, s.ETA)
+	}
+}
+
+func TestProgressTrackerNil(t *testing.T) {
+	var tracker *progressTracker
+	track := trackPlan{OutputFile: "/out/01.mp3"}
+	tracker.begin("RJ01", track)
+	if opts := tracker.options(track); opts.OnProgress != nil {
+		t.Error("nil の tracker は進捗を要求すべきではない")
+	}
+	tracker.end(track)
+}
+
+func TestFormatProgress(t *testing.T) {
+	lines := formatProgress(progressSnapshot{
+		Done: 1, Total: 3, Percent: 50, Elapsed: 90 * time.Second, ETA: -1,
+		Files: []fileSnapshot{{Key: "RJ01", Name: "01.wav", Percent: 25, Position: 15 * time.Second, Duration: time.Minute, Speed: 12.5, ETA: 3 * time.Second}},
+	})
+	want := []string{
+		"全体 [##########----------]  50.0% 1/3ファイル 経過 00:01:30 残り --:--:--",
+		"  [RJ01] 01.wav  25.0% 00:00:15/00:01:00 12.5x 残り 00:00:03",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("formatProgress:\ngot  %q\nwant %q", lines, want)
+	}
+}
+
+func TestTTYRendererWrite(t *testing.T) {
+	var buf bytes.Buffer
+	r := &ttyRenderer{out: &buf, restore: func() {}}
+	r.render(progressSnapshot{Total: 1, Percent: -1, ETA: -1, Files: []fileSnapshot{{Key: "RJ01", Name: "01.wav", Percent: -1, ETA: -1}}})
+	buf.Reset()
+
+	// ログ出力の前に2行分の進捗を消去し、出力後に描き直す
+	if _, err := r.Write([]byte("log line\n")); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "\r\033[K\033[1A\033[Klog line\n全体 ") {
+		t.Errorf("進捗の消去と再描画が正しくありません: %q", got)
+	}
+
+	buf.Reset()
+	r.close(progressSnapshot{})
+	if buf.String() != "\r\033[K\033[1A\033[K" {
+		t.Errorf("終了時に進捗が消去されていません: %q", buf.String())
+	}
+}
+
+func TestTTYRendererTruncatesToWidth(t *testing.T) {
+	var buf bytes.Buffer
+	r := &ttyRenderer{out: &buf, width: func() int { return 30 }, restore: func() {}}
+	r.render(progressSnapshot{Total: 1, Percent: -1, ETA: -1, Files: []fileSnapshot{{Key: "RJ01", Name: "とても長いファイル名のトラック.wav", Percent: -1, ETA: -1}}})
+
+	// 端末の幅を超える行は折り返さないように切り詰める（全角文字は2桁）
+	if got := buf.String(); got != "全体 [--------------------] -\n  [RJ01] とても長いファイル名" {
+		t.Errorf("端末の幅に合わせて切り詰めるべき: %q", got)
+	}
+	buf.Reset()
+	r.close(progressSnapshot{})
+	if buf.String() != "\r\033[K\033[1A\033[K" {
+		t.Errorf("切り詰めた行数分だけ消去すべき: %q", buf.String())
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	testCases := []struct {
+		line  string
+		limit int
+		want  string
+	}{
+		{"abcdef", 4, "abcd"},
+		{"あいう", 5, "あい"},
+		{"aあb", 3, "aあ"},
+		{"short", 10, "short"},
+		{"unlimited", 0, "unlimited"},
+	}
+	for _, tc := range testCases {
+		if got := truncateWidth(tc.line, tc.limit); got != tc.want {
+			t.Errorf("truncateWidth(%q, %d) = %q, want %q", tc.line, tc.limit, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"github.com/kkryama/dls-encoder/internal/report"
+)
+
+// failedWork は変換計画を作成できなかった作品のレポートを作成します。
+func failedWork(key string, data *model.IndividualData, reason failureReason, detail string) report.Work {
+	return report.Work{
+		Key:      key,
+		Status:   report.StatusFailed,
+		Reason:   string(reason),
+		Detail:   detail,
+		Metadata: data,
+	}
+}
+
+// albumWork は変換計画と変換結果から作品のレポートを作成します。
+func albumWork(plan *albumPlan, result albumResult) report.Work {
+	data := plan.Data
+	work := report.Work{
+		Key:            plan.Key,
+		Status:         report.StatusConverted,
+		Metadata:       &data,
+		OutputDir:      plan.OutputDir,
+		SkippedSources: plan.Skipped,
+	}
+	if plan.upToDate() {
+		work.Status = report.StatusUpToDate
+	}
+	if result.Err != nil {
+		work.Status = report.StatusFailed
+		work.Reason = string(classifyFailure(result.Err))
+		work.Detail = result.Err.Error()
+	}
+
+	for _, track := range plan.Tracks {
+		entry := report.Track{
+			Input:      track.InputFile,
+			Output:     track.OutputFile,
+			InputBytes: track.Record.Source.Size,
+		}
+		for _, chapter := range track.Record.Chapters {
+			entry.InputBytes += chapter.Source.Size
+		}
+		tr, attempted := result.Tracks[track.Record.Output]
+		switch {
+		case track.Skip:
+			entry.Status = report.TrackUnchanged
+		case !attempted:
+			entry.Status = report.TrackNotStarted
+		case tr.Err != nil:
+			entry.Status = report.TrackFailed
+			entry.Error = tr.Err.Error()
+		case result.Err != nil:
+			// 作品が完了しなかった場合、変換済みのトラックは作業用ディレクトリに残り、出力には反映されない
+			entry.Status = report.TrackDiscarded
+		default:
+			entry.Status = report.TrackEncoded
+		}
+		if attempted {
+			entry.DurationSeconds = tr.Duration.Seconds()
+		}
+		if entry.Status == report.TrackEncoded || entry.Status == report.TrackUnchanged {
+			if info, err := os.Stat(track.OutputFile); err == nil {
+				entry.OutputBytes = info.Size()
+			}
+		}
+		work.Tracks = append(work.Tracks, entry)
+	}
+	return work
+}
+
+// reportPath はログファイルと同じディレクトリ・タイムスタンプのレポートのパスを返します。
+// 例: results_20250712_120000.log → report_20250712_120000.json
+func reportPath(logFile *os.File, ext string) string {
+	name := strings.TrimSuffix(filepath.Base(logFile.Name()), filepath.Ext(logFile.Name()))
+	name = reportFilePrefix + strings.TrimPrefix(name, logFilePrefix)
+	return filepath.Join(filepath.Dir(logFile.Name()), name+ext)
+}
+
+// saveReport は実行結果のレポートをJSON（report_html が有効な場合はHTMLも）で保存します。
+// レポートの保存に失敗しても変換結果には影響しないため、警告に留めます。
+func saveReport(cfg *config.Config, rep *report.Report, logFile *os.File, runErr error) {
+	if rep == nil || logFile == nil {
+		return
+	}
+	rep.Finish(runErr)
+
+	paths := []string{reportPath(logFile, ".json")}
+	var errs []error
+	if err := rep.SaveJSON(paths[0]); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Setting.ReportHTML {
+		paths = append(paths, reportPath(logFile, ".html"))
+		if err := rep.SaveHTML(paths[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		logger.LogWarnEvent("report_save_error", map[string]interface{}{
+			"error":   err.Error(),
+			"paths":   paths,
+			"message": fmt.Sprintf("実行レポートの保存に失敗: %v", err),
+		})
+		return
+	}
+	logger.LogMessage(fmt.Sprintf("実行レポートを保存しました: %s", strings.Join(paths, ", ")))
+}
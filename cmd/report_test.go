@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"github.com/kkryama/dls-encoder/internal/report"
+)
+
+func TestHandleConversionRecordsReport(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Convert = true
+	cfg.Setting.ContinueOnError = true
+	cfg.Setting.Workers = 1
+	ctx := context.Background()
+
+	writeSourceFiles(t, cfg, "RJ01", map[string]string{"01.wav": "one", "01.mp3": "one (mp3)"})
+	writeSourceFiles(t, cfg, "RJ02", map[string]string{"01_broken.wav": "broken", "02.wav": "two"})
+	data := map[string]model.IndividualData{
+		"RJ01": {AlbumTitle: "作品1"},
+		"RJ02": {AlbumTitle: "作品2"},
+	}
+
+	rep := report.New("test", "encode")
+	handleConversion(ctx, cfg, data, []string{"RJ03"}, nil, rep)
+	rep.Finish(nil)
+
+	works := make(map[string]report.Work)
+	for _, work := range rep.Works {
+		works[work.Key] = work
+	}
+	if len(works) != 3 {
+		t.Fatalf("作品数: got %d, want 3 (%+v)", len(works), rep.Works)
+	}
+
+	converted := works["RJ01"]
+	if converted.Status != report.StatusConverted || converted.Metadata == nil || converted.Metadata.AlbumTitle != "作品1" {
+		t.Errorf("RJ01 の結果が正しくありません: %+v", converted)
+	}
+	if len(converted.Tracks) != 1 || converted.Tracks[0].Status != report.TrackEncoded ||
+		converted.Tracks[0].InputBytes != int64(len("one")) || converted.Tracks[0].OutputBytes == 0 {
+		t.Errorf("RJ01 のトラックの結果が正しくありません: %+v", converted.Tracks)
+	}
+	if len(converted.SkippedSources) != 1 || converted.SkippedSources[0].Reason != audioconverter.SkipReasonLowerPriority {
+		t.Errorf("優先度で除外したファイルが記録されていません: %+v", converted.SkippedSources)
+	}
+
+	failed := works["RJ02"]
+	if failed.Status != report.StatusFailed || failed.Reason != string(reasonFFmpegFailure) {
+		t.Errorf("RJ02 の結果が正しくありません: %+v", failed)
+	}
+	statuses := map[report.TrackStatus]int{}
+	for _, track := range failed.Tracks {
+		statuses[track.Status]++
+	}
+	if statuses[report.TrackFailed] != 1 || statuses[report.TrackNotStarted] != 1 {
+		t.Errorf("RJ02 のトラックの結果が正しくありません: %+v", failed.Tracks)
+	}
+
+	if works["RJ03"].Reason != string(reasonParseError) {
+		t.Errorf("RJ03 の結果が正しくありません: %+v", works["RJ03"])
+	}
+}
+
+func TestReportPath(t *testing.T) {
+	dir := t.TempDir()
+	logFile, err := os.Create(filepath.Join(dir, "results_20250712_120000.log"))
+	if err != nil {
+		t.Fatalf("ログファイルの作成に失敗: %v", err)
+	}
+	defer logFile.Close()
+
+	if got, want := reportPath(logFile, ".json"), filepath.Join(dir, "report_20250712_120000.json"); got != want {
+		t.Errorf("reportPath: got %v, want %v", got, want)
+	}
+}
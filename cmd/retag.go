@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/id3"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/manifest"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// errNeedsEncode は変換元やエンコード設定が変わっているなど、タグの書き換えだけでは出力を更新できないことを表します。
+var errNeedsEncode = errors.New("encode による再変換が必要です")
+
+// retagResult は1作品分のタグの書き換え結果です。
+type retagResult struct {
+	AlbumDir  string   // 書き換え前の出力アルバムのディレクトリ
+	OutputDir string   // 現在の作品データから求めた出力アルバムのディレクトリ
+	Updated   []string // タグを書き換えた出力ファイル名
+	Covers    bool     // フォルダ画像を保存し直すかどうか
+}
+
+// moved はアルバムのディレクトリを移動する（した）かどうかを返します。
+func (r *retagResult) moved() bool {
+	return r.AlbumDir != r.OutputDir
+}
+
+// runRetag は作品のHTMLを解析し直し、出力済みのMP3のタグを再エンコードせずに書き換えます。
+// 作品データから求めた出力先が変わった場合は、アルバムのディレクトリを移動します。
+func runRetag(ctx context.Context, cfg *config.Config, opts runOptions) error {
+	logger.LogMessage("dls-encoder version: " + version)
+
+	logFile, err := setupLogging(cfg.DirSetting.LogDir, cfg.Setting.Debug)
+	if err != nil {
+		return fmt.Errorf("ログ設定の初期化に失敗: %w", err)
+	}
+	defer func() {
+		if logFile != nil {
+			if err := logFile.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "ログファイルのクローズでエラー: %v\n", err)
+			}
+		}
+	}()
+
+	if !opts.DryRun {
+		cleanupInterruptedOutputs(cfg)
+	}
+
+	targetDirs, err := loadTargets(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	data, notApplicableData, missingImageData, err := processDirectories(ctx, cfg, targetDirs)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの処理に失敗: %w", err)
+	}
+
+	summary := &runSummary{}
+	for _, key := range getSortedKeys(data) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result, err := retagAlbum(ctx, cfg, key, data[key], opts.DryRun)
+		if err != nil {
+			summary.addError(key, err)
+			continue
+		}
+		summary.Succeeded = append(summary.Succeeded, key)
+		logRetagResult(key, result, opts.DryRun)
+	}
+	for _, key := range notApplicableData {
+		summary.addFailure(key, reasonParseError, "HTMLファイルが存在しないか、解析に失敗しました")
+	}
+	for _, key := range missingImageData {
+		summary.addFailure(key, reasonMissingImage, "メイン画像が見つかりません")
+	}
+	summary.print()
+	return summary.err()
+}
+
+// logRetagResult は1作品分の書き換え結果をログに出力します。
+func logRetagResult(key string, result *retagResult, dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[dry-run] "
+	}
+	if len(result.Updated) == 0 && !result.moved() && !result.Covers {
+		logger.LogMessage(fmt.Sprintf("%s[%s] タグに変更はありません", prefix, key))
+		return
+	}
+	if len(result.Updated) > 0 {
+		logger.LogMessage(fmt.Sprintf("%s[%s] %d トラックのタグを書き換えました: %s", prefix, key, len(result.Updated), strings.Join(result.Updated, ", ")))
+	}
+	if result.Covers {
+		logger.LogMessage(fmt.Sprintf("%s[%s] フォルダ画像を保存しました", prefix, key))
+	}
+	if result.moved() {
+		logger.LogMessage(fmt.Sprintf("%s[%s] アルバムを移動しました: %s -> %s", prefix, key, result.AlbumDir, result.OutputDir))
+	}
+	logger.LogInfoEvent("album_retagged", map[string]interface{}{
+		"key":       key,
+		"albumDir":  result.AlbumDir,
+		"outputDir": result.OutputDir,
+		"updated":   result.Updated,
+		"dryRun":    dryRun,
+	})
+}
+
+// retagAlbum は1作品分の出力済みのMP3のタグを、現在の作品データに合わせて書き換えます。
+// 変換記録と比べて変換元・エンコード設定・トラック構成が変わっている場合は、何も変更せずに errNeedsEncode を返します。
+// dryRun が true の場合は、書き換えるトラックと移動先を求めるだけでファイルは変更しません。
+func retagAlbum(ctx context.Context, cfg *config.Config, key string, data model.IndividualData, dryRun bool) (*retagResult, error) {
+	plan, err := buildAlbumPlan(cfg, key, data)
+	if err != nil {
+		return nil, err
+	}
+	if format := plan.Tracks[0].Format; format.Name != audioconverter.FormatNameMP3 {
+		return nil, fmt.Errorf("%w: タグの書き換えは MP3 の出力のみ対応しています（出力形式: %s）", errNeedsEncode, format.Name)
+	}
+
+	result := &retagResult{AlbumDir: plan.OutputDir, OutputDir: plan.OutputDir}
+	if plan.Previous == nil {
+		// 作品名などが変わり出力先が変わった場合は、作品キーから前回の出力を探す
+		dir, previous, err := findAlbumDir(cfg, key)
+		if err != nil {
+			return nil, err
+		}
+		result.AlbumDir = dir
+		plan.Previous = previous
+		applyLoudness(cfg, plan)
+	}
+	if err := checkRetaggable(cfg, plan, result.AlbumDir); err != nil {
+		return nil, err
+	}
+
+	result.Covers = !slices.Equal(plan.Previous.CoverFiles, plan.CoverFiles)
+	var cover []byte
+	for _, track := range plan.Tracks {
+		prev, _ := plan.Previous.Find(track.Record.Output)
+		if prev.Equal(track.Record) {
+			continue
+		}
+		// フォルダ画像は埋め込む画像と同じものを保存するため、埋め込む画像が変わった場合は保存し直す
+		if coverChanged(prev, track.Record) && len(plan.CoverFiles) > 0 {
+			result.Covers = true
+		}
+		result.Updated = append(result.Updated, track.Record.Output)
+		if dryRun {
+			continue
+		}
+
+		if cover == nil && plan.CoverImage != "" {
+			if cover, err = coverJPEG(ctx, plan); err != nil {
+				return nil, err
+			}
+		}
+		if err := id3.WriteFile(filepath.Join(result.AlbumDir, filepath.FromSlash(track.Record.Output)), id3Tag(track.Metadata, cover)); err != nil {
+			return nil, fmt.Errorf("タグの書き換えに失敗: %w", err)
+		}
+	}
+	if dryRun || (len(result.Updated) == 0 && !result.moved() && !result.Covers) {
+		return result, nil
+	}
+
+	if result.Covers {
+		if err := retagCoverFiles(ctx, plan, result.AlbumDir, cover); err != nil {
+			return nil, err
+		}
+	}
+	records := make([]manifest.Track, 0, len(plan.Tracks))
+	for _, track := range plan.Tracks {
+		records = append(records, track.Record)
+	}
+	record := manifest.New(key, records)
+	record.CoverFiles = plan.CoverFiles
+	if err := record.Save(result.AlbumDir); err != nil {
+		return nil, err
+	}
+	if result.moved() {
+		if err := moveAlbumDir(cfg, result.AlbumDir, result.OutputDir); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// retagCoverFiles はアルバムのディレクトリのフォルダ画像を保存し直し、cover_files から外したフォルダ画像を削除します。
+// cover は埋め込んだ画像のデータで、まだ読み込んでいない場合は nil です。
+func retagCoverFiles(ctx context.Context, plan *albumPlan, albumDir string, cover []byte) error {
+	for _, name := range plan.Previous.CoverFiles {
+		if !slices.Contains(plan.CoverFiles, name) {
+			if err := os.Remove(filepath.Join(albumDir, name)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("フォルダ画像の削除に失敗: %w", err)
+			}
+		}
+	}
+	if plan.CoverImage == "" || len(plan.CoverFiles) == 0 {
+		return nil
+	}
+	if cover == nil {
+		var err error
+		if cover, err = coverJPEG(ctx, plan); err != nil {
+			return err
+		}
+	}
+	return writeCoverFiles(plan, albumDir, cover)
+}
+
+// coverChanged は埋め込む画像（元の画像ファイルと加工方法）が変換記録から変わっているかどうかを返します。
+func coverChanged(prev, record manifest.Track) bool {
+	if (prev.Cover == nil) != (record.Cover == nil) || prev.CoverOptions != record.CoverOptions {
+		return true
+	}
+	return prev.Cover != nil && !prev.Cover.Equal(*record.Cover)
+}
+
+// checkRetaggable は出力済みのアルバムの全トラックが、タグの書き換えだけで現在の変換計画と一致するかどうかを確認します。
+func checkRetaggable(cfg *config.Config, plan *albumPlan, albumDir string) error {
+	if len(plan.Previous.Tracks) != len(plan.Tracks) {
+		return fmt.Errorf("%w: トラック構成が変換記録と一致しません", errNeedsEncode)
+	}
+	if cfg.Setting.LoudnessMode() == config.LoudnessReplayGain {
+		for _, track := range plan.Tracks {
+			if track.Loudness == nil {
+				return fmt.Errorf("%w: ReplayGain の計算に使用するラウドネスの測定結果がありません", errNeedsEncode)
+			}
+		}
+	}
+
+	var stale []string
+	for _, track := range plan.Tracks {
+		prev, ok := plan.Previous.Find(track.Record.Output)
+		if !ok || prev.Encoder != track.Record.Encoder || !prev.SameSource(track.Record) {
+			stale = append(stale, track.Record.Output)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(albumDir, filepath.FromSlash(track.Record.Output))); err != nil {
+			stale = append(stale, track.Record.Output)
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("%w: 変換元またはエンコード設定が変わっているトラックがあります: %s", errNeedsEncode, strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// findAlbumDir は出力先のツリーから、作品キーの変換記録があるアルバムのディレクトリを探します。
+// 出力アルバムは <出力先>/<声優>/<サークル>/【作品キー】作品名 に配置されているものとして探します。
+func findAlbumDir(cfg *config.Config, key string) (string, *manifest.Manifest, error) {
+	root := filepath.Join(cfg.DirSetting.OutputDir, cfg.DirSetting.Mp3OutputDirName)
+	candidates, err := filepath.Glob(filepath.Join(root, "*", "*", "【"+key+"】*"))
+	if err != nil {
+		return "", nil, fmt.Errorf("出力アルバムの検索に失敗: %w", err)
+	}
+
+	var dirs []string
+	var found *manifest.Manifest
+	for _, dir := range candidates {
+		previous, err := manifest.Load(dir)
+		if err != nil || previous == nil || previous.Key != key {
+			continue
+		}
+		dirs = append(dirs, dir)
+		found = previous
+	}
+	switch len(dirs) {
+	case 0:
+		return "", nil, fmt.Errorf("%w: 変換記録のある出力アルバムが見つかりません", errNeedsEncode)
+	case 1:
+		return dirs[0], found, nil
+	default:
+		return "", nil, fmt.Errorf("作品キー [%s] の出力アルバムが複数あります: %s", key, strings.Join(dirs, ", "))
+	}
+}
+
+// moveAlbumDir は出力アルバムのディレクトリを移動し、移動により空になった声優・サークルのディレクトリを削除します。
+func moveAlbumDir(cfg *config.Config, from, to string) error {
+	if exists, err := audioconverter.DirExists(to); err != nil {
+		return fmt.Errorf("ディレクトリの確認に失敗: %w", err)
+	} else if exists {
+		return fmt.Errorf("移動先のディレクトリが既に存在します: %s", to)
+	}
+	if err := audioconverter.EnsureDirExists(filepath.Dir(to)); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("出力アルバムの移動に失敗: %w", err)
+	}
+
+	root := filepath.Join(cfg.DirSetting.OutputDir, cfg.DirSetting.Mp3OutputDirName)
+	for dir := filepath.Dir(from); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		// 空でないディレクトリは削除できないため、そこで終了する
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// id3Tag はメタデータとカバー画像（JPEG）から書き込む ID3 タグを作成します。
+// タグ名と値は変換時に ffmpeg の -metadata で設定するものと同じです。
+func id3Tag(metadata audioconverter.MP3Metadata, cover []byte) id3.Tag {
+	var tag id3.Tag
+	for _, t := range metadata.Tags() {
+		tag.Fields = append(tag.Fields, id3.Field{Name: t.Name, Value: t.Value})
+	}
+	if cover != nil {
+		tag.Picture = &id3.Picture{
+			MIME:        "image/jpeg",
+			Type:        id3.PictureTypeFrontCover,
+			Description: "Album cover",
+			Data:        cover,
+		}
+	}
+	return tag
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/id3"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestRetagAlbumMovesAndRewritesTags(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "誤ったタイトル", Actor: "テスト声優", Brand: "テストサークル"}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	before, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+
+	value.AlbumTitle = "正しいタイトル"
+	result, err := retagAlbum(ctx, cfg, key, value, true)
+	if err != nil {
+		t.Fatalf("retagAlbum (dry-run) に失敗: %v", err)
+	}
+	if !result.moved() || len(result.Updated) != 2 {
+		t.Errorf("dry-run の結果: %+v", result)
+	}
+	if _, err := os.Stat(before.OutputDir); err != nil {
+		t.Fatalf("dry-run ではアルバムを移動しないべき: %v", err)
+	}
+
+	result, err = retagAlbum(ctx, cfg, key, value, false)
+	if err != nil {
+		t.Fatalf("retagAlbum に失敗: %v", err)
+	}
+	if got := countCalls(t, callLog); got != 2 {
+		t.Errorf("タグの書き換えでffmpegを呼び出すべきではない: got %d, want 2", got)
+	}
+	if want := []string{"01.mp3", "02.mp3"}; !reflect.DeepEqual(result.Updated, want) {
+		t.Errorf("書き換えたトラック: got %v, want %v", result.Updated, want)
+	}
+	if _, err := os.Stat(before.OutputDir); !os.IsNotExist(err) {
+		t.Errorf("移動前のアルバムが残っています: %v", err)
+	}
+
+	output := filepath.Join(result.OutputDir, "01.mp3")
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("移動後の出力を読み込めません: %v", err)
+	}
+	if !bytes.HasSuffix(content, []byte("encoded\n")) {
+		t.Errorf("音声データが変更されています: %q", content)
+	}
+	frames, err := id3.ReadFrames(output)
+	if err != nil {
+		t.Fatalf("タグの読み込みに失敗: %v", err)
+	}
+	albums := 0
+	for _, frame := range frames {
+		if frame.ID == "TALB" {
+			albums++
+			if got := frame.Strings(); !reflect.DeepEqual(got, []string{"正しいタイトル"}) {
+				t.Errorf("TALB: got %v", got)
+			}
+		}
+	}
+	if albums != 1 {
+		t.Errorf("TALB の数: got %d, want 1", albums)
+	}
+
+	after, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if !after.upToDate() {
+		t.Error("書き換え後は変換記録と一致するべき")
+	}
+
+	result, err = retagAlbum(ctx, cfg, key, value, false)
+	if err != nil {
+		t.Fatalf("2回目の retagAlbum に失敗: %v", err)
+	}
+	if result.moved() || len(result.Updated) != 0 {
+		t.Errorf("変更がない場合は何もしないべき: %+v", result)
+	}
+}
+
+func TestRetagAlbumRequiresEncode(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テストアルバム", Actor: "テスト声優", Brand: "テストサークル"}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+	if _, err := retagAlbum(ctx, cfg, key, value, false); !errors.Is(err, errNeedsEncode) {
+		t.Errorf("未変換の作品は errNeedsEncode になるべき: %v", err)
+	}
+
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one (fixed)"})
+	value.AlbumTitle = "修正後のアルバム"
+	if _, err := retagAlbum(ctx, cfg, key, value, false); !errors.Is(err, errNeedsEncode) {
+		t.Errorf("変換元が変わった作品は errNeedsEncode になるべき: %v", err)
+	}
+}
+
+func TestID3TagMatchesEncodeMetadata(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.ExtraTags = true
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+
+	data := model.IndividualData{AlbumTitle: "テスト作品", Additional: map[string]string{"シナリオ": "テスト作家", "販売日": "2023年05月12日 0時"}}
+	plan, err := buildAlbumPlan(cfg, key, data)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	track := plan.Tracks[0]
+
+	// 変換時に ffmpeg へ渡すタグと、タグの書き換えで書き込むタグは同じ名前・値にする
+	var encoded []string
+	args := track.Format.Args(track.InputFile, track.OutputFile, track.Metadata, "")
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-metadata" {
+			encoded = append(encoded, args[i+1])
+		}
+	}
+	var retagged []string
+	for _, field := range id3Tag(track.Metadata, nil).Fields {
+		retagged = append(retagged, field.Name+"="+field.Value)
+	}
+	if !reflect.DeepEqual(encoded, retagged) {
+		t.Errorf("変換時とタグの書き換え時でタグが異なります:\nencode %v\nretag  %v", encoded, retagged)
+	}
+
+	// ffmpeg は lyricist に対応するフレームがないため TXXX に書き込む。書き換えでも同じフレームにする
+	var lyricist []id3.Frame
+	for _, frame := range id3Tag(track.Metadata, nil).Frames() {
+		if frame.ID == "TEXT" || (frame.ID == "TXXX" && frame.Strings()[0] == "lyricist") {
+			lyricist = append(lyricist, frame)
+		}
+	}
+	if len(lyricist) != 1 || lyricist[0].ID != "TXXX" || !reflect.DeepEqual(lyricist[0].Strings(), []string{"lyricist", "テスト作家"}) {
+		t.Errorf("lyricist は変換時と同じ TXXX:lyricist に書き込むべき: %v", lyricist)
+	}
+}
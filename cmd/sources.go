@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"github.com/kkryama/dls-encoder/internal/probe"
+)
+
+// binauralKeywords は作品がバイノーラル録音であることを示す語です（大文字小文字を区別しない）。
+var binauralKeywords = []string{"バイノーラル", "binaural", "ダミーヘッド", "dummy head"}
+
+// probeSources は作品の変換対象の音声ファイルを ffprobe で調べ、結果と注意事項を作品データに設定します。
+// ffprobe が見つからない場合は何もしません。調べられなかったファイルは Error に理由を記録します。
+func probeSources(ctx context.Context, cfg *config.Config, key string, data *model.IndividualData) {
+	if !probe.Available() {
+		logger.LogDebugEvent("ffprobe_not_found", map[string]interface{}{
+			"key": key,
+		})
+		return
+	}
+
+	targetDir := filepath.Join(cfg.DirSetting.SourceDir, key)
+	files := audioSelection(cfg, key, *data).Files
+	data.Sources = make([]model.AudioSource, 0, len(files))
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		rel, err := filepath.Rel(targetDir, file)
+		if err != nil {
+			rel = file
+		}
+
+		source, err := probe.Inspect(ctx, file)
+		if err != nil {
+			source.Error = err.Error()
+			logger.LogWarnEvent("source_probe_failed", map[string]interface{}{
+				"key":     key,
+				"file":    file,
+				"error":   err.Error(),
+				"message": fmt.Sprintf("[%s] 音声ファイルの情報を取得できません: %v", key, err),
+			})
+		}
+		source.File = filepath.ToSlash(rel)
+		data.Sources = append(data.Sources, source)
+	}
+
+	data.Warnings = sourceWarnings(*data)
+	for _, warning := range data.Warnings {
+		logger.LogWarnEvent("source_warning", map[string]interface{}{
+			"key":     key,
+			"message": fmt.Sprintf("[%s] %s", key, warning),
+		})
+	}
+}
+
+// audioSelection は作品データに保存された変換対象の音声ファイルの選択結果を返します。
+// processDirectory を経由していない作品データでは、作品ディレクトリから改めて選択します。
+func audioSelection(cfg *config.Config, key string, data model.IndividualData) model.AudioSelection {
+	if data.Selection != nil {
+		return *data.Selection
+	}
+	return audioconverter.SelectAudioFiles(filepath.Join(cfg.DirSetting.SourceDir, key), cfg)
+}
+
+// sourceWarnings は ffprobe で調べた変換元の音声ファイルについての注意事項を返します。
+func sourceWarnings(data model.IndividualData) []string {
+	if !isBinaural(data) {
+		return nil
+	}
+	var warnings []string
+	for _, source := range data.Sources {
+		if source.Channels == 1 {
+			warnings = append(warnings, fmt.Sprintf("バイノーラル作品にモノラルの音声ファイルがあります: %s", source.File))
+		}
+	}
+	return warnings
+}
+
+// isBinaural は作品のタイトルまたは追加情報（ジャンルなど）からバイノーラル作品かどうかを判定します。
+func isBinaural(data model.IndividualData) bool {
+	texts := []string{data.AlbumTitle}
+	for _, value := range data.Additional {
+		texts = append(texts, value)
+	}
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		for _, keyword := range binauralKeywords {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sourceIndex は作品データの ffprobe の結果を変換元ファイルのパスで引けるようにします。
+func sourceIndex(targetDir string, data model.IndividualData) map[string]*model.AudioSource {
+	index := make(map[string]*model.AudioSource, len(data.Sources))
+	for i := range data.Sources {
+		index[filepath.Join(targetDir, filepath.FromSlash(data.Sources[i].File))] = &data.Sources[i]
+	}
+	return index
+}
+
+// formatSource は ffprobe で調べた変換元の情報を1行で表します。
+func formatSource(source model.AudioSource) string {
+	if source.Error != "" {
+		return "情報を取得できません (" + source.Error + ")"
+	}
+	parts := []string{source.Codec, fmt.Sprintf("%dHz", source.SampleRate)}
+	if source.BitDepth > 0 {
+		parts = append(parts, fmt.Sprintf("%dbit", source.BitDepth))
+	}
+	channels := fmt.Sprintf("%dch", source.Channels)
+	if source.ChannelLayout != "" {
+		channels += "(" + source.ChannelLayout + ")"
+	}
+	parts = append(parts, channels, formatClock(time.Duration(source.Duration*float64(time.Second))))
+	return strings.Join(parts, " ")
+}
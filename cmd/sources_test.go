@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// installFakeFFprobe は ffprobe の代わりに、ファイル名に "mono" を含む場合はモノラル、それ以外はステレオの
+// 44.1kHz / 16bit の WAV として JSON を出力するスクリプトを PATH の先頭に配置します。
+func installFakeFFprobe(t *testing.T) {
+	t.Helper()
+
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+for last; do :; done
+case "$last" in
+*mono*) ch=1; layout=mono ;;
+*) ch=2; layout=stereo ;;
+esac
+printf '{"streams":[{"codec_name":"pcm_s16le","sample_rate":"44100","bits_per_sample":16,"channels":%s,"channel_layout":"%s","tags":{"TITLE":"元のタイトル"}}],"format":{"duration":"90.500000","bit_rate":"1411200"}}' "$ch" "$layout"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "ffprobe"), []byte(script), 0755); err != nil {
+		t.Fatalf("ダミーffprobeの作成に失敗: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestProbeSources(t *testing.T) {
+	installFakeFFprobe(t)
+	cfg := newConversionTestConfig(t)
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "disc2/02_mono.wav": "two"})
+
+	data := model.IndividualData{AlbumTitle: "【バイノーラル】テスト作品"}
+	probeSources(context.Background(), cfg, key, &data)
+
+	if len(data.Sources) != 2 {
+		t.Fatalf("調べたファイル数: got %d, want 2", len(data.Sources))
+	}
+	first := data.Sources[0]
+	if first.File != "01.wav" || first.Codec != "pcm_s16le" || first.SampleRate != 44100 || first.BitDepth != 16 || first.Channels != 2 || first.Duration != 90.5 {
+		t.Errorf("ffprobeの結果が正しくありません: %+v", first)
+	}
+	if first.Tags["title"] != "元のタイトル" {
+		t.Errorf("既存のタグが記録されていません: %v", first.Tags)
+	}
+	if data.Sources[1].File != "disc2/02_mono.wav" {
+		t.Errorf("作品ディレクトリからの相対パスを記録すべき: %s", data.Sources[1].File)
+	}
+	if len(data.Warnings) != 1 || !strings.Contains(data.Warnings[0], "disc2/02_mono.wav") {
+		t.Errorf("バイノーラル作品のモノラル音源を警告すべき: %v", data.Warnings)
+	}
+
+	// バイノーラル作品でなければ警告しない
+	data = model.IndividualData{AlbumTitle: "テスト作品", Additional: map[string]string{"ジャンル": "癒し"}}
+	probeSources(context.Background(), cfg, key, &data)
+	if len(data.Warnings) != 0 {
+		t.Errorf("バイノーラル作品でない場合は警告しない: %v", data.Warnings)
+	}
+	data.Additional["ジャンル"] = "癒し / Binaural"
+	if !isBinaural(data) {
+		t.Error("追加情報のジャンルからバイノーラル作品と判定すべき")
+	}
+}
+
+func TestBuildAlbumPlanAvoidsUpsampling(t *testing.T) {
+	installFakeFFprobe(t)
+	cfg := newConversionTestConfig(t)
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+
+	data := model.IndividualData{AlbumTitle: "テスト作品"}
+	probeSources(context.Background(), cfg, key, &data)
+	// 02.wav は調べられなかったものとして、設定どおりのサンプリングレートで変換する
+	data.Sources = data.Sources[:1]
+
+	plan, err := buildAlbumPlan(cfg, key, data)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	args := plan.Tracks[0].Format.Args(plan.Tracks[0].InputFile, plan.Tracks[0].OutputFile, plan.Tracks[0].Metadata, "")
+	if i := slices.Index(args, "-ar"); i < 0 || args[i+1] != "44100" {
+		t.Errorf("44.1kHzの変換元を48kHzにアップサンプリングすべきでない: %q", args)
+	}
+	if plan.Tracks[0].Source == nil || plan.Tracks[0].Source.Duration != 90.5 {
+		t.Errorf("変換計画にffprobeの結果を設定すべき: %+v", plan.Tracks[0].Source)
+	}
+	args = plan.Tracks[1].Format.Args(plan.Tracks[1].InputFile, plan.Tracks[1].OutputFile, plan.Tracks[1].Metadata, "")
+	if i := slices.Index(args, "-ar"); i < 0 || args[i+1] != "48000" {
+		t.Errorf("ffprobeの結果がない場合は設定どおりのサンプリングレートにすべき: %q", args)
+	}
+	if plan.Tracks[0].Record.Encoder == plan.Tracks[1].Record.Encoder {
+		t.Error("サンプリングレートが異なる場合は変換記録のエンコード設定も異なるべき")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/manifest"
+)
+
+// 作品の出力は出力先と同じ親ディレクトリに作成した作業用ディレクトリに書き込み、
+// 全トラックの変換に成功した時点で rename により出力先と入れ替えます。
+const (
+	stagingDirPrefix = ".dls-encoder-staging-" // 変換中の作業用ディレクトリ
+	backupDirPrefix  = ".dls-encoder-old-"     // 入れ替え中に退避した前回の出力
+)
+
+// stagingDir は出力先ディレクトリに対応する作業用ディレクトリのパスを返します。
+func stagingDir(target string) string {
+	return filepath.Join(filepath.Dir(target), stagingDirPrefix+filepath.Base(target))
+}
+
+// backupDir は入れ替え中に前回の出力を退避するディレクトリのパスを返します。
+func backupDir(target string) string {
+	return filepath.Join(filepath.Dir(target), backupDirPrefix+filepath.Base(target))
+}
+
+// recoverAlbumDir は中断された前回の実行が残した作業用ディレクトリのうち、再利用できる変換記録がないものを削除します。
+// 入れ替えの途中で中断され出力先がない場合は、退避していた前回の出力を元に戻します。
+func recoverAlbumDir(target string) error {
+	staging := stagingDir(target)
+	if !resumable(staging) {
+		if err := os.RemoveAll(staging); err != nil {
+			return fmt.Errorf("作業用ディレクトリの削除に失敗: %w", err)
+		}
+	}
+
+	backup := backupDir(target)
+	if _, err := os.Stat(backup); err != nil {
+		return nil
+	}
+	exists, err := audioconverter.DirExists(target)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの確認に失敗: %w", err)
+	}
+	if exists {
+		if err := os.RemoveAll(backup); err != nil {
+			return fmt.Errorf("退避したディレクトリの削除に失敗: %w", err)
+		}
+		return nil
+	}
+	if err := os.Rename(backup, target); err != nil {
+		return fmt.Errorf("退避したディレクトリの復元に失敗: %w", err)
+	}
+	logger.LogWarnMessage(fmt.Sprintf("入れ替え途中で中断された出力を復元しました: %s", target))
+	return nil
+}
+
+// resumable は作業用ディレクトリに、中断された実行が保存した変換記録があるかどうかを返します。
+func resumable(staging string) bool {
+	record, err := manifest.Load(staging)
+	return err == nil && record != nil
+}
+
+// prepareStaging は作品の作業用ディレクトリを作成し、再エンコードしないトラックの出力を前回の出力から取り込みます。
+// 取り込みはハードリンクで行い、できない場合はコピーします。
+// 中断された前回の実行が残した作業用ディレクトリは、再利用するトラックの出力だけを残して引き継ぎます。
+// 失敗した場合も作業用ディレクトリは残し、次回の実行で再利用するか削除します。
+func prepareStaging(plan *albumPlan) (string, error) {
+	if err := recoverAlbumDir(plan.OutputDir); err != nil {
+		return "", err
+	}
+
+	staging := stagingDir(plan.OutputDir)
+	if err := pruneStaging(staging, plan.resumedFiles()); err != nil {
+		return "", fmt.Errorf("作業用ディレクトリの整理に失敗: %w", err)
+	}
+	if err := audioconverter.EnsureDirExists(staging); err != nil {
+		return "", fmt.Errorf("作業用ディレクトリの作成に失敗: %w", err)
+	}
+	for _, track := range plan.Tracks {
+		// output_layout が subdir の場合のディスクのディレクトリ
+		if err := audioconverter.EnsureDirExists(filepath.Join(staging, filepath.Dir(filepath.FromSlash(track.Record.Output)))); err != nil {
+			return "", fmt.Errorf("作業用ディレクトリの作成に失敗: %w", err)
+		}
+	}
+	for name := range plan.keepFiles() {
+		if name == manifest.FileName {
+			continue
+		}
+		rel := filepath.FromSlash(name)
+		if err := linkOrCopy(filepath.Join(plan.OutputDir, rel), filepath.Join(staging, rel)); err != nil {
+			return "", fmt.Errorf("変換済みファイルの取り込みに失敗: %w", err)
+		}
+	}
+	return staging, nil
+}
+
+// pruneStaging は作業用ディレクトリから、keep に含まれないファイルと空になったディレクトリを削除します。
+// 作業用ディレクトリがない場合は何もしません。
+func pruneStaging(staging string, keep map[string]bool) error {
+	var dirs []string
+	err := filepath.WalkDir(staging, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == staging && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != staging {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		if keep[filepath.ToSlash(rel)] {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+	// 深い階層から順に削除する（空でないディレクトリの削除は失敗するため残る）
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+	return nil
+}
+
+// linkOrCopy は src のハードリンクを dst に作成し、作成できない場合はコピーします。
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// swapAlbumDir は作業用ディレクトリを出力先と入れ替えます。
+// 前回の出力は入れ替えが完了するまで退避先に残し、失敗した場合は元に戻します。
+func swapAlbumDir(staging, target string) error {
+	if err := audioconverter.EnsureDirExists(filepath.Dir(target)); err != nil {
+		return fmt.Errorf("ディレクトリの作成に失敗: %w", err)
+	}
+
+	exists, err := audioconverter.DirExists(target)
+	if err != nil {
+		return fmt.Errorf("ディレクトリの確認に失敗: %w", err)
+	}
+	if !exists {
+		if err := os.Rename(staging, target); err != nil {
+			return fmt.Errorf("出力ディレクトリの配置に失敗: %w", err)
+		}
+		return nil
+	}
+
+	backup := backupDir(target)
+	if err := os.RemoveAll(backup); err != nil {
+		return fmt.Errorf("退避先の削除に失敗: %w", err)
+	}
+	if err := os.Rename(target, backup); err != nil {
+		return fmt.Errorf("前回の出力の退避に失敗: %w", err)
+	}
+	if err := os.Rename(staging, target); err != nil {
+		if restoreErr := os.Rename(backup, target); restoreErr != nil {
+			return fmt.Errorf("出力ディレクトリの入れ替えに失敗: %w", errors.Join(err, restoreErr))
+		}
+		return fmt.Errorf("出力ディレクトリの入れ替えに失敗: %w", err)
+	}
+	if err := os.RemoveAll(backup); err != nil {
+		// 入れ替えは完了しているため、次回の実行で削除する
+		logger.LogWarnMessage(fmt.Sprintf("前回の出力の削除に失敗: %v", err))
+	}
+	return nil
+}
+
+// cleanupStaging は出力先のツリーから、中断された実行が残した作業用ディレクトリと退避先を片付けます。
+// 変換記録が残っている作業用ディレクトリは、次回の変換で再利用するため削除しません。
+func cleanupStaging(root string) error {
+	var targets []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		switch {
+		case strings.HasPrefix(name, stagingDirPrefix):
+			targets = append(targets, filepath.Join(filepath.Dir(path), strings.TrimPrefix(name, stagingDirPrefix)))
+			return filepath.SkipDir
+		case strings.HasPrefix(name, backupDirPrefix):
+			targets = append(targets, filepath.Join(filepath.Dir(path), strings.TrimPrefix(name, backupDirPrefix)))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("出力ディレクトリの走査に失敗: %w", err)
+	}
+
+	for _, target := range targets {
+		if err := recoverAlbumDir(target); err != nil {
+			return err
+		}
+		logger.LogDebugEvent("staging_cleaned_up", map[string]interface{}{"outputDir": target})
+	}
+	return nil
+}
+
+// cleanupInterruptedOutputs は前回の実行が中断された際に残った作業用ディレクトリを片付けます。
+// 片付けに失敗しても作品ごとの変換開始時に再度削除するため、警告に留めます。
+func cleanupInterruptedOutputs(cfg *config.Config) {
+	root := filepath.Join(cfg.DirSetting.OutputDir, cfg.DirSetting.Mp3OutputDirName)
+	if err := cleanupStaging(root); err != nil {
+		logger.LogWarnMessage(fmt.Sprintf("中断された変換の片付けに失敗: %v", err))
+	}
+}
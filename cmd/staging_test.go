@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/manifest"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestConvertFilesKeepsPreviousOutputOnFailure(t *testing.T) {
+	installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	value := model.IndividualData{AlbumTitle: "テストアルバム"}
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two"})
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("初回の変換に失敗: %v", err)
+	}
+
+	// 変更したトラックの変換は成功するが、追加したトラックの変換に失敗する
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one (fixed)", "03_broken.wav": "broken"})
+	if err := convertFiles(ctx, cfg, key, value); err == nil {
+		t.Fatal("変換に失敗した場合はエラーを返すべき")
+	}
+
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	entries, err := os.ReadDir(plan.OutputDir)
+	if err != nil {
+		t.Fatalf("出力ディレクトリの読み込みに失敗: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != manifest.FileName || names[1] != "01.mp3" || names[2] != "02.mp3" {
+		t.Errorf("前回の出力がそのまま残っているべき: %v", names)
+	}
+	previous, err := manifest.Load(plan.OutputDir)
+	if err != nil || previous == nil || len(previous.Tracks) != 2 {
+		t.Errorf("前回の変換記録が残っているべき: %+v, %v", previous, err)
+	}
+	// 変換済みのトラックは次回の実行で再利用できるよう、作業用ディレクトリに記録とともに残る
+	partial, err := manifest.Load(stagingDir(plan.OutputDir))
+	if err != nil || partial == nil {
+		t.Fatalf("作業用ディレクトリに変換済みのトラックの記録を残すべき: %v", err)
+	}
+	if _, ok := partial.Find("01.mp3"); !ok {
+		t.Errorf("変換済みのトラックが記録されていません: %+v", partial.Tracks)
+	}
+	if _, ok := partial.Find("03_broken.mp3"); ok {
+		t.Error("失敗したトラックを記録してはいけない")
+	}
+
+	// 失敗したトラックを取り除くと、変換できた作品だけが入れ替わる
+	if err := os.Remove(filepath.Join(cfg.DirSetting.SourceDir, key, "03_broken.wav")); err != nil {
+		t.Fatal(err)
+	}
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("3回目の変換に失敗: %v", err)
+	}
+	if _, err := os.Stat(stagingDir(plan.OutputDir)); !os.IsNotExist(err) {
+		t.Errorf("作業用ディレクトリが残っています: %v", err)
+	}
+	current, err := manifest.Load(plan.OutputDir)
+	if err != nil || current == nil {
+		t.Fatalf("変換記録の読み込みに失敗: %v", err)
+	}
+	if track, ok := current.Find("01.mp3"); !ok || track.Source.Size != int64(len("one (fixed)")) {
+		t.Errorf("変更したトラックの記録が更新されていません: %+v", track)
+	}
+}
+
+func TestConvertFilesResumesInterruptedAlbum(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Workers = 1
+	ctx := context.Background()
+
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two", "03.wav": "three"})
+	value := model.IndividualData{
+		AlbumTitle:   "テストアルバム",
+		TrackMatches: []model.TrackMatch{{File: "03.wav", Track: 3, Title: "broken"}},
+	}
+
+	// 3トラック目の変換に失敗し、作品が完了しない
+	if err := convertFiles(ctx, cfg, key, value); err == nil {
+		t.Fatal("変換に失敗した場合はエラーを返すべき")
+	}
+	if n := countCalls(t, callLog); n != 3 {
+		t.Fatalf("ffmpegの呼び出し回数: got %d, want 3", n)
+	}
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if _, err := os.Stat(plan.OutputDir); !os.IsNotExist(err) {
+		t.Errorf("完了しなかった作品を出力してはいけない: %v", err)
+	}
+
+	// 次の実行では変換済みの2トラックを再利用し、残りのみ変換する
+	value.TrackMatches[0].Title = "エピローグ"
+	plan, err = buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	if pending := plan.pendingTracks(); len(pending) != 1 || pending[0].Record.Output != "03.mp3" {
+		t.Fatalf("中断前に変換済みのトラックは再利用すべき: %+v", pending)
+	}
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if n := countCalls(t, callLog); n != 4 {
+		t.Errorf("中断前に変換済みのトラックを再エンコードしてはいけない: got %d calls, want 4", n)
+	}
+	for _, name := range []string{"01.mp3", "02.mp3", "03.mp3"} {
+		if _, err := os.Stat(filepath.Join(plan.OutputDir, name)); err != nil {
+			t.Errorf("%s が出力されていません: %v", name, err)
+		}
+	}
+	current, err := manifest.Load(plan.OutputDir)
+	if err != nil || current == nil || len(current.Tracks) != 3 {
+		t.Errorf("全トラックを記録すべき: %+v, %v", current, err)
+	}
+	if _, err := os.Stat(stagingDir(plan.OutputDir)); !os.IsNotExist(err) {
+		t.Errorf("作業用ディレクトリが残っています: %v", err)
+	}
+}
+
+func TestConvertFilesKeepsResumedTracksOnCoverFailure(t *testing.T) {
+	callLog := installFakeFFmpeg(t)
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.Workers = 1
+	cfg.Setting.CoverProcessing = true
+	ctx := context.Background()
+
+	mainImage := filepath.Join(t.TempDir(), "RJ01234567_img_main.png")
+	file, err := os.Create(mainImage)
+	if err != nil {
+		t.Fatalf("メイン画像の作成に失敗: %v", err)
+	}
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatalf("PNG のエンコードに失敗: %v", err)
+	}
+	file.Close()
+	valid, err := os.ReadFile(mainImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(mainImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 大きさと更新日時を変えずに内容を置き換え、変換記録と一致したまま画像の加工だけを失敗させる
+	replaceImage := func(content []byte) {
+		t.Helper()
+		if err := os.WriteFile(mainImage, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(mainImage, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two", "03.wav": "three"})
+	value := model.IndividualData{
+		AlbumTitle:   "テストアルバム",
+		MainImage:    mainImage,
+		TrackMatches: []model.TrackMatch{{File: "03.wav", Track: 3, Title: "broken"}},
+	}
+	if err := convertFiles(ctx, cfg, key, value); err == nil {
+		t.Fatal("変換に失敗した場合はエラーを返すべき")
+	}
+	plan, err := buildAlbumPlan(cfg, key, value)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	staging := stagingDir(plan.OutputDir)
+
+	// メイン画像の加工に失敗しても、引き継いだトラックと途中までの変換記録は残す
+	value.TrackMatches[0].Title = "エピローグ"
+	replaceImage(make([]byte, len(valid)))
+	if err := convertFiles(ctx, cfg, key, value); err == nil {
+		t.Fatal("メイン画像の加工に失敗した場合はエラーを返すべき")
+	}
+	for _, name := range []string{"01.mp3", "02.mp3", manifest.FileName} {
+		if _, err := os.Stat(filepath.Join(staging, name)); err != nil {
+			t.Errorf("作業用ディレクトリの %s を削除してはいけない: %v", name, err)
+		}
+	}
+
+	// 画像が読み込めるようになれば、引き継いだトラックを再利用して残りのみ変換する
+	replaceImage(valid)
+	before := countCalls(t, callLog)
+	if err := convertFiles(ctx, cfg, key, value); err != nil {
+		t.Fatalf("再実行に失敗: %v", err)
+	}
+	if n := countCalls(t, callLog) - before; n != 1 {
+		t.Errorf("引き継いだトラックを再エンコードしてはいけない: got %d calls, want 1", n)
+	}
+}
+
+func TestSwapAlbumDir(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "album")
+	staging := stagingDir(target)
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(filepath.Join(target, "old.mp3"), "old")
+	writeFile(filepath.Join(staging, "new.mp3"), "new")
+
+	if err := swapAlbumDir(staging, target); err != nil {
+		t.Fatalf("swapAlbumDir でエラー: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "new.mp3")); err != nil {
+		t.Errorf("新しい出力が配置されていません: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "old.mp3")); !os.IsNotExist(err) {
+		t.Error("前回の出力が残っています")
+	}
+	for _, dir := range []string{staging, backupDir(target)} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s が残っています", dir)
+		}
+	}
+}
+
+func TestCleanupStaging(t *testing.T) {
+	root := t.TempDir()
+	brand := filepath.Join(root, "声優", "サークル")
+
+	// 変換中に中断された作品
+	interrupted := filepath.Join(brand, "【RJ01】作品1")
+	if err := os.MkdirAll(stagingDir(interrupted), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 変換記録を残して中断された作品
+	resumed := filepath.Join(brand, "【RJ03】作品3")
+	if err := os.MkdirAll(stagingDir(resumed), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.New("RJ03", []manifest.Track{{Output: "01.mp3"}}).Save(stagingDir(resumed)); err != nil {
+		t.Fatal(err)
+	}
+	// 入れ替えの途中で中断され、前回の出力が退避先にのみ残っている作品
+	swapped := filepath.Join(brand, "【RJ02】作品2")
+	if err := os.MkdirAll(backupDir(swapped), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir(swapped), "01.mp3"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cleanupStaging(root); err != nil {
+		t.Fatalf("cleanupStaging でエラー: %v", err)
+	}
+	if _, err := os.Stat(stagingDir(interrupted)); !os.IsNotExist(err) {
+		t.Error("作業用ディレクトリが削除されていません")
+	}
+	if !resumable(stagingDir(resumed)) {
+		t.Error("変換記録が残っている作業用ディレクトリは削除してはいけない")
+	}
+	if _, err := os.Stat(filepath.Join(swapped, "01.mp3")); err != nil {
+		t.Errorf("退避した出力が復元されていません: %v", err)
+	}
+
+	if err := cleanupStaging(filepath.Join(root, "missing")); err != nil {
+		t.Errorf("出力先が存在しない場合はエラーにすべきではない: %v", err)
+	}
+}
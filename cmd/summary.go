@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/loudness"
+)
+
+// 終了コード
+const (
+	exitOK             = 0 // すべての作品の処理に成功
+	exitFatal          = 1 // 設定エラーや中断などで処理全体が失敗
+	exitPartialFailure = 2 // 一部の作品の処理に失敗（成功した作品がある場合のみ）
+)
+
+// errNoAudioFiles は作品のディレクトリに変換対象の音声ファイルがないことを表します。
+var errNoAudioFiles = errors.New("音声ファイルが見つかりません")
+
+// failureReason は作品の処理に失敗した原因の分類です。
+type failureReason string
+
+const (
+	reasonParseError    failureReason = "parse_error"    // HTMLファイルが存在しない、または解析に失敗
+	reasonMissingImage  failureReason = "missing_image"  // メイン画像が見つからない
+	reasonNoAudio       failureReason = "no_audio"       // 変換対象の音声ファイルがない
+	reasonFFmpegFailure failureReason = "ffmpeg_failure" // ffmpeg による変換に失敗
+	reasonCanceled      failureReason = "canceled"       // 中断により変換が完了しなかった
+	reasonNeedsEncode   failureReason = "needs_encode"   // タグの書き換えでは更新できず、再エンコードが必要
+	reasonOther         failureReason = "other"          // 出力先の準備失敗など、その他のエラー
+)
+
+// label は分類の表示名を返します。
+func (r failureReason) label() string {
+	switch r {
+	case reasonParseError:
+		return "HTMLなし・解析失敗"
+	case reasonMissingImage:
+		return "メイン画像なし"
+	case reasonNoAudio:
+		return "音声ファイルなし"
+	case reasonFFmpegFailure:
+		return "ffmpeg変換失敗"
+	case reasonCanceled:
+		return "中断"
+	case reasonNeedsEncode:
+		return "再エンコードが必要"
+	default:
+		return "その他のエラー"
+	}
+}
+
+// classifyFailure はエラーから失敗の分類を判定します。
+func classifyFailure(err error) failureReason {
+	switch {
+	case errors.Is(err, errNoAudioFiles):
+		return reasonNoAudio
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return reasonCanceled
+	case errors.Is(err, errNeedsEncode):
+		return reasonNeedsEncode
+	case errors.Is(err, audioconverter.ErrConversionFailed), errors.Is(err, loudness.ErrMeasureFailed):
+		return reasonFFmpegFailure
+	default:
+		return reasonOther
+	}
+}
+
+// workFailure は1作品の処理失敗です。
+type workFailure struct {
+	Key    string
+	Reason failureReason
+	Detail string
+}
+
+// runSummary は作品ごとの処理結果を集計します。
+type runSummary struct {
+	Succeeded []string
+	Failures  []workFailure
+}
+
+// addFailure は作品の失敗を記録します。
+func (s *runSummary) addFailure(key string, reason failureReason, detail string) {
+	s.Failures = append(s.Failures, workFailure{Key: key, Reason: reason, Detail: detail})
+}
+
+// addError はエラーを分類して作品の失敗として記録します。
+func (s *runSummary) addError(key string, err error) {
+	s.addFailure(key, classifyFailure(err), err.Error())
+}
+
+// print は処理結果の一覧をログに出力します。
+func (s *runSummary) print() {
+	sort.Strings(s.Succeeded)
+	sort.SliceStable(s.Failures, func(i, j int) bool { return s.Failures[i].Key < s.Failures[j].Key })
+
+	total := len(s.Succeeded) + len(s.Failures)
+	logger.LogMessage(fmt.Sprintf("処理結果: 成功 %d / 失敗 %d (全 %d 作品)", len(s.Succeeded), len(s.Failures), total))
+	for _, failure := range s.Failures {
+		logger.LogWarnMessage(fmt.Sprintf("  [%s] %s: %s", failure.Key, failure.Reason.label(), failure.Detail))
+	}
+
+	failures := make([]map[string]interface{}, 0, len(s.Failures))
+	for _, failure := range s.Failures {
+		failures = append(failures, map[string]interface{}{
+			"key":    failure.Key,
+			"reason": string(failure.Reason),
+			"detail": failure.Detail,
+		})
+	}
+	logger.LogDebugEvent("run_summary", map[string]interface{}{
+		"succeeded": s.Succeeded,
+		"failures":  failures,
+	})
+}
+
+// err は失敗した作品がある場合に partialFailureError を返します。
+// 成功した作品が1つもない場合は一部の失敗ではないため、処理全体の失敗として通常のエラーを返します。
+func (s *runSummary) err() error {
+	if len(s.Failures) == 0 {
+		return nil
+	}
+	if len(s.Succeeded) == 0 {
+		return fmt.Errorf("すべての作品（%d 作品）の処理に失敗しました", len(s.Failures))
+	}
+	return &partialFailureError{Failed: len(s.Failures), Total: len(s.Succeeded) + len(s.Failures)}
+}
+
+// partialFailureError は一部の作品の処理に失敗したことを表します。
+// continue_on_error が有効な場合に、処理全体の失敗と区別するために使用します。
+type partialFailureError struct {
+	Failed int
+	Total  int
+}
+
+func (e *partialFailureError) Error() string {
+	return fmt.Sprintf("%d/%d 作品の処理に失敗しました", e.Failed, e.Total)
+}
+
+// exitCode はエラーに対応する終了コードを返します。
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var partial *partialFailureError
+	if errors.As(err, &partial) {
+		return exitPartialFailure
+	}
+	return exitFatal
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// tagDatePattern は販売日などの日付（"2023年05月12日 0時"、"2023/05/12"、"2023-05-12"）です。
+var tagDatePattern = regexp.MustCompile(`(\d{4})\D{1,3}(\d{1,2})\D{1,3}(\d{1,2})`)
+
+// tagYearPattern は日付の年です。
+var tagYearPattern = regexp.MustCompile(`\d{4}`)
+
+// workTags は [[tag_mapping]] の対応に従い、作品データから出力ファイルに書き込むタグを返します。
+// 値のない項目と、変換できない値（日付として読めない販売日など）は書き込みません。
+func workTags(cfg *config.Config, key string, data model.IndividualData) []audioconverter.Tag {
+	var tags []audioconverter.Tag
+	for _, mapping := range cfg.TagRules() {
+		value := tagSourceValue(mapping, key, data)
+		if value == "" {
+			continue
+		}
+
+		values := []string{value}
+		if mapping.Multiple {
+			values = splitTagValues(value)
+		}
+		converted := make([]string, 0, len(values))
+		for _, v := range values {
+			if v = convertTagValue(mapping.Convert, v); v != "" {
+				converted = append(converted, v)
+			}
+		}
+		if len(converted) == 0 {
+			continue
+		}
+		tags = append(tags, audioconverter.Tag{Name: mapping.Tag, Value: strings.Join(converted, mapping.SeparatorOrDefault())})
+	}
+	return tags
+}
+
+// supportedTags は出力形式に書き込めないタグを除いて返します。
+// M4A では ffmpeg が独自の名前のタグを黙って捨てるため、変換記録にも記録しないよう計画の時点で除きます。
+func supportedTags(key string, format audioconverter.Format, tags []audioconverter.Tag) []audioconverter.Tag {
+	supported := make([]audioconverter.Tag, 0, len(tags))
+	for _, tag := range tags {
+		if format.SupportsTag(tag.Name) {
+			supported = append(supported, tag)
+			continue
+		}
+		logger.LogDebugEvent("tag_unsupported", map[string]interface{}{
+			"key":     key,
+			"tag":     tag.Name,
+			"format":  format.Name,
+			"message": fmt.Sprintf("[%s] %s には %s のタグを書き込めないため省略します", key, format.Name, tag.Name),
+		})
+	}
+	return supported
+}
+
+// tagSourceValue は source に指定したキーのうち、最初に値のあるものの値を返します。
+func tagSourceValue(mapping config.TagMapping, key string, data model.IndividualData) string {
+	for _, source := range mapping.Source {
+		if source == config.TagSourceKey {
+			return key
+		}
+		if value := strings.TrimSpace(data.Additional[source]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// splitTagValues は HTML の解析で ", " 区切りにまとめた値（複数のジャンルなど）を分割します。
+func splitTagValues(value string) []string {
+	var values []string
+	for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '、' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// convertTagValue は tag_mapping の convert に従って値を変換します。変換できない場合は空文字列を返します。
+func convertTagValue(convert, value string) string {
+	switch convert {
+	case config.TagConvertDate:
+		match := tagDatePattern.FindStringSubmatch(value)
+		if match == nil {
+			return ""
+		}
+		month, _ := strconv.Atoi(match[2])
+		day, _ := strconv.Atoi(match[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return ""
+		}
+		return fmt.Sprintf("%s-%02d-%02d", match[1], month, day)
+	case config.TagConvertYear:
+		return tagYearPattern.FindString(value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestWorkTagsDefault(t *testing.T) {
+	cfg := &config.Config{Setting: config.Setting{ExtraTags: true}}
+	data := model.IndividualData{Additional: map[string]string{
+		"ジャンル":   "癒し, 耳かき, バイノーラル/ダミヘ",
+		"販売日":    "2023年05月12日 0時",
+		"シナリオ":   "テスト作家",
+		"イラスト":   "テスト絵師",
+		"作品形式":   "ボイス・ASMR",
+		"ファイル形式": "WAV",
+	}}
+
+	got := workTags(cfg, "RJ01234567", data)
+	want := []audioconverter.Tag{
+		{Name: "genre", Value: "癒し; 耳かき; バイノーラル/ダミヘ"},
+		{Name: "date", Value: "2023-05-12"},
+		{Name: "composer", Value: "テスト作家"},
+		{Name: "lyricist", Value: "テスト作家"},
+		{Name: "comment", Value: "RJ01234567"},
+		{Name: "CATALOGNUMBER", Value: "RJ01234567"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("既定の対応でのタグ:\ngot  %v\nwant %v", got, want)
+	}
+
+	// 値のない項目は書き込まない
+	got = workTags(cfg, "d_123456", model.IndividualData{Additional: map[string]string{"配信開始日": "2024/1/5"}})
+	want = []audioconverter.Tag{
+		{Name: "date", Value: "2024-01-05"},
+		{Name: "comment", Value: "d_123456"},
+		{Name: "CATALOGNUMBER", Value: "d_123456"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("追加情報が少ない作品のタグ:\ngot  %v\nwant %v", got, want)
+	}
+
+	cfg.Setting.ExtraTags = false
+	if got := workTags(cfg, "RJ01234567", data); len(got) != 0 {
+		t.Errorf("extra_tags = false の場合はタグを追加しない: %v", got)
+	}
+}
+
+func TestWorkTagsCustomMapping(t *testing.T) {
+	cfg := &config.Config{
+		Setting: config.Setting{ExtraTags: true},
+		TagMappings: []config.TagMapping{
+			{Tag: "year", Source: []string{"販売日"}, Convert: config.TagConvertYear},
+			{Tag: "genre", Source: []string{"ジャンル"}, Multiple: true, Separator: "/"},
+			{Tag: "ILLUSTRATOR", Source: []string{"イラスト"}},
+			{Tag: "date", Source: []string{"販売日"}, Convert: config.TagConvertDate},
+		},
+	}
+	data := model.IndividualData{Additional: map[string]string{
+		"ジャンル": "癒し、耳かき",
+		"販売日":  "近日発売",
+		"イラスト": "テスト絵師",
+	}}
+
+	got := workTags(cfg, "RJ01234567", data)
+	want := []audioconverter.Tag{
+		{Name: "genre", Value: "癒し/耳かき"},
+		{Name: "ILLUSTRATOR", Value: "テスト絵師"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("設定した対応でのタグ:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestBuildAlbumPlanWritesWorkTags(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.ExtraTags = true
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+
+	data := model.IndividualData{AlbumTitle: "テスト作品", Additional: map[string]string{"ジャンル": "癒し, 耳かき"}}
+	plan, err := buildAlbumPlan(cfg, key, data)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	metadata := plan.Tracks[0].Record.Metadata
+	if metadata["genre"] != "癒し; 耳かき" || metadata["CATALOGNUMBER"] != key {
+		t.Errorf("変換記録のメタデータに作品データのタグを含めるべき: %v", metadata)
+	}
+}
+
+func TestBuildAlbumPlanOmitsUnsupportedTags(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	cfg.Setting.ExtraTags = true
+	cfg.Setting.OutputFormat = audioconverter.FormatNameM4A
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+
+	data := model.IndividualData{AlbumTitle: "テスト作品", Additional: map[string]string{"ジャンル": "癒し", "シナリオ": "作家"}}
+	plan, err := buildAlbumPlan(cfg, key, data)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	// M4A では ffmpeg が書き込めない独自の名前のタグは、引数にも変換記録にも含めない
+	metadata := plan.Tracks[0].Record.Metadata
+	if metadata["genre"] != "癒し" || metadata["composer"] != "作家" || metadata["comment"] != key {
+		t.Errorf("M4A に書き込めるタグは残すべき: %v", metadata)
+	}
+	for _, name := range []string{"lyricist", "CATALOGNUMBER"} {
+		if _, ok := metadata[name]; ok {
+			t.Errorf("M4A に書き込めない %s は省略すべき: %v", name, metadata)
+		}
+	}
+}
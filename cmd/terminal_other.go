@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// defaultTerminalWidth は端末の幅を取得できない環境で使用する幅です。
+const defaultTerminalWidth = 80
+
+// terminalWidth は端末の幅（桁数）を返します。
+// この環境では端末から取得できないため、環境変数 COLUMNS または既定の幅を使用します。
+func terminalWidth(*os.File) int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultTerminalWidth
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"github.com/kkryama/dls-encoder/internal/tracklist"
+)
+
+// matchTrackList は作品データのトラック一覧と変換対象の音声ファイルを対応付け、結果と注意事項を作品データに設定します。
+// 再生時間には ffprobe で調べた結果を使用するため、probeSources の後に呼び出します。
+func matchTrackList(cfg *config.Config, key string, data *model.IndividualData) {
+	if len(data.TrackList) == 0 {
+		return
+	}
+	targetDir := filepath.Join(cfg.DirSetting.SourceDir, key)
+	if _, err := os.Stat(targetDir); err != nil {
+		return
+	}
+
+	audioFiles := audioSelection(cfg, key, *data).Files
+	sources := sourceIndex(targetDir, *data)
+	files := make([]tracklist.File, len(audioFiles))
+	for i, file := range audioFiles {
+		name := filepath.Base(file)
+		files[i].Name = strings.TrimSuffix(name, filepath.Ext(name))
+		if source := sources[filepath.Clean(file)]; source != nil {
+			files[i].Duration = source.Duration
+		}
+	}
+	entries := make([]tracklist.Entry, len(data.TrackList))
+	for i, track := range data.TrackList {
+		entries[i] = tracklist.Entry{Title: track.TrackTitle, Duration: tracklist.ParseDuration(track.TrackDuration)}
+	}
+
+	result := tracklist.Match(files, entries)
+	data.TrackMatches = nil
+	for i, pair := range result.Pairs {
+		if pair.Entry < 0 {
+			continue
+		}
+		rel, err := filepath.Rel(targetDir, audioFiles[i])
+		if err != nil {
+			rel = audioFiles[i]
+		}
+		data.TrackMatches = append(data.TrackMatches, model.TrackMatch{
+			File:   filepath.ToSlash(rel),
+			Track:  pair.Entry + 1,
+			Title:  data.TrackList[pair.Entry].TrackTitle,
+			Method: pair.Method,
+		})
+	}
+
+	logger.LogDebugEvent("track_list_matched", map[string]interface{}{
+		"key":        key,
+		"trackCount": len(entries),
+		"fileCount":  len(files),
+		"matched":    len(data.TrackMatches),
+	})
+	for _, issue := range result.Issues {
+		warning := "トラック一覧との対応: " + issue
+		data.Warnings = append(data.Warnings, warning)
+		logger.LogWarnEvent("track_list_mismatch", map[string]interface{}{
+			"key":     key,
+			"message": fmt.Sprintf("[%s] %s", key, warning),
+		})
+	}
+}
+
+// trackTitles はトラック一覧と対応付けた音声ファイルのタイトルを、変換元ファイルのパスで引けるようにします。
+func trackTitles(targetDir string, data model.IndividualData) map[string]string {
+	titles := make(map[string]string, len(data.TrackMatches))
+	for _, match := range data.TrackMatches {
+		if match.Title != "" {
+			titles[filepath.Join(targetDir, filepath.FromSlash(match.File))] = match.Title
+		}
+	}
+	return titles
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestMatchTrackListSetsTitles(t *testing.T) {
+	installFakeFFprobe(t)
+	cfg := newConversionTestConfig(t)
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one", "02.wav": "two", "おまけ.wav": "bonus"})
+
+	data := model.IndividualData{
+		AlbumTitle: "テスト作品",
+		TrackList: []model.Track{
+			{TrackTitle: "プロローグ", TrackDuration: "1分30秒"},
+			{TrackTitle: "添い寝", TrackDuration: "5分0秒"},
+		},
+	}
+	probeSources(context.Background(), cfg, key, &data)
+	matchTrackList(cfg, key, &data)
+
+	// ダミーの ffprobe はすべて 90.5 秒を返すため、再生時間の一致する「プロローグ」のみ対応付ける
+	if len(data.TrackMatches) != 1 || data.TrackMatches[0].File != "01.wav" || data.TrackMatches[0].Title != "プロローグ" || data.TrackMatches[0].Track != 1 {
+		t.Fatalf("トラック一覧との対応: got %+v", data.TrackMatches)
+	}
+	warnings := strings.Join(data.Warnings, "\n")
+	for _, want := range []string{"2 トラックですが、音声ファイルは 3 ファイル", "対応付けられない音声ファイル: 02、おまけ", "対応する音声ファイルがないトラック: 添い寝"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("警告に %q が含まれていません: %v", want, data.Warnings)
+		}
+	}
+
+	plan, err := buildAlbumPlan(cfg, key, data)
+	if err != nil {
+		t.Fatalf("変換計画の作成に失敗: %v", err)
+	}
+	var titles []string
+	for _, track := range plan.Tracks {
+		titles = append(titles, track.Metadata.TrackName)
+	}
+	if strings.Join(titles, ",") != "プロローグ,02,おまけ" {
+		t.Errorf("タグのトラックタイトル: got %v", titles)
+	}
+	if plan.Tracks[0].Record.Metadata["title"] != "プロローグ" {
+		t.Errorf("変換記録にも作品ページのタイトルを記録すべき: %v", plan.Tracks[0].Record.Metadata)
+	}
+}
+
+func TestMatchTrackListByOrder(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	key := "RJ01234567"
+	writeSourceFiles(t, cfg, key, map[string]string{"track2.wav": "two", "track10.wav": "ten"})
+
+	// ffprobe の結果がなくても、トラック数が同じなら並び順（自然順）で対応付ける
+	data := model.IndividualData{TrackList: []model.Track{{TrackTitle: "前編"}, {TrackTitle: "後編"}}}
+	matchTrackList(cfg, key, &data)
+	if len(data.TrackMatches) != 2 || data.TrackMatches[0].File != "track2.wav" || data.TrackMatches[0].Title != "前編" || data.TrackMatches[1].Title != "後編" {
+		t.Errorf("並び順での対応: got %+v", data.TrackMatches)
+	}
+	if len(data.Warnings) != 0 {
+		t.Errorf("すべて対応付けられた場合は警告しない: %v", data.Warnings)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/audioconverter"
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/report"
+	"github.com/kkryama/dls-encoder/internal/storage"
+	"github.com/kkryama/dls-encoder/internal/watcher"
+)
+
+// watchOptions は watch サブコマンドの動作を指定します。
+type watchOptions struct {
+	runOptions
+	Settle   time.Duration // 最後の変更からこの時間書き込みがなければ処理を開始する
+	Interval time.Duration // キューを確認する間隔
+}
+
+// runWatch は source_dir・html_dir・image_dir を監視し、追加された作品を自動で変換します。
+// ctx がキャンセルされるまで終了しません。
+func runWatch(ctx context.Context, cfg *config.Config, opts watchOptions) error {
+	logger.LogMessage("dls-encoder version: " + version)
+
+	if err := validateDependencies(); err != nil {
+		return fmt.Errorf("依存関係の確認に失敗: %w", err)
+	}
+
+	logFile, err := setupLogging(cfg.DirSetting.LogDir, cfg.Setting.Debug)
+	if err != nil {
+		return fmt.Errorf("ログ設定の初期化に失敗: %w", err)
+	}
+	defer func() {
+		if logFile != nil {
+			if err := logFile.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "ログファイルのクローズでエラー: %v\n", err)
+			}
+		}
+	}()
+
+	cleanupInterruptedOutputs(cfg)
+
+	if !cfg.Setting.Convert {
+		logger.LogWarnMessage("convert = false のため、作品の検知時はHTMLの解析のみ行います")
+	}
+
+	w, err := watcher.New(watcher.Roots{
+		SourceDir: cfg.DirSetting.SourceDir,
+		HtmlDir:   cfg.DirSetting.HtmlDir,
+		ImageDir:  cfg.DirSetting.ImageDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 監視中に処理した作品は1つのレポートにまとめ、処理のたびに最新の結果で保存し直す
+	rep := report.New(version, "watch")
+	queue := watcher.NewQueue()
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- w.Run(ctx, func(key string) {
+			if !watchSelected(opts.Targets, key) {
+				return
+			}
+			logger.LogDebugEvent("watch_change_detected", map[string]interface{}{"key": key})
+			queue.Touch(key, time.Now())
+		}, func(err error) {
+			logger.LogWarnMessage(err.Error())
+		})
+	}()
+
+	logger.LogMessage(fmt.Sprintf("監視を開始しました（待機時間: %s）: %s, %s, %s",
+		opts.Settle, cfg.DirSetting.SourceDir, cfg.DirSetting.HtmlDir, cfg.DirSetting.ImageDir))
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.LogMessage(fmt.Sprintf("監視を終了しました。未処理の作品: %v", queue.Pending()))
+			return nil
+		case err := <-watchErr:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		case <-ticker.C:
+			reasons := make(map[string]string)
+			keys, blocked := queue.Take(time.Now(), opts.Settle, func(key string) bool {
+				reasons[key] = watchMissing(cfg, key)
+				return reasons[key] == ""
+			})
+			for _, key := range blocked {
+				logger.LogMessage(fmt.Sprintf("[%s] %s。揃い次第処理します", key, reasons[key]))
+			}
+			if len(keys) > 0 {
+				processWatchedWorks(ctx, cfg, keys, rep, logFile)
+			}
+		}
+	}
+}
+
+// watchSelected は作品キーが絞り込み条件に一致するかどうかを返します。
+func watchSelected(targets storage.TargetSelector, key string) bool {
+	if targets.Empty() {
+		return true
+	}
+	selected, _ := targets.Select([]string{key})
+	return len(selected) > 0
+}
+
+// watchMissing は作品の処理に必要なファイルのうち、揃っていないものを返します。
+// すべて揃っている場合は空文字列を返します。
+func watchMissing(cfg *config.Config, key string) string {
+	sourceDir := filepath.Join(cfg.DirSetting.SourceDir, key)
+	if info, err := os.Stat(sourceDir); err != nil || !info.IsDir() {
+		return "音声ディレクトリがありません"
+	}
+	if len(audioconverter.FindAudioFiles(sourceDir, cfg)) == 0 {
+		return "音声ファイルがありません"
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DirSetting.HtmlDir, key+".html")); err != nil {
+		return "HTMLファイルがありません"
+	}
+	if cfg.Setting.SetMainImage {
+		if image, err := storage.FindMainImage(cfg.DirSetting.ImageDir, key); err != nil || image == "" {
+			return "メイン画像がありません"
+		}
+	}
+	return ""
+}
+
+// processWatchedWorks は検知した作品に対してHTML解析から変換までを実行します。
+// 失敗しても監視は継続するため、エラーはログに出力するのみです。
+func processWatchedWorks(ctx context.Context, cfg *config.Config, keys []string, rep *report.Report, logFile *os.File) {
+	logger.LogMessage(fmt.Sprintf("作品を検知しました: %v", keys))
+
+	data, notApplicableData, missingImageData, err := processDirectories(ctx, cfg, keys)
+	if err != nil {
+		logger.LogWarnMessage(fmt.Sprintf("ディレクトリの処理に失敗: %v", err))
+		return
+	}
+	err = handleConversion(ctx, cfg, data, notApplicableData, missingImageData, rep)
+	saveReport(cfg, rep, logFile, err)
+	if err != nil {
+		logger.LogWarnMessage(fmt.Sprintf("%v の変換処理に失敗: %v", keys, err))
+		return
+	}
+	logger.LogMessage(fmt.Sprintf("作品の処理が完了しました: %v", getSortedKeys(data)))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/storage"
+)
+
+func TestWatchMissing(t *testing.T) {
+	cfg := newConversionTestConfig(t)
+	key := "RJ01234567"
+
+	if got := watchMissing(cfg, key); got != "音声ディレクトリがありません" {
+		t.Errorf("音声ディレクトリなし: got %q", got)
+	}
+
+	writeSourceFiles(t, cfg, key, map[string]string{"01.wav": "one"})
+	if got := watchMissing(cfg, key); got != "HTMLファイルがありません" {
+		t.Errorf("HTMLなし: got %q", got)
+	}
+
+	if err := os.MkdirAll(cfg.DirSetting.HtmlDir, 0755); err != nil {
+		t.Fatalf("HTMLディレクトリの作成に失敗: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.DirSetting.HtmlDir, key+".html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("HTMLファイルの作成に失敗: %v", err)
+	}
+	if got := watchMissing(cfg, key); got != "" {
+		t.Errorf("必要なファイルが揃っている場合は空文字列を返すべき: got %q", got)
+	}
+
+	cfg.Setting.SetMainImage = true
+	if got := watchMissing(cfg, key); got != "メイン画像がありません" {
+		t.Errorf("メイン画像なし: got %q", got)
+	}
+}
+
+func TestWatchSelected(t *testing.T) {
+	if !watchSelected(storage.TargetSelector{}, "RJ01234567") {
+		t.Error("条件なしの場合はすべての作品が対象になるべき")
+	}
+	targets := storage.TargetSelector{Match: []string{"RJ*"}}
+	if !watchSelected(targets, "RJ01234567") || watchSelected(targets, "d_123456") {
+		t.Error("globパターンによる絞り込みが正しくありません")
+	}
+}
@@ -0,0 +1,151 @@
+package audioconverter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/probe"
+)
+
+// Chapter はオーディオブックとして1ファイルにまとめる音声ファイルの1つです。
+type Chapter struct {
+	InputFile string        // 変換元ファイルのパス
+	Title     string        // チャプター名
+	Duration  time.Duration // 再生時間（0の場合は変換時に ffprobe で取得する）
+}
+
+// AudiobookFormat は audiobook の設定値（m4b / mp3）に対応する出力形式を返します。
+// m4b は M4A と同じ AAC のエンコード設定で、拡張子を .m4b にします。
+func AudiobookFormat(mode string) (Format, error) {
+	switch mode {
+	case config.AudiobookM4B:
+		format := formats[FormatNameM4A]
+		format.Name = config.AudiobookM4B
+		format.Extension = ".m4b"
+		return format, nil
+	case config.AudiobookMP3:
+		format := formats[FormatNameMP3]
+		// トラックごとの MP3 の変換記録と区別するため、MP3のみ対応していた頃の引数は使用しない
+		format.legacy = false
+		return format, nil
+	}
+	return Format{}, fmt.Errorf("対応していないオーディオブックの形式です: %s", mode)
+}
+
+// ChapterDurations は再生時間が不明なチャプターの再生時間を ffprobe で取得したチャプターの一覧を返します。
+func ChapterDurations(ctx context.Context, chapters []Chapter) ([]Chapter, error) {
+	chapters = slices.Clone(chapters)
+	for i := range chapters {
+		if chapters[i].Duration > 0 {
+			continue
+		}
+		duration, err := probe.Duration(ctx, chapters[i].InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("チャプターの位置を求めるための再生時間の取得に失敗: %w", err)
+		}
+		chapters[i].Duration = duration
+	}
+	return chapters, nil
+}
+
+// chapterMetadata はチャプターを記述した ffmetadata の内容を返します。
+// 各チャプターは前のチャプターの終わりから始まり、位置はミリ秒単位で記述します。
+func chapterMetadata(chapters []Chapter) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	var start time.Duration
+	for _, chapter := range chapters {
+		end := start + chapter.Duration
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", start.Milliseconds(), end.Milliseconds(), escapeFFMetadata(chapter.Title))
+		start = end
+	}
+	return b.String()
+}
+
+// AudiobookArgs は chapters の変換元を順に連結して1ファイルに変換する ffmpeg コマンドの引数を返します。
+// chapterMetadata はチャプターを記述した ffmetadata ファイルのパスです。
+// 連結には concat フィルタを使用するため、サンプリングレートやチャンネル数が異なる変換元もまとめられます。
+func (f Format) AudiobookArgs(chapters []Chapter, outputFile string, metadata MP3Metadata, chapterMetadata string) []string {
+	var cmdArgs []string
+	var graph strings.Builder
+	for i, chapter := range chapters {
+		cmdArgs = append(cmdArgs, "-i", chapter.InputFile)
+		fmt.Fprintf(&graph, "[%d:a]", i)
+	}
+	fmt.Fprintf(&graph, "concat=n=%d:v=0:a=1", len(chapters))
+	if filters := f.audioFilters(); len(filters) > 0 {
+		// 複合フィルタの出力には -af を指定できないため、連結の後に続ける
+		graph.WriteString("," + strings.Join(filters, ","))
+	}
+	graph.WriteString("[a]")
+
+	inputs := len(chapters)
+	hasCover := metadata.CoverImage != nil && *metadata.CoverImage != ""
+	if hasCover {
+		cmdArgs = append(cmdArgs, "-i", *metadata.CoverImage)
+		inputs++
+	}
+	cmdArgs = append(cmdArgs,
+		"-f", "ffmetadata", "-i", chapterMetadata, // チャプターを記述したファイル
+		"-filter_complex", graph.String(),
+		"-map", "[a]",
+	)
+	if hasCover {
+		codec := "mjpeg"
+		if metadata.CoverReady {
+			codec = "copy"
+		}
+		cmdArgs = append(cmdArgs,
+			"-map", strconv.Itoa(len(chapters))+":v",
+			"-c:v", codec,
+			"-metadata:s:v", "title=Album cover",
+			"-disposition:v", "attached_pic",
+		)
+	}
+	cmdArgs = append(cmdArgs,
+		"-map_metadata", "-1", // 変換元のタグは引き継がず、設定したタグのみを書き込む
+		"-map_chapters", strconv.Itoa(inputs),
+	)
+
+	cmdArgs = append(cmdArgs, f.encodeArgs()...)
+	for _, tag := range metadata.Tags() {
+		cmdArgs = append(cmdArgs, "-metadata", tag.Name+"="+tag.Value)
+	}
+	cmdArgs = append(cmdArgs, f.muxArgs...)
+	return append(cmdArgs, "-y", outputFile)
+}
+
+// ConvertAudiobook は chapters の変換元を順に連結し、トラックの境界にチャプターを設定した1ファイルに変換します。
+// 再生時間が不明なチャプターは ffprobe で再生時間を取得します。
+func ConvertAudiobook(ctx context.Context, chapters []Chapter, outputFile string, metadata MP3Metadata, format Format, opts ConvertOptions) error {
+	chapters, err := ChapterDurations(ctx, chapters)
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrConversionFailed, outputFile, err)
+	}
+	path, err := writeFFMetadata(chapterMetadata(chapters))
+	if err != nil {
+		return fmt.Errorf("%w %s: チャプターの準備に失敗: %w", ErrConversionFailed, outputFile, err)
+	}
+	defer os.Remove(path)
+
+	cmdArgs := format.AudiobookArgs(chapters, outputFile, metadata, path)
+	if opts.OnProgress != nil {
+		cmdArgs = append(progressArgs(), cmdArgs...)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", cmdArgs...)
+	if err := runFfmpeg(cmd, opts.OnProgress); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("変換処理がキャンセルされました: %w", ctx.Err())
+		}
+		return fmt.Errorf("%w %s (コマンド引数: %v): %w", ErrConversionFailed, outputFile, cmdArgs, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package audioconverter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+)
+
+func TestAudiobookFormat(t *testing.T) {
+	m4b, err := AudiobookFormat(config.AudiobookM4B)
+	if err != nil || m4b.Extension != ".m4b" || m4b.encoder != "aac" {
+		t.Errorf("m4b は AAC で拡張子を .m4b にすべき: %+v, %v", m4b, err)
+	}
+	mp3, err := AudiobookFormat(config.AudiobookMP3)
+	if err != nil || mp3.Name != FormatNameMP3 || mp3.Signature() == FormatMP3().Signature() {
+		t.Errorf("mp3 はトラックごとの MP3 と区別できる Signature にすべき: %q, %v", mp3.Signature(), err)
+	}
+	if _, err := AudiobookFormat(config.AudiobookOff); err == nil {
+		t.Error("off を指定した場合はエラーが発生すべき")
+	}
+}
+
+func TestAudiobookArgs(t *testing.T) {
+	format, _ := AudiobookFormat(config.AudiobookM4B)
+	format = format.WithFilter("volume=0.5", 44100)
+	cover := "/img/cover.jpg"
+	chapters := []Chapter{{InputFile: "/src/01.wav"}, {InputFile: "/src/02.flac"}}
+	args := format.AudiobookArgs(chapters, "/out/作品.m4b", MP3Metadata{AlbumTitle: "作品", TrackName: "作品", CoverImage: &cover}, "chapters.ffmetadata")
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"-i /src/01.wav -i /src/02.flac -i /img/cover.jpg -f ffmetadata -i chapters.ffmetadata",
+		"-filter_complex [0:a][1:a]concat=n=2:v=0:a=1,volume=0.5[a] -map [a]",
+		"-map 2:v -c:v mjpeg",
+		"-disposition:v attached_pic",
+		"-map_metadata -1 -map_chapters 3",
+		"-metadata album=作品",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("引数に %q が含まれていません: %s", want, joined)
+		}
+	}
+	if slices.Contains(args, "-af") {
+		t.Errorf("複合フィルタの出力に -af を指定してはいけない: %s", joined)
+	}
+	if args[len(args)-1] != "/out/作品.m4b" {
+		t.Errorf("最後の引数は出力ファイルにすべき: %s", args[len(args)-1])
+	}
+
+	// 画像なしの場合、チャプターのファイルは変換元の直後の入力になる
+	args = format.AudiobookArgs(chapters, "/out/作品.m4b", MP3Metadata{}, "chapters.ffmetadata")
+	if joined := strings.Join(args, " "); !strings.Contains(joined, "-map_chapters 2") || strings.Contains(joined, "attached_pic") {
+		t.Errorf("画像なしの引数が正しくありません: %s", joined)
+	}
+}
+
+func TestChapterMetadata(t *testing.T) {
+	got := chapterMetadata([]Chapter{
+		{Title: "プロローグ", Duration: 61500 * time.Millisecond},
+		{Title: "本編=前半; #1", Duration: 30 * time.Second},
+	})
+	want := ";FFMETADATA1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=61500\ntitle=プロローグ\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=61500\nEND=91500\ntitle=本編\\=前半\\; \\#1\n"
+	if got != want {
+		t.Errorf("チャプターの記述:\ngot  %q\nwant %q", got, want)
+	}
+}
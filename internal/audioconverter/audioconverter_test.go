@@ -4,9 +4,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
 )
 
 func TestEnsureDirExists(t *testing.T) {
@@ -195,3 +197,139 @@ func TestConvertFileToMp3_CommandGeneration(t *testing.T) {
 		t.Error("ffmpegコマンドの生成に失敗")
 	}
 }
+
+func TestFfmpegArgs(t *testing.T) {
+	coverImage := "/image/cover.jpg"
+	metadata := MP3Metadata{
+		Artist:      "テストアーティスト",
+		AlbumArtist: "テストアルバムアーティスト",
+		AlbumTitle:  "テストアルバム",
+		TrackName:   "テストトラック",
+		CoverImage:  &coverImage,
+	}
+
+	got := FfmpegArgs("/source/test.wav", "/output/test.mp3", metadata)
+	want := []string{
+		"-i", "/source/test.wav",
+		"-i", coverImage,
+		"-map", "0:a",
+		"-map", "1:v",
+		"-c:v", "mjpeg",
+		"-metadata:s:v", "title=Album cover",
+		"-c:a", "libmp3lame",
+		"-b:a", "320k",
+		"-ar", "48000",
+		"-metadata", "artist=テストアーティスト",
+		"-metadata", "album_artist=テストアルバムアーティスト",
+		"-metadata", "album=テストアルバム",
+		"-metadata", "title=テストトラック",
+		"-id3v2_version", "3",
+		"-y",
+		"/output/test.mp3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FfmpegArgs:\n got  %v\n want %v", got, want)
+	}
+
+	metadata.CoverImage = nil
+	for _, arg := range FfmpegArgs("/source/test.wav", "/output/test.mp3", metadata) {
+		if arg == "-map" {
+			t.Error("画像がない場合は -map を指定すべきではありません")
+		}
+	}
+}
+
+func TestSelectAudioFilesReportsSkipped(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{Setting: config.Setting{ExcludeStrings: []string{"SEなし"}}}
+
+	for _, name := range []string{"track1.wav", "track1.mp3", "track1.flac", "track2_SEなし.wav", "readme.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("dummy audio data"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	selection := SelectAudioFiles(tempDir, cfg)
+	wav := filepath.Join(tempDir, "track1.wav")
+	if !reflect.DeepEqual(selection.Files, []string{wav}) {
+		t.Errorf("Files: got %v, want %v", selection.Files, []string{wav})
+	}
+
+	want := []model.SkippedFile{
+		{Path: filepath.Join(tempDir, "track2_SEなし.wav"), Reason: SkipReasonExcluded, ExcludeString: "SEなし"},
+		{Path: filepath.Join(tempDir, "track1.flac"), Reason: SkipReasonLowerPriority, PreferredPath: wav},
+		{Path: filepath.Join(tempDir, "track1.mp3"), Reason: SkipReasonLowerPriority, PreferredPath: wav},
+	}
+	if !reflect.DeepEqual(selection.Skipped, want) {
+		t.Errorf("Skipped: got %+v, want %+v", selection.Skipped, want)
+	}
+}
+
+func TestSelectAudioFilesSameNameInSubdirs(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{}
+
+	for _, name := range []string{"本編/01.wav", "本編/01.mp3", "おまけ/01.wav", "wav/特典/01.wav", "mp3/特典/01.mp3"} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("ディレクトリの作成に失敗: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("dummy audio data"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(tempDir, filepath.FromSlash(name)) }
+
+	// 別のディレクトリの同名ファイルはそれぞれ変換し、形式の優先度は同じディレクトリ（形式だけを表すディレクトリは区別しない）の中で比較する
+	selection := SelectAudioFiles(tempDir, cfg)
+	wantFiles := []string{path("本編/01.wav"), path("おまけ/01.wav"), path("wav/特典/01.wav")}
+	if !reflect.DeepEqual(selection.Files, wantFiles) {
+		t.Errorf("Files: got %v, want %v", selection.Files, wantFiles)
+	}
+	wantSkipped := []model.SkippedFile{
+		{Path: path("本編/01.mp3"), Reason: SkipReasonLowerPriority, PreferredPath: path("本編/01.wav")},
+		{Path: path("mp3/特典/01.mp3"), Reason: SkipReasonLowerPriority, PreferredPath: path("wav/特典/01.wav")},
+	}
+	if !reflect.DeepEqual(selection.Skipped, wantSkipped) {
+		t.Errorf("Skipped:\n got  %+v\n want %+v", selection.Skipped, wantSkipped)
+	}
+}
+
+func TestSelectAudioFilesInputFormats(t *testing.T) {
+	path := func(dir, name string) string { return filepath.Join(dir, name) }
+	writeFiles := func(t *testing.T, names ...string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for _, name := range names {
+			if err := os.WriteFile(path(dir, name), []byte("dummy audio data"), 0644); err != nil {
+				t.Fatalf("テストファイルの作成に失敗: %v", err)
+			}
+		}
+		return dir
+	}
+
+	// 非可逆の形式を先に並べても、可逆の形式を優先する
+	dir := writeFiles(t, "a.mp3", "a.flac", "b.opus", "b.mp3", "c.flac", "c.wav", "d.m4a", "e.opus")
+	cfg := &config.Config{Setting: config.Setting{InputFormats: []string{"mp3", ".OPUS", "flac", "wav"}}}
+	selection := SelectAudioFiles(dir, cfg)
+	want := []string{path(dir, "a.flac"), path(dir, "b.mp3"), path(dir, "c.flac"), path(dir, "e.opus")}
+	if !reflect.DeepEqual(selection.Files, want) {
+		t.Errorf("Files: got %v, want %v", selection.Files, want)
+	}
+	wantSkipped := []model.SkippedFile{
+		{Path: path(dir, "a.mp3"), Reason: SkipReasonLowerPriority, PreferredPath: path(dir, "a.flac")},
+		{Path: path(dir, "b.opus"), Reason: SkipReasonLowerPriority, PreferredPath: path(dir, "b.mp3")},
+		{Path: path(dir, "c.wav"), Reason: SkipReasonLowerPriority, PreferredPath: path(dir, "c.flac")},
+	}
+	if !reflect.DeepEqual(selection.Skipped, wantSkipped) {
+		t.Errorf("Skipped:\n got  %+v\n want %+v", selection.Skipped, wantSkipped)
+	}
+
+	// input_formats が未設定の場合は既定の形式をすべて対象にする
+	dir = writeFiles(t, "01.aiff", "02.m4a", "02.wv", "03.dsf", "04.ape", "05.ogg")
+	selection = SelectAudioFiles(dir, &config.Config{})
+	want = []string{path(dir, "01.aiff"), path(dir, "02.wv"), path(dir, "03.dsf"), path(dir, "04.ape"), path(dir, "05.ogg")}
+	if !reflect.DeepEqual(selection.Files, want) {
+		t.Errorf("既定の形式の Files: got %v, want %v", selection.Files, want)
+	}
+}
@@ -2,19 +2,81 @@ package audioconverter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 )
 
-// MP3Metadata はMP3ファイルのメタデータを格納する構造体です。
+// ErrConversionFailed は ffmpeg によるファイル変換が失敗したことを表します。
+var ErrConversionFailed = errors.New("ファイル変換に失敗しました")
+
+// MP3Metadata は出力ファイルのメタデータを格納する構造体です。MP3以外の出力形式でも使用します。
 type MP3Metadata struct {
 	Artist      string  // アーティスト名
 	AlbumArtist string  // アルバムアーティスト名
 	AlbumTitle  string  // アルバムタイトル
 	TrackName   string  // トラック名
+	Track       int     // トラック番号（0の場合は設定しない）
+	TrackTotal  int     // トラック数（0の場合はトラック番号のみ設定する）
+	Disc        int     // ディスク番号（0の場合は設定しない）
+	DiscTotal   int     // ディスク数（0の場合はディスク番号のみ設定する）
 	CoverImage  *string // 画像ファイルのパス（nil の場合は画像なし）
+	CoverReady  bool    // CoverImage が埋め込み用に加工済みの JPEG かどうか（ffmpeg で再エンコードせずに埋め込む）
+	Work        []Tag   // 作品データから設定するタグ（ジャンル・販売日など）
+	Extra       []Tag   // 追加で設定するタグ（ReplayGain など）
+}
+
+// Tag はffmpegの -metadata で設定するタグ名と値の組です。
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Tags はファイルに設定するタグを ffmpeg に渡す順序で返します。
+func (m MP3Metadata) Tags() []Tag {
+	tags := []Tag{
+		{Name: "artist", Value: m.Artist},
+		{Name: "album_artist", Value: m.AlbumArtist},
+		{Name: "album", Value: m.AlbumTitle},
+		{Name: "title", Value: m.TrackName},
+	}
+	// ffmpeg が MP3 では TRCK / TPOS、M4A では trkn / disk、Vorbisコメントでは TRACKNUMBER / DISCNUMBER に変換する
+	if m.Track > 0 {
+		tags = append(tags, Tag{Name: "track", Value: numberOf(m.Track, m.TrackTotal)})
+	}
+	if m.Disc > 0 {
+		tags = append(tags, Tag{Name: "disc", Value: numberOf(m.Disc, m.DiscTotal)})
+	}
+	tags = append(tags, m.Work...)
+	return append(tags, m.Extra...)
+}
+
+// numberOf はトラック番号・ディスク番号を "n/N" の形式で返します。総数が0の場合は "n" のみです。
+func numberOf(n, total int) string {
+	if total > 0 {
+		return fmt.Sprintf("%d/%d", n, total)
+	}
+	return strconv.Itoa(n)
+}
+
+// TagMap はタグ名をキーとしたマップを返します。
+func (m MP3Metadata) TagMap() map[string]string {
+	tags := m.Tags()
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		result[tag.Name] = tag.Value
+	}
+	return result
+}
+
+// EncoderSignature は既定の出力形式（MP3）のエンコード設定を識別する文字列を返します。
+// 設定が変わった場合に再エンコードが必要かどうかの判定に使用します。
+func EncoderSignature() string {
+	return FormatMP3().Signature()
 }
 
 // EnsureDirExists はディレクトリが存在しない場合に作成します。
@@ -55,44 +117,66 @@ func CleanUp(dir string) error {
 	return nil
 }
 
+// FfmpegArgs は音声ファイルをMP3に変換する ffmpeg コマンドの引数を返します。
+func FfmpegArgs(inputFile, mp3File string, metadata MP3Metadata) []string {
+	return FormatMP3().Args(inputFile, mp3File, metadata, "")
+}
+
 // ConvertFileToMp3WithContext はコンテキスト対応で音声ファイルをMP3形式に変換します。
 func ConvertFileToMp3WithContext(ctx context.Context, inputFile, mp3File string, metadata MP3Metadata) error {
-	cmdArgs := []string{
-		"-i", inputFile, // 入力ファイル
-	}
-
-	if metadata.CoverImage != nil && *metadata.CoverImage != "" {
-		cmdArgs = append(cmdArgs,
-			"-i", *metadata.CoverImage, // 画像ファイルを入力として追加
-			"-map", "0:a", // 最初の入力 (wav) のオーディオストリームを使用
-			"-map", "1:v", // 2つ目の入力 (画像) のビデオストリームを使用
-			"-c:v", "mjpeg", // JPEG 画像として保存
-			"-metadata:s:v", "title=Album cover", // 画像のメタデータ
-		)
-	}
-
-	cmdArgs = append(cmdArgs,
-		"-c:a", "libmp3lame", // LAME MP3 エンコーダを使用
-		// "-q:a", "2", // MP3 の品質を設定（0が最高品質、9が最低品質）
-		"-b:a", "320k", // 320kbps の固定ビットレート
-		"-ar", "48000", // サンプリングレートを 48kHz に設定
-		"-metadata", "artist="+metadata.Artist,
-		"-metadata", "album_artist="+metadata.AlbumArtist,
-		"-metadata", "album="+metadata.AlbumTitle,
-		"-metadata", "title="+metadata.TrackName,
-		"-id3v2_version", "3", // ID3v2.3 を使用
-		"-y",    // 出力ファイルを強制的に上書き
-		mp3File, // 出力ファイルのパス
-	)
+	return ConvertFileToMp3WithOptions(ctx, inputFile, mp3File, metadata, ConvertOptions{})
+}
+
+// ConvertFileToMp3WithOptions は ConvertFileToMp3WithContext に加えて、変換の進捗を受け取れます。
+func ConvertFileToMp3WithOptions(ctx context.Context, inputFile, mp3File string, metadata MP3Metadata, opts ConvertOptions) error {
+	return ConvertFile(ctx, inputFile, mp3File, metadata, FormatMP3(), opts)
+}
+
+// ConvertFile は音声ファイルを指定した出力形式に変換します。
+func ConvertFile(ctx context.Context, inputFile, outputFile string, metadata MP3Metadata, format Format, opts ConvertOptions) error {
+	var pictureMetadata string
+	if format.UsesPictureMetadata() && metadata.CoverImage != nil && *metadata.CoverImage != "" {
+		path, err := writePictureMetadata(ctx, *metadata.CoverImage)
+		if err != nil {
+			return fmt.Errorf("%w %s -> %s: カバー画像の準備に失敗: %w", ErrConversionFailed, inputFile, outputFile, err)
+		}
+		defer os.Remove(path)
+		pictureMetadata = path
+	}
+
+	cmdArgs := format.Args(inputFile, outputFile, metadata, pictureMetadata)
+	if opts.OnProgress != nil {
+		cmdArgs = append(progressArgs(), cmdArgs...)
+	}
 
 	// ffmpeg でエンコードする（コンテキスト対応）
 	cmd := exec.CommandContext(ctx, "ffmpeg", cmdArgs...)
-	err := cmd.Run()
+	err := runFfmpeg(cmd, opts.OnProgress)
 	if err != nil {
 		if ctx.Err() != nil {
 			return fmt.Errorf("変換処理がキャンセルされました: %w", ctx.Err())
 		}
-		return fmt.Errorf("ファイル変換に失敗しました %s -> %s (コマンド引数: %v): %w", inputFile, mp3File, cmdArgs, err)
+		return fmt.Errorf("%w %s -> %s (コマンド引数: %v): %w", ErrConversionFailed, inputFile, outputFile, cmdArgs, err)
 	}
 	return nil
 }
+
+// runFfmpeg は ffmpeg を実行し、onProgress が指定されている場合は標準出力の進捗を読み取ります。
+func runFfmpeg(cmd *exec.Cmd, onProgress func(Progress)) error {
+	if onProgress == nil {
+		return cmd.Run()
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// 進捗の読み取りに失敗しても変換自体は継続できるため、残りの出力を読み捨てて終了を待つ
+	if err := ParseProgress(stdout, onProgress); err != nil {
+		io.Copy(io.Discard, stdout)
+	}
+	return cmd.Wait()
+}
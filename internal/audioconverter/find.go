@@ -9,21 +9,35 @@ import (
 
 	"github.com/kkryama/dls-encoder/internal/config"
 	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// 音声ファイルを変換対象から外した理由です（model.SkippedFile の Reason）。
+const (
+	SkipReasonExcluded      = "excluded"       // exclude_strings に一致した
+	SkipReasonLowerPriority = "lower_priority" // 同じディレクトリに同名のより優先度の高い形式が存在する
+	SkipReasonVariant       = "variant"        // [[variant]] の規則でより優先する版が存在する
 )
 
 // FindAudioFiles は指定されたディレクトリから音声ファイルを検索し、パスのリストを返します。
-// WAVファイルを優先し、WAVが存在しない場合FLACを、次にMP3ファイルを対象とします。
+// 対象は input_formats の拡張子のファイルです。[[variant]] の規則で同じトラックの別の版から1つを選び、
+// 同じディレクトリ（形式だけを表すディレクトリは区別しない）の同名のファイルは input_formats の順で優先する形式を選びます。
+// 可逆の形式（WAV・FLAC など）は input_formats の順にかかわらず非可逆の形式（MP3・M4A など）より優先します。
+// リストはトラック番号の順（ディスクごとにファイル名の自然順）に並びます。
 func FindAudioFiles(directory string, cfg *config.Config) []string {
-	audioFiles := make(map[string]string)        // 拡張子を除いたファイル名をキーとして、対応するファイルのフルパスを値に持つマップ
-	seen := make(map[string]int)                 // 拡張子を除いたファイル名をキーとして、そのファイルの優先度（WAV:3, FLAC:2, MP3:1）を値に持つマップ
+	return SelectAudioFiles(directory, cfg).Files
+}
+
+// SelectAudioFiles は FindAudioFiles と同じ規則で音声ファイルを選択し、選択しなかったファイルとその理由も返します。
+func SelectAudioFiles(directory string, cfg *config.Config) model.AudioSelection {
+	var selection model.AudioSelection
+	var found []string                           // 除外文字列に一致しない、対応する形式のファイルのパス
+	candidates := make(map[string][]string)      // トラックごとの、対応する形式のファイルのパス
+	audioFiles := make(map[string]string)        // トラックをキーとして、選択したファイルのフルパスを値に持つマップ
 	excludeStrings := cfg.Setting.ExcludeStrings // パス中に含まれていたら除外する文字列リスト
 
-	// 優先度: WAV > FLAC > MP3
-	priority := map[string]int{
-		".wav":  3,
-		".flac": 2,
-		".mp3":  1,
-	}
+	// 拡張子ごとの優先度（可逆の形式 > 非可逆の形式、同じ種類の中では input_formats の順）
+	priority := inputRanks(cfg.Setting.InputFormatList())
 
 	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -33,6 +47,9 @@ func FindAudioFiles(directory string, cfg *config.Config) []string {
 			// 除外対象の文字列が含まれている場合はスキップ
 			for _, excl := range excludeStrings {
 				if strings.Contains(path, excl) {
+					if _, ok := priority[strings.ToLower(filepath.Ext(info.Name()))]; ok {
+						selection.Skipped = append(selection.Skipped, model.SkippedFile{Path: path, Reason: SkipReasonExcluded, ExcludeString: excl})
+					}
 					logger.LogDebugEvent("audio_file_excluded", map[string]interface{}{
 						"exclude_string": excl,
 						"path":           path,
@@ -41,36 +58,8 @@ func FindAudioFiles(directory string, cfg *config.Config) []string {
 				}
 			}
 
-			name := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())) // 拡張子を除いたファイル名を取得
-			ext := strings.ToLower(filepath.Ext(info.Name()))                  // 拡張子を小文字で取得（大文字対応）
-
-			// 優先度の高い拡張子の場合にのみマップを更新
-			// 例1: "track1.mp3" (p=1) が見つかると、seen["track1"] = 0 < 1 なので、seen["track1"] = 1, audioFiles["track1"] = "/path/to/track1.mp3"。
-			// 例2: 次に "track1.wav" (p=3) が見つかると、seen["track1"] = 1 < 3 なので、seen["track1"] = 3, audioFiles["track1"] = "/path/to/track1.wav" に更新。
-			// 例3: 次に "track1.flac" (p=2) が見つかると、seen["track1"] = 3 > 2 なので、更新されません（WAV が優先）。
-			if p, ok := priority[ext]; ok {
-				if seen[name] < p {
-					prevPriority := seen[name]
-					prevPath := audioFiles[name]
-					seen[name] = p          // 優先度を更新
-					audioFiles[name] = path // パスを記録
-					if prevPriority > 0 {
-						logger.LogDebugEvent("audio_file_priority_updated", map[string]interface{}{
-							"file_name":     name,
-							"prev_priority": prevPriority,
-							"new_priority":  p,
-							"prev_path":     prevPath,
-							"new_path":      path,
-							"message":       fmt.Sprintf("%s は優先度 %d から %d に更新されたため '%s' を '%s' へ差し替えました。", name, prevPriority, p, prevPath, path),
-						})
-					} else {
-						logger.LogDebugEvent("audio_file_registered", map[string]interface{}{
-							"file_name": name,
-							"priority":  p,
-							"path":      path,
-						})
-					}
-				}
+			if _, ok := priority[strings.ToLower(filepath.Ext(info.Name()))]; ok {
+				found = append(found, path)
 			}
 		}
 		return nil
@@ -81,19 +70,140 @@ func FindAudioFiles(directory string, cfg *config.Config) []string {
 			"error":     err.Error(),
 			"directory": directory,
 		})
-		return nil
+		return model.AudioSelection{}
+	}
+
+	// 同じトラックの別の版（SE有/SE無など）から、規則で優先する版を選ぶ
+	found, skipped := selectVariants(directory, found, cfg.VariantRules)
+	selection.Skipped = append(selection.Skipped, skipped...)
+
+	for _, path := range found {
+		name := trackID(directory, path) // ディスクのディレクトリと拡張子を除いたファイル名
+		rank := priority[strings.ToLower(filepath.Ext(path))]
+		candidates[name] = append(candidates[name], path)
+
+		prevPath, ok := audioFiles[name]
+		if !ok {
+			audioFiles[name] = path
+			logger.LogDebugEvent("audio_file_registered", map[string]interface{}{
+				"file_name": name,
+				"format":    rank.format,
+				"lossless":  rank.lossless,
+				"path":      path,
+			})
+			continue
+		}
+
+		// 同じトラックの2つ目以降のファイルは、優先する形式の場合のみ差し替え、判断の理由を記録する
+		prev := priority[strings.ToLower(filepath.Ext(prevPath))]
+		updated := rank.higher(prev)
+		if updated {
+			audioFiles[name] = path
+		}
+		logPriorityDecision(name, prevPath, path, prev, rank, updated)
 	}
 
 	// マップからリストに変換
-	var result []string
 	keys := make([]string, 0, len(audioFiles))
 	for name := range audioFiles {
 		keys = append(keys, name)
 	}
 	sort.Strings(keys)
 	for _, name := range keys {
-		result = append(result, audioFiles[name])
+		selection.Files = append(selection.Files, audioFiles[name])
+		for _, candidate := range candidates[name] {
+			if candidate != audioFiles[name] {
+				selection.Skipped = append(selection.Skipped, model.SkippedFile{Path: candidate, Reason: SkipReasonLowerPriority, PreferredPath: audioFiles[name]})
+			}
+		}
+	}
+	// トラック番号の順序にするため、ディスク（サブディレクトリ）ごとにファイル名の自然順で並べる
+	sortAudioFiles(directory, selection.Files)
+
+	return selection
+}
+
+// trackID は形式の優先度を比較する単位となるトラックを表す文字列を返します。
+// 同じディスクのディレクトリ（"wav" や "MP3版" のような形式だけを表すディレクトリは区別しない）の、拡張子を除いて同名のファイルが同じトラックです。
+// "本編/01.wav" と "おまけ/01.wav" は別のトラックになります。
+func trackID(directory, file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if dir := discGroup(directory, file); dir != "" {
+		return dir + "/" + name
+	}
+	return name
+}
+
+// 同じトラックの形式の選択の理由です（audio_file_priority_updated の reason）。
+const (
+	priorityReasonLossless     = "lossless"      // 可逆の形式を非可逆の形式より優先した
+	priorityReasonInputFormats = "input_formats" // input_formats の順で優先した
+	priorityReasonSameFormat   = "same_format"   // 同じ形式のため先に見つかったファイルを残した
+)
+
+// inputRank は変換元の形式の優先度です。
+type inputRank struct {
+	format   string // 拡張子（"." なし、小文字）
+	order    int    // input_formats での位置
+	lossless bool   // 可逆の形式かどうか
+}
+
+// inputRanks は input_formats の拡張子ごとの優先度を返します。キーは "." 付きの小文字の拡張子です。
+func inputRanks(formats []string) map[string]inputRank {
+	ranks := make(map[string]inputRank, len(formats))
+	for i, format := range formats {
+		lossless, _ := config.InputFormat(format)
+		ranks["."+format] = inputRank{format: format, order: i, lossless: lossless}
+	}
+	return ranks
+}
+
+// higher は r が other より優先する形式かどうかを返します。
+// 可逆の形式は input_formats の順にかかわらず非可逆の形式より優先し、同じ種類の中では input_formats の順です。
+func (r inputRank) higher(other inputRank) bool {
+	if r.lossless != other.lossless {
+		return r.lossless
+	}
+	return r.order < other.order
+}
+
+// logPriorityDecision は同じトラックの2つのファイルからどちらを選んだかと、その理由をデバッグログに出力します。
+func logPriorityDecision(name, prevPath, newPath string, prev, next inputRank, updated bool) {
+	selected, other := prev, next
+	selectedPath := prevPath
+	if updated {
+		selected, other = next, prev
+		selectedPath = newPath
 	}
 
-	return result
+	var reason, detail string
+	switch {
+	case selected.format == other.format:
+		reason, detail = priorityReasonSameFormat, "同じ形式のため先に見つかったファイルを残しました"
+	case selected.lossless != other.lossless:
+		reason = priorityReasonLossless
+		detail = fmt.Sprintf("可逆の %s を非可逆の %s より優先しました", selected.format, other.format)
+		if selected.order > other.order {
+			detail += "（input_formats の順より優先）"
+		}
+	default:
+		reason = priorityReasonInputFormats
+		detail = fmt.Sprintf("input_formats の順で %s を %s より優先しました", selected.format, other.format)
+	}
+
+	message := fmt.Sprintf("%s は%s。'%s' を選択しています。", name, detail, selectedPath)
+	if updated {
+		message = fmt.Sprintf("%s は%s。'%s' を '%s' へ差し替えました。", name, detail, prevPath, newPath)
+	}
+	logger.LogDebugEvent("audio_file_priority_updated", map[string]interface{}{
+		"file_name":     name,
+		"prev_format":   prev.format,
+		"new_format":    next.format,
+		"prev_path":     prevPath,
+		"new_path":      newPath,
+		"updated":       updated,
+		"selected_path": selectedPath,
+		"reason":        reason,
+		"message":       message,
+	})
 }
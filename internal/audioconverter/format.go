@@ -0,0 +1,347 @@
+package audioconverter
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+)
+
+// 出力形式の名前（設定ファイルの output_format に指定する値）
+const (
+	FormatNameMP3  = "mp3"
+	FormatNameM4A  = "m4a"
+	FormatNameOpus = "opus"
+	FormatNameOgg  = "ogg"
+	FormatNameFLAC = "flac"
+)
+
+// coverMode はカバー画像の埋め込み方法です。
+type coverMode int
+
+const (
+	coverAttachedPic   coverMode = iota // 画像を映像ストリーム（attached_pic）として埋め込む（MP3 / M4A / FLAC）
+	coverVorbisComment                  // 画像を METADATA_BLOCK_PICTURE コメントとして埋め込む（Ogg Opus / Ogg Vorbis）
+)
+
+// Format は出力形式ごとのエンコード設定とタグ・カバー画像の書き込み方法です。
+type Format struct {
+	Name      string // 出力形式の名前
+	Extension string // 出力ファイルの拡張子
+	Preset    string // 適用したプリセット名（既定の設定の場合は空文字列）
+
+	encoder     string   // ffmpeg のエンコーダ名
+	rateArgs    []string // ビットレートや品質の設定
+	sampleRate  int      // サンプリングレート（0の場合は変換元のまま）
+	channels    int      // チャンネル数（0の場合は変換元のまま）
+	resampler   string   // リサンプラー（空文字列の場合は ffmpeg の既定）
+	filter      string   // エンコード前に適用する音声フィルタ（loudnorm など、空文字列の場合はなし）
+	muxArgs     []string // コンテナ固有の設定（タグの形式など）
+	cover       coverMode
+	legacy      bool  // MP3のみ対応していた頃と同じ引数を生成するかどうか
+	bitrate     bool  // プリセットの bitrate に対応しているかどうか
+	quality     bool  // プリセットの quality に対応しているかどうか
+	sampleRates []int // エンコーダが対応しているサンプリングレート（nil の場合は制限なし）
+	customTags  bool  // 任意の名前のタグ（ReplayGain など）を書き込めるかどうか
+	copy        bool  // 音声を再エンコードせずにコピーするかどうか
+}
+
+// formats は対応している出力形式です。
+// タグは ffmpeg の共通のキー（artist, album_artist など）で渡し、
+// 各コンテナの形式（ID3 フレーム、MP4 アトム、Vorbis コメント）への変換は ffmpeg に任せます。
+var formats = map[string]Format{
+	FormatNameMP3: {
+		Name:       FormatNameMP3,
+		Extension:  ".mp3",
+		encoder:    "libmp3lame",                    // LAME MP3 エンコーダを使用
+		rateArgs:   []string{"-b:a", "320k"},        // 320kbps の固定ビットレート
+		sampleRate: 48000,                           // サンプリングレートを 48kHz に設定
+		muxArgs:    []string{"-id3v2_version", "3"}, // ID3v2.3 を使用
+		cover:      coverAttachedPic,
+		legacy:     true,
+		bitrate:    true,
+		quality:    true,
+		customTags: true, // ID3v2 の TXXX フレームとして書き込まれる
+	},
+	FormatNameM4A: {
+		Name:       FormatNameM4A,
+		Extension:  ".m4a",
+		encoder:    "aac",
+		rateArgs:   []string{"-b:a", "256k"},
+		sampleRate: 48000,
+		muxArgs:    []string{"-movflags", "+faststart"},
+		cover:      coverAttachedPic,
+		bitrate:    true,
+		quality:    true,
+	},
+	FormatNameOpus: {
+		Name:        FormatNameOpus,
+		Extension:   ".opus",
+		encoder:     "libopus",
+		rateArgs:    []string{"-b:a", "160k"},
+		sampleRate:  48000,
+		cover:       coverVorbisComment,
+		bitrate:     true,
+		sampleRates: []int{8000, 12000, 16000, 24000, 48000},
+		customTags:  true,
+	},
+	FormatNameOgg: {
+		Name:       FormatNameOgg,
+		Extension:  ".ogg",
+		encoder:    "libvorbis",
+		rateArgs:   []string{"-q:a", "8"},
+		sampleRate: 48000,
+		cover:      coverVorbisComment,
+		bitrate:    true,
+		quality:    true,
+		customTags: true,
+	},
+	FormatNameFLAC: {
+		Name:      FormatNameFLAC,
+		Extension: ".flac",
+		encoder:   "flac",
+		// アーカイブ用途のため、サンプリングレートは変換元のまま保持する
+		rateArgs:   []string{"-compression_level", "8"},
+		cover:      coverAttachedPic,
+		customTags: true,
+	},
+}
+
+// formatAliases は出力形式の別名です。
+var formatAliases = map[string]string{
+	"":    FormatNameMP3,
+	"aac": FormatNameM4A,
+}
+
+// LookupFormat は出力形式の名前（大文字小文字を区別しない）から出力形式を返します。
+// 空文字列の場合は MP3 を返します。
+func LookupFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := formatAliases[name]; ok {
+		name = alias
+	}
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("対応していない出力形式です: %s", name)
+	}
+	return format, nil
+}
+
+// FormatMP3 は既定の出力形式（320kbps MP3）です。
+func FormatMP3() Format {
+	return formats[FormatNameMP3]
+}
+
+// WithPreset はプリセットの設定を適用した出力形式を返します。
+// プリセットで指定しなかった項目は出力形式の既定値のままです。
+func (f Format) WithPreset(name string, preset config.Preset) (Format, error) {
+	switch {
+	case preset.Bitrate != "":
+		if !f.bitrate {
+			return Format{}, fmt.Errorf("プリセット %q: %s では bitrate を指定できません", name, f.Name)
+		}
+		f.rateArgs = []string{"-b:a", preset.Bitrate}
+	case preset.Quality != nil:
+		if !f.quality {
+			return Format{}, fmt.Errorf("プリセット %q: %s では quality を指定できません", name, f.Name)
+		}
+		f.rateArgs = []string{"-q:a", strconv.FormatFloat(*preset.Quality, 'g', -1, 64)}
+	}
+	if preset.SampleRate > 0 {
+		if f.sampleRates != nil && !slices.Contains(f.sampleRates, preset.SampleRate) {
+			return Format{}, fmt.Errorf("プリセット %q: %s の sample_rate には %v のいずれかを指定してください: %d", name, f.Name, f.sampleRates, preset.SampleRate)
+		}
+		f.sampleRate = preset.SampleRate
+	}
+	if preset.Channels > 0 {
+		f.channels = preset.Channels
+	}
+	if preset.Resampler != "" {
+		f.resampler = preset.Resampler
+	}
+	f.Preset = name
+	f.legacy = false
+	return f, nil
+}
+
+// ForSource は変換元のサンプリングレートに合わせた出力形式を返します。
+// 変換元のサンプリングレートが設定より低い場合は、アップサンプリングしないよう変換元のサンプリングレートを使用します。
+// エンコーダが変換元のサンプリングレートに対応していない場合は、対応しているもののうち変換元以上で最も低いものを使用します。
+func (f Format) ForSource(sampleRate int) Format {
+	if f.sampleRate == 0 || sampleRate <= 0 || sampleRate >= f.sampleRate {
+		return f
+	}
+	if f.sampleRates == nil {
+		f.sampleRate = sampleRate
+		return f
+	}
+	for _, rate := range f.sampleRates {
+		if rate >= sampleRate {
+			f.sampleRate = min(rate, f.sampleRate)
+			break
+		}
+	}
+	return f
+}
+
+// WithFilter はエンコード前に音声フィルタを適用する出力形式を返します。既に設定したフィルタは置き換えます。
+// フィルタが出力のサンプリングレートを変える場合（loudnorm は 192kHz で出力する）に備え、
+// 出力形式でサンプリングレートを指定していない場合は sampleRate（0の場合は 48kHz）を指定します。
+func (f Format) WithFilter(filter string, sampleRate int) Format {
+	f.filter = filter
+	if f.sampleRate == 0 {
+		f.sampleRate = sampleRate
+		if f.sampleRate <= 0 {
+			f.sampleRate = 48000
+		}
+	}
+	return f
+}
+
+// Passthrough は音声を再エンコードせずにコピー（-c:a copy）し、タグとカバー画像のみを書き込む出力形式を返します。
+// 変換元が出力形式と同じコーデックの場合にのみ使用します。エンコード設定（ビットレート・サンプリングレートなど）は変換元のままです。
+func (f Format) Passthrough() Format {
+	f.copy = true
+	f.legacy = false
+	return f
+}
+
+// IsPassthrough は音声を再エンコードせずにコピーする出力形式かどうかを返します。
+func (f Format) IsPassthrough() bool {
+	return f.copy
+}
+
+// CustomTags は ReplayGain などの任意の名前のタグを書き込める形式かどうかを返します。
+// M4A は ffmpeg が iTunes 形式の独自タグを書き込めないため対応していません。
+func (f Format) CustomTags() bool {
+	return f.customTags
+}
+
+// mp4Tags は ffmpeg の mp4 マルチプレクサが iTunes 形式のアトムとして書き込むタグ名です。
+// これ以外の名前のタグは、エラーにならずに書き込まれません。
+var mp4Tags = map[string]bool{
+	"title": true, "artist": true, "album_artist": true, "album": true, "composer": true,
+	"date": true, "comment": true, "genre": true, "copyright": true, "grouping": true,
+	"lyrics": true, "description": true, "synopsis": true, "show": true, "episode_id": true,
+	"network": true, "keywords": true, "track": true, "disc": true, "compilation": true,
+	"encoder": true, "media_type": true, "gapless_playback": true,
+	"sort_name": true, "sort_artist": true, "sort_album_artist": true, "sort_album": true,
+	"sort_composer": true, "sort_show": true,
+}
+
+// SupportsTag は name のタグを出力ファイルに書き込めるかどうかを返します。
+// 任意の名前のタグを書き込めない形式（M4A）では、mp4Tags にある名前のみ書き込めます。
+func (f Format) SupportsTag(name string) bool {
+	return f.customTags || mp4Tags[strings.ToLower(name)]
+}
+
+// codecArgs は音声のエンコード設定を表す ffmpeg の引数を返します。
+func (f Format) codecArgs() []string {
+	args := f.encodeArgs()
+	if filters := f.audioFilters(); len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+	return args
+}
+
+// encodeArgs は音声フィルタを除いたエンコード設定の ffmpeg の引数を返します。
+func (f Format) encodeArgs() []string {
+	if f.copy {
+		return []string{"-c:a", "copy"}
+	}
+	args := append([]string{"-c:a", f.encoder}, f.rateArgs...)
+	if f.sampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(f.sampleRate))
+	}
+	if f.channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(f.channels))
+	}
+	return args
+}
+
+// audioFilters はエンコード前に適用する音声フィルタを適用する順に返します。
+// 音声をコピーする場合はフィルタを適用できないため、常に空です。
+func (f Format) audioFilters() []string {
+	if f.copy {
+		return nil
+	}
+	var filters []string
+	if f.filter != "" {
+		filters = append(filters, f.filter)
+	}
+	if f.resampler != "" {
+		// フィルタの後に置き、フィルタの出力を指定したリサンプラーで変換する
+		filters = append(filters, "aresample=resampler="+f.resampler)
+	}
+	return filters
+}
+
+// Signature はエンコード設定を識別する文字列を返します。
+// 設定が変わった場合に再エンコードが必要かどうかの判定に使用します。
+func (f Format) Signature() string {
+	if f.legacy {
+		// 出力形式の設定を追加する前に記録した変換記録と一致させる
+		return strings.Join(f.codecArgs(), " ")
+	}
+	return f.Name + ": " + strings.Join(append(f.codecArgs(), f.muxArgs...), " ")
+}
+
+// UsesPictureMetadata はカバー画像を METADATA_BLOCK_PICTURE コメントとして埋め込む形式かどうかを返します。
+func (f Format) UsesPictureMetadata() bool {
+	return f.cover == coverVorbisComment
+}
+
+// Args は音声ファイルを変換する ffmpeg コマンドの引数を返します。
+// pictureMetadata は METADATA_BLOCK_PICTURE を記述した ffmetadata ファイルのパスで、
+// UsesPictureMetadata が true の形式でカバー画像を埋め込む場合にのみ使用します。
+func (f Format) Args(inputFile, outputFile string, metadata MP3Metadata, pictureMetadata string) []string {
+	cmdArgs := []string{
+		"-i", inputFile, // 入力ファイル
+	}
+
+	hasCover := metadata.CoverImage != nil && *metadata.CoverImage != ""
+	pictureComment := hasCover && f.cover == coverVorbisComment && pictureMetadata != ""
+	switch {
+	case hasCover && f.cover == coverAttachedPic:
+		codec := "mjpeg" // JPEG 画像として保存
+		if metadata.CoverReady {
+			codec = "copy" // 加工済みの JPEG をそのまま保存
+		}
+		cmdArgs = append(cmdArgs,
+			"-i", *metadata.CoverImage, // 画像ファイルを入力として追加
+			"-map", "0:a", // 最初の入力 (wav) のオーディオストリームを使用
+			"-map", "1:v", // 2つ目の入力 (画像) のビデオストリームを使用
+			"-c:v", codec,
+			"-metadata:s:v", "title=Album cover", // 画像のメタデータ
+		)
+		if !f.legacy {
+			cmdArgs = append(cmdArgs, "-disposition:v", "attached_pic")
+		}
+	case pictureComment:
+		cmdArgs = append(cmdArgs,
+			"-f", "ffmetadata", "-i", pictureMetadata, // METADATA_BLOCK_PICTURE を記述したファイル
+			"-map", "0:a",
+			"-map_metadata", "1", // ファイル全体のメタデータとして使用
+		)
+	case !f.legacy:
+		// 変換元に埋め込まれた画像を映像ストリームとして出力しないよう、音声のみを対象にする
+		cmdArgs = append(cmdArgs, "-map", "0:a")
+	}
+	if !f.legacy && !pictureComment {
+		// 変換元のタグは引き継がず、設定したタグのみを書き込む
+		cmdArgs = append(cmdArgs, "-map_metadata", "-1")
+	}
+
+	cmdArgs = append(cmdArgs, f.codecArgs()...)
+	for _, tag := range metadata.Tags() {
+		cmdArgs = append(cmdArgs, "-metadata", tag.Name+"="+tag.Value)
+	}
+	cmdArgs = append(cmdArgs, f.muxArgs...)
+	cmdArgs = append(cmdArgs,
+		"-y",       // 出力ファイルを強制的に上書き
+		outputFile, // 出力ファイルのパス
+	)
+	return cmdArgs
+}
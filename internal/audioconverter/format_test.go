@@ -0,0 +1,264 @@
+package audioconverter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+)
+
+func TestLookupFormat(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{"", FormatNameMP3},
+		{"mp3", FormatNameMP3},
+		{"AAC", FormatNameM4A},
+		{"m4a", FormatNameM4A},
+		{" opus ", FormatNameOpus},
+		{"ogg", FormatNameOgg},
+		{"flac", FormatNameFLAC},
+	}
+	for _, tc := range testCases {
+		format, err := LookupFormat(tc.name)
+		if err != nil || format.Name != tc.want {
+			t.Errorf("LookupFormat(%q): got %q, %v, want %q", tc.name, format.Name, err, tc.want)
+		}
+	}
+	if _, err := LookupFormat("wma"); err == nil {
+		t.Error("対応していない出力形式でエラーが発生すべき")
+	}
+}
+
+func TestFormatSupportsTag(t *testing.T) {
+	m4a, _ := LookupFormat(FormatNameM4A)
+	if !m4a.SupportsTag("genre") || !m4a.SupportsTag("Composer") {
+		t.Error("M4A は iTunes 形式のアトムがあるタグを書き込めるべき")
+	}
+	if m4a.SupportsTag("CATALOGNUMBER") || m4a.SupportsTag("lyricist") {
+		t.Error("M4A は独自の名前のタグを書き込めない")
+	}
+	if !FormatMP3().SupportsTag("CATALOGNUMBER") {
+		t.Error("MP3 は任意の名前のタグを書き込めるべき")
+	}
+}
+
+func TestFormatSignature(t *testing.T) {
+	// 出力形式の設定を追加する前の変換記録と一致させ、既存のMP3を再エンコードしない
+	if got, want := FormatMP3().Signature(), "-c:a libmp3lame -b:a 320k -ar 48000"; got != want {
+		t.Errorf("MP3のSignature: got %q, want %q", got, want)
+	}
+	seen := map[string]string{}
+	for name, format := range formats {
+		if other, ok := seen[format.Signature()]; ok {
+			t.Errorf("%s と %s のSignatureが重複しています", name, other)
+		}
+		seen[format.Signature()] = name
+	}
+}
+
+func TestFormatWithPreset(t *testing.T) {
+	quality := 2.0
+	mp3, err := FormatMP3().WithPreset("v2", config.Preset{Quality: &quality, SampleRate: 44100, Channels: 1, Resampler: config.ResamplerSoXR})
+	if err != nil {
+		t.Fatalf("MP3へのプリセットの適用に失敗: %v", err)
+	}
+	want := []string{"-c:a", "libmp3lame", "-q:a", "2", "-ar", "44100", "-ac", "1", "-af", "aresample=resampler=soxr"}
+	if got := mp3.codecArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("プリセット適用後の引数:\ngot  %q\nwant %q", got, want)
+	}
+	if mp3.Preset != "v2" || mp3.Signature() == FormatMP3().Signature() {
+		t.Errorf("プリセットを適用した場合はSignatureが変わるべき: %q", mp3.Signature())
+	}
+
+	// 既定値と同じ設定のプリセットでも、プリセットの指定前後で変換記録を区別する
+	same, err := FormatMP3().WithPreset("same", config.Preset{Bitrate: "320k"})
+	if err != nil {
+		t.Fatalf("MP3へのプリセットの適用に失敗: %v", err)
+	}
+	if !slices.Contains(same.codecArgs(), "320k") || !slices.Contains(same.codecArgs(), "48000") {
+		t.Errorf("指定しなかった項目は既定値のままにすべき: %q", same.codecArgs())
+	}
+
+	opus, _ := LookupFormat(FormatNameOpus)
+	if _, err := opus.WithPreset("v2", config.Preset{Quality: &quality}); err == nil {
+		t.Error("Opusでqualityを指定した場合はエラーが発生すべき")
+	}
+	if _, err := opus.WithPreset("cd", config.Preset{SampleRate: 44100}); err == nil {
+		t.Error("Opusが対応していないサンプリングレートでエラーが発生すべき")
+	}
+	if _, err := opus.WithPreset("voice", config.Preset{Bitrate: "64k", SampleRate: 24000}); err != nil {
+		t.Errorf("Opusが対応しているサンプリングレートでエラーが発生しました: %v", err)
+	}
+
+	flac, _ := LookupFormat(FormatNameFLAC)
+	if _, err := flac.WithPreset("small", config.Preset{Bitrate: "192k"}); err == nil {
+		t.Error("FLACでbitrateを指定した場合はエラーが発生すべき")
+	}
+}
+
+func TestFormatForSource(t *testing.T) {
+	rateOf := func(f Format) string {
+		args := f.codecArgs()
+		if i := slices.Index(args, "-ar"); i >= 0 {
+			return args[i+1]
+		}
+		return ""
+	}
+
+	mp3 := FormatMP3()
+	testCases := []struct {
+		name       string
+		format     Format
+		sampleRate int
+		want       string
+	}{
+		{"44.1kHzはアップサンプリングしない", mp3, 44100, "44100"},
+		{"96kHzはダウンサンプリングする", mp3, 96000, "48000"},
+		{"不明な場合は設定どおり", mp3, 0, "48000"},
+		{"Opusは対応するサンプリングレートに合わせる", formats[FormatNameOpus], 44100, "48000"},
+		{"Opusの22.05kHzは24kHz", formats[FormatNameOpus], 22050, "24000"},
+		{"FLACは変換元のまま", formats[FormatNameFLAC], 44100, ""},
+	}
+	for _, tc := range testCases {
+		if got := rateOf(tc.format.ForSource(tc.sampleRate)); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFormatWithFilter(t *testing.T) {
+	filter := "loudnorm=I=-18:TP=-1"
+	testCases := []struct {
+		name       string
+		format     Format
+		sampleRate int
+		want       []string
+	}{
+		{"MP3は設定のサンプリングレート", FormatMP3(), 44100, []string{"-ar", "48000", "-af", filter}},
+		{"FLACは変換元のサンプリングレート", formats[FormatNameFLAC], 44100, []string{"-ar", "44100", "-af", filter}},
+		{"FLACで変換元が不明な場合は48kHz", formats[FormatNameFLAC], 0, []string{"-ar", "48000", "-af", filter}},
+	}
+	for _, tc := range testCases {
+		args := tc.format.WithFilter(filter, tc.sampleRate).codecArgs()
+		if got := args[len(args)-len(tc.want):]; !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, args, tc.want)
+		}
+	}
+
+	// リサンプラーはフィルタの後に適用する
+	soxr, err := FormatMP3().WithPreset("hq", config.Preset{Resampler: config.ResamplerSoXR})
+	if err != nil {
+		t.Fatalf("WithPreset でエラー: %v", err)
+	}
+	args := soxr.WithFilter(filter, 0).codecArgs()
+	if i := slices.Index(args, "-af"); i < 0 || args[i+1] != filter+",aresample=resampler=soxr" {
+		t.Errorf("フィルタとリサンプラーの指定: got %v", args)
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	cover := "/image/cover.webp"
+	metadata := MP3Metadata{Artist: "声優", AlbumTitle: "作品", TrackName: "01", CoverImage: &cover}
+
+	flac, _ := LookupFormat(FormatNameFLAC)
+	got := flac.Args("/source/01.wav", "/output/01.flac", metadata, "")
+	want := []string{
+		"-i", "/source/01.wav",
+		"-i", cover,
+		"-map", "0:a",
+		"-map", "1:v",
+		"-c:v", "mjpeg",
+		"-metadata:s:v", "title=Album cover",
+		"-disposition:v", "attached_pic",
+		"-map_metadata", "-1",
+		"-c:a", "flac", "-compression_level", "8",
+		"-metadata", "artist=声優",
+		"-metadata", "album_artist=",
+		"-metadata", "album=作品",
+		"-metadata", "title=01",
+		"-y", "/output/01.flac",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FLACの引数:\ngot  %q\nwant %q", got, want)
+	}
+
+	opus, _ := LookupFormat(FormatNameOpus)
+	got = opus.Args("/source/01.wav", "/output/01.opus", metadata, "/tmp/cover.ffmetadata")
+	if !slices.Contains(got, "/tmp/cover.ffmetadata") || slices.Contains(got, cover) || slices.Contains(got, "1:v") {
+		t.Errorf("Opusではカバー画像を ffmetadata で渡すべき: %q", got)
+	}
+	if !slices.Contains(got, "libopus") || got[len(got)-1] != "/output/01.opus" {
+		t.Errorf("Opusの引数が正しくありません: %q", got)
+	}
+
+	// ffmetadata ファイルがない場合は画像を埋め込まない
+	got = opus.Args("/source/01.wav", "/output/01.opus", metadata, "")
+	if slices.Contains(got, "ffmetadata") || !slices.Contains(got, "-1") {
+		t.Errorf("カバー画像なしのOpusの引数が正しくありません: %q", got)
+	}
+
+	// 加工済みの画像は再エンコードしない
+	metadata.CoverReady = true
+	got = FormatMP3().Args("/source/01.wav", "/output/01.mp3", metadata, "")
+	if i := slices.Index(got, "-c:v"); i < 0 || got[i+1] != "copy" {
+		t.Errorf("加工済みの画像は -c:v copy で埋め込むべき: %q", got)
+	}
+}
+
+func TestPictureBlock(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	block, err := pictureBlock(data)
+	if err != nil {
+		t.Fatalf("pictureBlock でエラー: %v", err)
+	}
+
+	r := bytes.NewReader(block)
+	read := func() uint32 {
+		var v uint32
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if got := read(); got != pictureTypeFrontCover {
+		t.Errorf("画像の種別: got %d", got)
+	}
+	mime := make([]byte, read())
+	r.Read(mime)
+	if string(mime) != "image/png" {
+		t.Errorf("MIMEタイプ: got %q", mime)
+	}
+	if read() != 0 {
+		t.Error("説明文は空であるべき")
+	}
+	if w, h := read(), read(); w != 4 || h != 3 {
+		t.Errorf("画像サイズ: got %dx%d, want 4x3", w, h)
+	}
+	read() // 色深度
+	read() // 色数
+	if size := read(); int(size) != len(data) || r.Len() != len(data) {
+		t.Errorf("画像データの長さ: got %d (残り %d), want %d", size, r.Len(), len(data))
+	}
+
+	if _, err := pictureBlock([]byte("not an image")); err == nil {
+		t.Error("画像でないデータでエラーが発生すべき")
+	}
+}
+
+func TestEscapeFFMetadata(t *testing.T) {
+	if got, want := escapeFFMetadata("ab==;#\\"), `ab\=\=\;\#\\`; got != want {
+		t.Errorf("escapeFFMetadata: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package audioconverter
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+)
+
+// TrackNumber はトラック番号とディスク番号です。
+type TrackNumber struct {
+	Track      int // ディスク内のトラック番号（1始まり）
+	TrackTotal int // ディスク内のトラック数
+	Disc       int // ディスク番号（1始まり、ディスクに分かれていない場合は0）
+	DiscTotal  int // ディスク数（ディスクに分かれていない場合は0）
+}
+
+// bonusKeywords はおまけ・特典のディレクトリを表す語です。これらを含むディレクトリは本編の後のディスクとして扱います。
+var bonusKeywords = []string{"おまけ", "オマケ", "特典", "付録", "bonus", "omake", "extra"}
+
+// NaturalLess は文字列中の数字を数値として比較し、a が b より前に並ぶかどうかを返します。
+// "track2" は "track10" より前になります。全角数字も数値として扱います。
+func NaturalLess(a, b string) bool {
+	if c := naturalCompare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// naturalCompare は数字の並びを数値として a と b を比較します（a が前なら負、後なら正、同じ順位なら0）。
+// 大文字小文字は区別せず、数値が同じで桁数だけが異なる場合（"01" と "1"）は同じ順位とします。
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		da, restA := leadingDigits(a)
+		db, restB := leadingDigits(b)
+		if da != "" && db != "" {
+			if c := compareNumbers(da, db); c != 0 {
+				return c
+			}
+			a, b = restA, restB
+			continue
+		}
+
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		ra, rb = unicode.ToLower(ra), unicode.ToLower(rb)
+		if ra != rb {
+			if ra < rb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return len(a) - len(b)
+}
+
+// leadingDigits は s の先頭に続く数字を半角に変換して返し、残りの文字列とともに返します。
+func leadingDigits(s string) (digits, rest string) {
+	var b strings.Builder
+	for s != "" {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= '０' && r <= '９':
+			b.WriteRune('0' + (r - '０'))
+		default:
+			return b.String(), s
+		}
+		s = s[size:]
+	}
+	return b.String(), s
+}
+
+// compareNumbers は数字の並びを数値として比較します。
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// discGroup は作品ディレクトリからの相対的なディレクトリのうち、ディスクの判定に使用する部分を返します。
+// "wav" や "MP3版" のような形式だけを表すディレクトリは、ディスクの区別に使用しません。
+func discGroup(directory, file string) string {
+	rel, err := filepath.Rel(directory, filepath.Dir(file))
+	if err != nil || rel == "." {
+		return ""
+	}
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if isFormatDir(part) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// DiscDir は音声ファイルのディスクのディレクトリ（作品ディレクトリからの相対パス、区切りは "/"）を返します。
+// 形式だけを表すディレクトリは含めず、作品直下のファイルの場合は空文字列です。
+func DiscDir(directory, file string) string {
+	return discGroup(directory, file)
+}
+
+// isFormatDir は音声の形式だけを表すディレクトリ名（"wav"、"MP3版"、"flac形式" など、input_formats に指定できる形式）かどうかを返します。
+func isFormatDir(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, suffix := range []string{"版", "形式"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	_, ok := config.InputFormat(name)
+	return ok
+}
+
+// isBonusDir はおまけ・特典のディレクトリかどうかを返します。
+func isBonusDir(group string) bool {
+	lower := strings.ToLower(group)
+	for _, keyword := range bonusKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortAudioFiles は音声ファイルをディスク（サブディレクトリ）ごとに、ファイル名の自然順で並べ替えます。
+// ディスクは作品直下のファイル、本編、おまけ・特典の順で、同じ種類の中ではディレクトリ名の自然順です。
+func sortAudioFiles(directory string, files []string) {
+	type key struct {
+		bonus bool
+		group string
+		name  string
+	}
+	keys := make(map[string]key, len(files))
+	for _, file := range files {
+		group := discGroup(directory, file)
+		keys[file] = key{bonus: isBonusDir(group), group: group, name: filepath.Base(file)}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := keys[files[i]], keys[files[j]]
+		if a.bonus != b.bonus {
+			return !a.bonus
+		}
+		if a.group != b.group {
+			if a.group == "" || b.group == "" {
+				return a.group == ""
+			}
+			return NaturalLess(a.group, b.group)
+		}
+		if a.name != b.name {
+			return NaturalLess(a.name, b.name)
+		}
+		return files[i] < files[j]
+	})
+}
+
+// NumberTracks は FindAudioFiles が返した順序の音声ファイルに、トラック番号とディスク番号を割り当てます。
+// ファイルが複数のサブディレクトリ（"本編" と "おまけ"、"Disc1" と "Disc2" など）に分かれている場合は
+// ディレクトリごとに1枚のディスクとし、トラック番号はディスクごとに1から振ります。
+func NumberTracks(directory string, files []string) []TrackNumber {
+	groups := make([]string, len(files))
+	counts := make(map[string]int)
+	var order []string
+	for i, file := range files {
+		groups[i] = discGroup(directory, file)
+		if counts[groups[i]] == 0 {
+			order = append(order, groups[i])
+		}
+		counts[groups[i]]++
+	}
+
+	discs := make(map[string]int, len(order))
+	if len(order) > 1 {
+		for i, group := range order {
+			discs[group] = i + 1
+		}
+	}
+
+	numbers := make([]TrackNumber, len(files))
+	seen := make(map[string]int)
+	for i, group := range groups {
+		seen[group]++
+		numbers[i] = TrackNumber{Track: seen[group], TrackTotal: counts[group]}
+		if disc := discs[group]; disc > 0 {
+			numbers[i].Disc = disc
+			numbers[i].DiscTotal = len(order)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,95 @@
+package audioconverter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+)
+
+func TestNaturalLess(t *testing.T) {
+	names := []string{"track10.wav", "Track1.wav", "track2.wav", "トラック１０", "トラック２", "01_本編", "1_本編", "b", "A"}
+	sort.SliceStable(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+	want := []string{"01_本編", "1_本編", "A", "b", "Track1.wav", "track2.wav", "track10.wav", "トラック２", "トラック１０"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("NaturalLess による並び順:\ngot  %v\nwant %v", names, want)
+	}
+}
+
+func TestSelectAudioFilesOrderAndNumbering(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{}
+	files := []string{
+		"おまけ/フリートーク.wav",
+		"本編/track10.wav",
+		"本編/track2.wav",
+		"本編/track1.wav",
+		"wav/特典/ボイス.wav",
+	}
+	for _, name := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("ディレクトリの作成に失敗: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("dummy"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	selected := FindAudioFiles(tempDir, cfg)
+	var got []string
+	for _, file := range selected {
+		rel, _ := filepath.Rel(tempDir, file)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"本編/track1.wav", "本編/track2.wav", "本編/track10.wav", "おまけ/フリートーク.wav", "wav/特典/ボイス.wav"}
+	if !slices.Equal(got, want) {
+		t.Errorf("音声ファイルの並び順:\ngot  %v\nwant %v", got, want)
+	}
+
+	numbers := NumberTracks(tempDir, selected)
+	wantNumbers := []TrackNumber{
+		{Track: 1, TrackTotal: 3, Disc: 1, DiscTotal: 3},
+		{Track: 2, TrackTotal: 3, Disc: 1, DiscTotal: 3},
+		{Track: 3, TrackTotal: 3, Disc: 1, DiscTotal: 3},
+		{Track: 1, TrackTotal: 1, Disc: 2, DiscTotal: 3},
+		{Track: 1, TrackTotal: 1, Disc: 3, DiscTotal: 3},
+	}
+	if !reflect.DeepEqual(numbers, wantNumbers) {
+		t.Errorf("NumberTracks:\ngot  %+v\nwant %+v", numbers, wantNumbers)
+	}
+}
+
+func TestNumberTracksWithoutDiscs(t *testing.T) {
+	// 形式だけを表すディレクトリはディスクとして扱わない
+	dir := filepath.Join("source", "RJ01234567")
+	files := []string{
+		filepath.Join(dir, "WAV版", "01.wav"),
+		filepath.Join(dir, "WAV版", "02.wav"),
+		filepath.Join(dir, "mp3", "03.mp3"),
+	}
+	want := []TrackNumber{{Track: 1, TrackTotal: 3}, {Track: 2, TrackTotal: 3}, {Track: 3, TrackTotal: 3}}
+	if got := NumberTracks(dir, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("NumberTracks:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMetadataTrackTags(t *testing.T) {
+	metadata := MP3Metadata{TrackName: "01", Track: 1, TrackTotal: 12, Disc: 2, DiscTotal: 2}
+	tags := metadata.TagMap()
+	if tags["track"] != "1/12" || tags["disc"] != "2/2" {
+		t.Errorf("トラック番号・ディスク番号のタグ: got %q, %q", tags["track"], tags["disc"])
+	}
+
+	tags = MP3Metadata{TrackName: "01"}.TagMap()
+	if _, ok := tags["track"]; ok {
+		t.Error("トラック番号が0の場合は track を設定すべきではありません")
+	}
+	if _, ok := tags["disc"]; ok {
+		t.Error("ディスク番号が0の場合は disc を設定すべきではありません")
+	}
+}
@@ -0,0 +1,45 @@
+package audioconverter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kkryama/dls-encoder/internal/id3"
+)
+
+// mp3ScanSize は ID3v2 タグの後から最初のフレームを探す範囲のバイト数です。
+const mp3ScanSize = 8192
+
+// MP3IsVBR は MP3 ファイルの最初のフレームのヘッダー（Xing / VBRI）から、可変ビットレートかどうかを判定します。
+// LAME などのエンコーダーは固定ビットレートの場合に Xing の代わりに Info を書き込みます。
+// どちらのヘッダーもない場合は固定ビットレートとみなします。
+func MP3IsVBR(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("ファイルのオープンに失敗: %w", err)
+	}
+	defer file.Close()
+
+	offset, err := id3.TagSize(file)
+	if err != nil {
+		return false, fmt.Errorf("ID3v2 タグの読み込みに失敗: %w", err)
+	}
+	buf := make([]byte, mp3ScanSize)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("ファイルの読み込みに失敗: %w", err)
+	}
+	buf = buf[:n]
+
+	for i := 0; i+1 < len(buf); i++ {
+		if buf[i] != 0xFF || buf[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+		// Xing / Info はサイド情報の後（最大で先頭から36バイト）、VBRI は先頭から36バイトの位置にある
+		frame := buf[i:min(i+40, len(buf))]
+		return bytes.Contains(frame, []byte("Xing")) || bytes.Contains(frame, []byte("VBRI")), nil
+	}
+	return false, fmt.Errorf("MP3 のフレームが見つかりません: %s", path)
+}
@@ -0,0 +1,65 @@
+package audioconverter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFormatPassthrough(t *testing.T) {
+	format := FormatMP3().WithFilter("volume=0.5", 44100).Passthrough()
+	if got := format.codecArgs(); !slices.Equal(got, []string{"-c:a", "copy"}) {
+		t.Errorf("コピーする場合はエンコード設定とフィルタを指定しない: %q", got)
+	}
+	if got, want := format.Signature(), "mp3: -c:a copy -id3v2_version 3"; got != want {
+		t.Errorf("Signature: got %q, want %q", got, want)
+	}
+
+	cover := "/img/cover.jpg"
+	args := strings.Join(format.Args("/src/01.mp3", "/out/01.mp3", MP3Metadata{TrackName: "01", CoverImage: &cover}, ""), " ")
+	for _, want := range []string{"-map 0:a -map 1:v", "-disposition:v attached_pic", "-map_metadata -1", "-c:a copy", "-metadata title=01"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("引数に %q が含まれていません: %s", want, args)
+		}
+	}
+}
+
+func TestMP3IsVBR(t *testing.T) {
+	frame := func(marker string) string {
+		// MPEG1 Layer III ステレオのフレームヘッダーとサイド情報（32バイト）の後に Xing / Info ヘッダーを置く
+		return "\xff\xfb\x90\x00" + strings.Repeat("\x00", 32) + marker + strings.Repeat("\x00", 100)
+	}
+	testCases := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"Xing", frame("Xing"), true},
+		{"Info", frame("Info"), false},
+		{"ヘッダーなし", frame("\x00\x00\x00\x00"), false},
+		{"VBRI", frame("VBRI"), true},
+		// タグ内の文字列はフレームとして扱わない
+		{"ID3v2タグの後", "ID3\x03\x00\x00\x00\x00\x00\x08\xff\xfb\x00\x00Xing" + frame("Info"), false},
+	}
+	dir := t.TempDir()
+	for i, tc := range testCases {
+		path := filepath.Join(dir, string(rune('a'+i))+".mp3")
+		if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := MP3IsVBR(path)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %v, %v, want %v", tc.name, got, err, tc.want)
+		}
+	}
+
+	path := filepath.Join(dir, "empty.mp3")
+	if err := os.WriteFile(path, []byte("not audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MP3IsVBR(path); err == nil {
+		t.Error("フレームが見つからない場合はエラーが発生すべき")
+	}
+}
@@ -0,0 +1,150 @@
+package audioconverter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // DecodeConfig で JPEG を判別するため
+	_ "image/png"  // DecodeConfig で PNG を判別するため
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pictureTypeFrontCover は FLAC の PICTURE ブロックにおける表紙画像の種別です。
+const pictureTypeFrontCover = 3
+
+// writePictureMetadata はカバー画像を METADATA_BLOCK_PICTURE として記述した ffmetadata ファイルを一時ディレクトリに作成し、そのパスを返します。
+// 画像が大きいと base64 の値がコマンドライン引数の長さの上限を超えるため、-metadata ではなくファイルで渡します。
+func writePictureMetadata(ctx context.Context, coverImage string) (string, error) {
+	data, err := readCoverImage(ctx, coverImage)
+	if err != nil {
+		return "", err
+	}
+	block, err := pictureBlock(data)
+	if err != nil {
+		return "", err
+	}
+	return writeFFMetadata(";FFMETADATA1\nMETADATA_BLOCK_PICTURE=" + escapeFFMetadata(base64.StdEncoding.EncodeToString(block)) + "\n")
+}
+
+// writeFFMetadata は ffmetadata ファイルを一時ディレクトリに作成し、そのパスを返します。
+func writeFFMetadata(content string) (string, error) {
+	file, err := os.CreateTemp("", "dls-encoder-*.ffmetadata")
+	if err != nil {
+		return "", fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("一時ファイルの書き込みに失敗: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("一時ファイルの書き込みに失敗: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// readCoverImage はカバー画像を JPEG または PNG のデータとして読み込みます。
+// WebP など Vorbis コメントで広く扱えない形式の場合は、ffmpeg で JPEG に変換します。
+func readCoverImage(ctx context.Context, coverImage string) ([]byte, error) {
+	data, err := os.ReadFile(coverImage)
+	if err != nil {
+		return nil, fmt.Errorf("カバー画像の読み込みに失敗: %w", err)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		return data, nil
+	}
+	return convertToJPEG(ctx, coverImage)
+}
+
+// CoverJPEG はカバー画像を JPEG のデータとして読み込みます。
+// MP3 の変換時に ffmpeg が JPEG（mjpeg）で埋め込むのと同様に、JPEG 以外の画像は ffmpeg で JPEG に変換します。
+func CoverJPEG(ctx context.Context, coverImage string) ([]byte, error) {
+	data, err := os.ReadFile(coverImage)
+	if err != nil {
+		return nil, fmt.Errorf("カバー画像の読み込みに失敗: %w", err)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && format == "jpeg" {
+		return data, nil
+	}
+	return convertToJPEG(ctx, coverImage)
+}
+
+// convertToJPEG は ffmpeg で画像を JPEG に変換し、変換後のデータを返します。
+func convertToJPEG(ctx context.Context, coverImage string) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "dls-encoder-cover-*")
+	if err != nil {
+		return nil, fmt.Errorf("一時ディレクトリの作成に失敗: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	jpegPath := filepath.Join(tmpDir, "cover.jpg")
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-i", coverImage, "-frames:v", "1", "-c:v", "mjpeg", "-y", jpegPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("カバー画像のJPEG変換に失敗: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	data, err := os.ReadFile(jpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("カバー画像の読み込みに失敗: %w", err)
+	}
+	return data, nil
+}
+
+// pictureBlock は画像データから FLAC の PICTURE メタデータブロック（Vorbis コメントの METADATA_BLOCK_PICTURE の中身）を作成します。
+func pictureBlock(data []byte) ([]byte, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("カバー画像の形式を判別できません: %w", err)
+	}
+
+	mime := "image/" + format
+	var buf bytes.Buffer
+	write := func(v uint32) { binary.Write(&buf, binary.BigEndian, v) }
+	write(pictureTypeFrontCover)
+	write(uint32(len(mime)))
+	buf.WriteString(mime)
+	write(0) // 説明文なし
+	write(uint32(config.Width))
+	write(uint32(config.Height))
+	write(colorDepth(config.ColorModel))
+	write(0) // インデックスカラーの色数（使用しない）
+	write(uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// colorDepth は画像の1ピクセルあたりのビット数を返します。
+func colorDepth(model color.Model) uint32 {
+	switch model {
+	case color.GrayModel:
+		return 8
+	case color.Gray16Model:
+		return 16
+	case color.RGBAModel, color.NRGBAModel:
+		return 32
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 64
+	default:
+		return 24
+	}
+}
+
+// escapeFFMetadata は ffmetadata ファイルの値として特殊文字をエスケープします。
+func escapeFFMetadata(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '=', ';', '#', '\\', '\n':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
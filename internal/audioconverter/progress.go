@@ -0,0 +1,89 @@
+package audioconverter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress は ffmpeg の -progress 出力から読み取った変換の進捗です。
+type Progress struct {
+	OutTime time.Duration // 変換済みの再生位置
+	Speed   float64       // 再生速度に対する変換速度の倍率（不明な場合は0）
+	Done    bool          // 変換が終了したかどうか
+}
+
+// ConvertOptions は変換時の追加オプションです。
+type ConvertOptions struct {
+	// OnProgress を指定すると ffmpeg に -progress pipe:1 を渡し、進捗を受け取るたびに呼び出します。
+	OnProgress func(Progress)
+}
+
+// progressArgs は ffmpeg に進捗を標準出力へ書き出させる引数です。
+func progressArgs() []string {
+	return []string{"-progress", "pipe:1", "-nostats"}
+}
+
+// ParseProgress は ffmpeg の -progress 出力（key=value 形式）を読み込み、
+// progress=continue / progress=end の行ごとにそれまでの値を fn に渡します。
+func ParseProgress(r io.Reader, fn func(Progress)) error {
+	var current Progress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "out_time_us":
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				current.OutTime = time.Duration(us) * time.Microsecond
+			}
+		case "out_time":
+			// out_time_us が出力されない古い ffmpeg 向け
+			if current.OutTime == 0 {
+				if d, err := parseClock(value); err == nil {
+					current.OutTime = d
+				}
+			}
+		case "speed":
+			if speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+				current.Speed = speed
+			}
+		case "progress":
+			current.Done = value == "end"
+			fn(current)
+			current = Progress{OutTime: current.OutTime, Speed: current.Speed}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("進捗の読み込みに失敗: %w", err)
+	}
+	return nil
+}
+
+// parseClock は HH:MM:SS.ffffff 形式の時刻を変換します。
+func parseClock(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("時刻の形式が正しくありません: %s", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), nil
+}
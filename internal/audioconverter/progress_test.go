@@ -0,0 +1,111 @@
+package audioconverter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	input := strings.Join([]string{
+		"frame=0",
+		"out_time_us=1500000",
+		"out_time=00:00:01.500000",
+		"speed=12.5x",
+		"progress=continue",
+		"out_time_us=N/A",
+		"speed=N/A",
+		"progress=continue",
+		"out_time_us=3000000",
+		"speed=13x",
+		"progress=end",
+	}, "\n")
+
+	var got []Progress
+	if err := ParseProgress(strings.NewReader(input), func(p Progress) { got = append(got, p) }); err != nil {
+		t.Fatalf("ParseProgress でエラー: %v", err)
+	}
+
+	want := []Progress{
+		{OutTime: 1500 * time.Millisecond, Speed: 12.5},
+		{OutTime: 1500 * time.Millisecond, Speed: 12.5}, // N/A の場合は直前の値を維持する
+		{OutTime: 3 * time.Second, Speed: 13, Done: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("進捗の件数が一致しません: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("進捗[%d]: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseProgress_OutTimeFallback(t *testing.T) {
+	var got []Progress
+	err := ParseProgress(strings.NewReader("out_time=01:02:03.500000\nprogress=end\n"), func(p Progress) { got = append(got, p) })
+	if err != nil {
+		t.Fatalf("ParseProgress でエラー: %v", err)
+	}
+	want := time.Hour + 2*time.Minute + 3500*time.Millisecond
+	if len(got) != 1 || got[0].OutTime != want {
+		t.Errorf("out_time から再生位置を取得できません: got %+v, want %s", got, want)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	if got, err := parseClock("00:10:05.250000"); err != nil || got != 10*time.Minute+5250*time.Millisecond {
+		t.Errorf("parseClock: got %s, %v", got, err)
+	}
+	for _, value := range []string{"", "10:05", "aa:00:00", "00:00:xx"} {
+		if _, err := parseClock(value); err == nil {
+			t.Errorf("不正な時刻 %q でエラーが発生すべき", value)
+		}
+	}
+}
+
+func TestConvertFileToMp3WithOptions_Progress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("シェルスクリプトを使用するため Windows ではスキップ")
+	}
+
+	// -progress pipe:1 を受け取った場合のみ進捗を出力する偽の ffmpeg
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+for arg in "$@"; do last="$arg"; done
+case "$*" in
+*"-progress pipe:1"*)
+	echo "out_time_us=500000"
+	echo "speed=2x"
+	echo "progress=continue"
+	echo "out_time_us=1000000"
+	echo "progress=end"
+	;;
+esac
+echo encoded > "$last"
+`
+	if err := os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tempDir := t.TempDir()
+	output := filepath.Join(tempDir, "out.mp3")
+	var got []Progress
+	err := ConvertFileToMp3WithOptions(context.Background(), filepath.Join(tempDir, "in.wav"), output, MP3Metadata{}, ConvertOptions{
+		OnProgress: func(p Progress) { got = append(got, p) },
+	})
+	if err != nil {
+		t.Fatalf("変換に失敗: %v", err)
+	}
+	if len(got) != 2 || !got[1].Done || got[1].OutTime != time.Second {
+		t.Errorf("進捗を受け取れていません: %+v", got)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("出力ファイルが作成されていません: %v", err)
+	}
+}
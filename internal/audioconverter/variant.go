@@ -0,0 +1,167 @@
+package audioconverter
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/logger"
+	"github.com/kkryama/dls-encoder/internal/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// variantTrim は版のラベルを取り除いた後に、ディレクトリ名・ファイル名の前後から取り除く区切りの文字です。
+const variantTrim = " 　_-.・()[]（）【】「」"
+
+// variantFile は版の選択に使用する音声ファイルの情報です。
+type variantFile struct {
+	path  string // ファイルのパス
+	label int    // 一致したラベルの位置（一致しない場合は -1）
+	slot  string // ラベルを取り除いた相対パス（同じトラックの別の版は同じ値になる）
+}
+
+// selectVariants は [[variant]] の規則を順に適用し、同じトラックの別の版のうち最も優先するラベルのファイルだけを残します。
+// 1つの版にしかないトラックと、どのラベルにも一致しないファイルはそのまま残します。
+func selectVariants(directory string, files []string, rules []config.VariantRule) ([]string, []model.SkippedFile) {
+	var skipped []model.SkippedFile
+	for _, rule := range rules {
+		entries := make([]variantFile, 0, len(files))
+		best := make(map[string]int)         // ラベルを取り除いた相対パスごとの、最も優先するラベルの位置
+		preferred := make(map[string]string) // ラベルを取り除いた相対パスごとの、最も優先するラベルのファイル
+		for _, file := range files {
+			entry := matchVariant(directory, file, rule.Labels)
+			entries = append(entries, entry)
+			if entry.label < 0 {
+				continue
+			}
+			if b, ok := best[entry.slot]; !ok || entry.label < b {
+				best[entry.slot] = entry.label
+				preferred[entry.slot] = file
+			}
+		}
+
+		remaining := make([]string, 0, len(files))
+		for _, entry := range entries {
+			if entry.label < 0 || entry.label == best[entry.slot] {
+				remaining = append(remaining, entry.path)
+				continue
+			}
+			skip := model.SkippedFile{
+				Path:             entry.path,
+				Reason:           SkipReasonVariant,
+				Variant:          rule.Labels[entry.label],
+				PreferredVariant: rule.Labels[best[entry.slot]],
+				PreferredPath:    preferred[entry.slot],
+			}
+			skipped = append(skipped, skip)
+			logger.LogDebugEvent("audio_file_variant_skipped", map[string]interface{}{
+				"rule":              rule.DisplayName(),
+				"path":              skip.Path,
+				"variant":           skip.Variant,
+				"preferred_variant": skip.PreferredVariant,
+				"preferred_path":    skip.PreferredPath,
+				"message":           fmt.Sprintf("%s の版は %s より %s を優先するため '%s' を除外しました。", rule.DisplayName(), skip.Variant, skip.PreferredVariant, skip.Path),
+			})
+		}
+		files = remaining
+	}
+	return files, skipped
+}
+
+// matchVariant は作品ディレクトリからの相対パス（拡張子を除く）のディレクトリ名・ファイル名に含まれるラベルを調べます。
+// 複数のラベルを含む場合は、優先する順で最初のラベルを使用します。
+// ディレクトリ名・ファイル名とラベルは同じように正規化して比較し（normalizeVariant）、規則のラベルをすべて取り除いたものを slot とします。
+func matchVariant(directory, file string, labels []string) variantFile {
+	entry := variantFile{path: file, label: -1}
+	rel, err := filepath.Rel(directory, file)
+	if err != nil {
+		rel = file
+	}
+	rel = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+
+	slot := make([]string, 0, strings.Count(rel, "/")+1)
+	for _, part := range strings.Split(normalizeVariant(rel), "/") {
+		part, matched := stripLabels(part, labels)
+		for _, i := range matched {
+			if entry.label < 0 || i < entry.label {
+				entry.label = i
+			}
+		}
+		if part = strings.Trim(part, variantTrim); part != "" {
+			slot = append(slot, part)
+		}
+	}
+	if entry.label >= 0 {
+		entry.slot = strings.Join(slot, "/")
+	}
+	return entry
+}
+
+// normalizeVariant は版のラベルとの比較のために文字列を正規化します。全角英数字を半角に、英字を小文字にします。
+func normalizeVariant(s string) string {
+	return strings.ToLower(norm.NFKC.String(s))
+}
+
+// variantOkurigana は版のラベルの直後に続く場合に、ラベルの一部として取り除くひらがなの最大文字数です。
+// ラベル "SE有" を含む "SE有り" と "SE無" を含む "SE無し" のように、送り仮名の有無を書き分けたラベルを省略できます。
+const variantOkurigana = 2
+
+// stripLabels は正規化したディレクトリ名・ファイル名 name からラベルをすべて取り除き、含まれていたラベルの位置を返します。
+// 長いラベルから順に取り除くため、"SE有" と "SE有り" のように一方が他方を含むラベルは、その順序によらず長い方に一致します。
+func stripLabels(name string, labels []string) (string, []int) {
+	order := make([]int, len(labels))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return len(normalizeVariant(labels[b])) - len(normalizeVariant(labels[a]))
+	})
+
+	var matched []int
+	for _, i := range order {
+		label := normalizeVariant(labels[i])
+		if label == "" {
+			continue
+		}
+		found := false
+		for {
+			start := strings.Index(name, label)
+			if start < 0 {
+				break
+			}
+			found = true
+			end := okuriganaEnd(name, start+len(label))
+			name = name[:start] + name[end:]
+		}
+		if found {
+			matched = append(matched, i)
+		}
+	}
+	return name, matched
+}
+
+// okuriganaEnd は name の end の位置から続く送り仮名（variantOkurigana 文字以内のひらがなで、直後が区切りの文字または末尾のもの）の終わりの位置を返します。
+// 送り仮名が続かない場合は end を返します。
+func okuriganaEnd(name string, end int) int {
+	pos := end
+	for n := 0; n < variantOkurigana && pos < len(name); n++ {
+		r, size := utf8.DecodeRuneInString(name[pos:])
+		if !unicode.Is(unicode.Hiragana, r) {
+			break
+		}
+		pos += size
+	}
+	if pos == end {
+		return end
+	}
+	if pos < len(name) {
+		if r, _ := utf8.DecodeRuneInString(name[pos:]); !strings.ContainsRune(variantTrim, r) {
+			return end
+		}
+	}
+	return pos
+}
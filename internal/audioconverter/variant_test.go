@@ -0,0 +1,117 @@
+package audioconverter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/config"
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestSelectAudioFilesVariants(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{VariantRules: []config.VariantRule{
+		{Name: "SE", Labels: []string{"SE有", "SE無"}},
+		{Labels: []string{"24bit", "16bit"}},
+	}}
+
+	for _, name := range []string{
+		"SE有_16bit/01.wav",
+		"SE有_24bit/01.wav",
+		"SE無_16bit/01.wav",
+		"SE無_24bit/01.wav",
+		"SE無_24bit/02_おまけ.wav", // SE無 にしかないトラックは残す
+		"特典/03.wav",            // どのラベルにも一致しないファイルは残す
+	} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("ディレクトリの作成に失敗: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("dummy audio data"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(tempDir, filepath.FromSlash(name)) }
+
+	selection := SelectAudioFiles(tempDir, cfg)
+	wantFiles := []string{path("SE有_24bit/01.wav"), path("SE無_24bit/02_おまけ.wav"), path("特典/03.wav")}
+	if !reflect.DeepEqual(selection.Files, wantFiles) {
+		t.Errorf("Files: got %v, want %v", selection.Files, wantFiles)
+	}
+
+	wantSkipped := []model.SkippedFile{
+		{Path: path("SE無_16bit/01.wav"), Reason: SkipReasonVariant, Variant: "SE無", PreferredVariant: "SE有", PreferredPath: path("SE有_16bit/01.wav")},
+		{Path: path("SE無_24bit/01.wav"), Reason: SkipReasonVariant, Variant: "SE無", PreferredVariant: "SE有", PreferredPath: path("SE有_24bit/01.wav")},
+		{Path: path("SE有_16bit/01.wav"), Reason: SkipReasonVariant, Variant: "16bit", PreferredVariant: "24bit", PreferredPath: path("SE有_24bit/01.wav")},
+	}
+	if !reflect.DeepEqual(selection.Skipped, wantSkipped) {
+		t.Errorf("Skipped:\n got  %+v\n want %+v", selection.Skipped, wantSkipped)
+	}
+}
+
+func TestMatchVariant(t *testing.T) {
+	t.Parallel()
+
+	labels := []string{"SE有り", "SE有", "SE無し", "SE無"}
+	testCases := []struct {
+		file  string
+		label int
+		slot  string
+	}{
+		{"本編（SE有り）/01 はじまり.wav", 0, "本編/01 はじまり"},
+		{"本編（SE無し）/01 はじまり.wav", 2, "本編/01 はじまり"},
+		{"本編/01 はじまり_se無.flac", 3, "本編/01 はじまり"},
+		{"本編/01 はじまり.wav", -1, ""},
+	}
+	for _, tc := range testCases {
+		got := matchVariant("/work", filepath.Join("/work", filepath.FromSlash(tc.file)), labels)
+		if got.label != tc.label || got.slot != tc.slot {
+			t.Errorf("%s: got (%d, %q), want (%d, %q)", tc.file, got.label, got.slot, tc.label, tc.slot)
+		}
+	}
+}
+
+func TestMatchVariantPartialLabels(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		labels []string
+		files  []string // 同じトラックの別の版として対応付けるべきファイル
+	}{
+		// 送り仮名を書き分けたラベルを省略しても対応付ける
+		{"送り仮名を省略", []string{"SE有", "SE無"}, []string{"SE有り/01 はじまり.wav", "SE無し/01 はじまり.wav"}},
+		{"区切りの前の送り仮名", []string{"SE有", "SE無"}, []string{"本編_SE有り_24bit/01.wav", "本編_SE無し_24bit/01.wav"}},
+		// 一方が他方を含むラベルは、順序によらず長い方を取り除く
+		{"短いラベルが先", []string{"SE有", "SE有り", "SE無し"}, []string{"SE有り/01.wav", "SE無し/01.wav"}},
+		{"長いラベルが先", []string{"SE有り", "SE有", "SE無し"}, []string{"SE有り/01.wav", "SE無し/01.wav"}},
+		// 全角英数字・大文字小文字はラベルと同じように正規化する
+		{"全角英字", []string{"SE有", "SE無"}, []string{"ＳＥ有り/01.wav", "se無し/01.wav"}},
+	}
+	for _, tc := range testCases {
+		var slots []string
+		for _, file := range tc.files {
+			got := matchVariant("/work", filepath.Join("/work", filepath.FromSlash(file)), tc.labels)
+			if got.label < 0 {
+				t.Errorf("%s: %s がラベルに一致しません", tc.name, file)
+			}
+			slots = append(slots, got.slot)
+		}
+		if slots[0] != slots[1] {
+			t.Errorf("%s: 別の版が対応付けられません: %q", tc.name, slots)
+		}
+	}
+
+	// 送り仮名に続けて区切りのない文字がある場合は、ラベルの一部として取り除かない
+	got := matchVariant("/work", "/work/SE有りのおまけ.wav", []string{"SE有", "SE無"})
+	if got.label != 0 || got.slot != "りのおまけ" {
+		t.Errorf("区切りのない送り仮名: got (%d, %q)", got.label, got.slot)
+	}
+	// 長いラベルの位置を一致したラベルとする
+	got = matchVariant("/work", "/work/SE有り/01.wav", []string{"SE有", "SE無", "SE有り"})
+	if got.label != 2 || got.slot != "01" {
+		t.Errorf("長いラベルに一致すべき: got (%d, %q)", got.label, got.slot)
+	}
+}
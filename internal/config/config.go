@@ -4,12 +4,24 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 )
 
 type Config struct {
 	Setting       Setting       `mapstructure:"setting"`
 	DirSetting    DirSetting    `mapstructure:"dir_setting"`
 	SanitizeRules SanitizeRules `mapstructure:",squash"`
+
+	Presets     map[string]Preset `mapstructure:"preset"`      // 名前ごとのエンコード設定のプリセット
+	WorkPresets []WorkPreset      `mapstructure:"work_preset"` // 作品ごとに使用するプリセット
+
+	TagMappings []TagMapping `mapstructure:"tag_mapping"` // 作品データから出力ファイルのタグへの対応（未定義の場合は DefaultTagMappings）
+
+	VariantRules []VariantRule `mapstructure:"variant"` // 同じトラックを別の版で収録した作品から変換する版を選ぶ規則（上から順に適用）
+
+	File           string `mapstructure:"-"` // 読み込んだ設定ファイルのパス
+	Profile        string `mapstructure:"-"` // 適用したプロファイル名
+	PresetOverride string `mapstructure:"-"` // コマンドラインで指定したプリセット名（すべての作品に優先して使用する）
 }
 
 // Validate は設定値の妥当性をチェック
@@ -26,6 +38,66 @@ func (c *Config) Validate() error {
 		{c.DirSetting.ImageDir, "image_dir"},
 	}
 
+	if c.Setting.Workers < 0 {
+		return fmt.Errorf("workersには0以上の値を指定してください: %d", c.Setting.Workers)
+	}
+
+	if err := c.Setting.validateInputFormats(); err != nil {
+		return err
+	}
+	if err := c.validatePresets(); err != nil {
+		return err
+	}
+	if err := c.validateTagMappings(); err != nil {
+		return err
+	}
+	if err := c.validateVariants(); err != nil {
+		return err
+	}
+
+	switch c.Setting.ProgressMode() {
+	case ProgressAuto, ProgressTTY, ProgressLog, ProgressOff:
+	default:
+		return fmt.Errorf("progressには auto / tty / log / off のいずれかを指定してください: %s", c.Setting.Progress)
+	}
+
+	switch c.Setting.OutputLayoutMode() {
+	case OutputLayoutFlat, OutputLayoutSubdir:
+	default:
+		return fmt.Errorf("output_layoutには flat / subdir のいずれかを指定してください: %s", c.Setting.OutputLayout)
+	}
+
+	switch c.Setting.LoudnessMode() {
+	case LoudnessOff, LoudnessLoudnorm, LoudnessReplayGain:
+	default:
+		return fmt.Errorf("loudnessには off / loudnorm / replaygain のいずれかを指定してください: %s", c.Setting.Loudness)
+	}
+
+	if c.Setting.MP3PassthroughMaxBitrate < 0 {
+		return fmt.Errorf("mp3_passthrough_max_bitrateには0以上の値を指定してください: %d", c.Setting.MP3PassthroughMaxBitrate)
+	}
+
+	switch c.Setting.AudiobookMode() {
+	case AudiobookOff, AudiobookM4B, AudiobookMP3:
+	default:
+		return fmt.Errorf("audiobookには off / m4b / mp3 のいずれかを指定してください: %s", c.Setting.Audiobook)
+	}
+	// ラウドネスはトラックごとに測定するため、連結した1ファイルには適用できない
+	if c.Setting.AudiobookMode() != AudiobookOff && c.Setting.LoudnessMode() != LoudnessOff {
+		return fmt.Errorf("audiobookを使用する場合はloudnessに off を指定してください: %s", c.Setting.Loudness)
+	}
+	// loudnorm フィルタが受け付ける範囲
+	if target := c.Setting.LoudnessTargetLUFS(); target < -70 || target > -5 {
+		return fmt.Errorf("loudness_targetには -70〜-5 の値を指定してください: %g", target)
+	}
+	if peak := c.Setting.LoudnessTruePeakDB(); peak < -9 || peak > 0 {
+		return fmt.Errorf("loudness_true_peakには -9〜0 の値を指定してください: %g", peak)
+	}
+
+	if err := c.Setting.validateCover(); err != nil {
+		return err
+	}
+
 	for _, dir := range dirs {
 		if dir.path == "" {
 			return fmt.Errorf("%sが設定されていません", dir.name)
@@ -54,6 +126,130 @@ type Setting struct {
 	Convert        bool     `mapstructure:"convert"`
 	Debug          bool     `mapstructure:"debug"`
 	ExcludeStrings []string `mapstructure:"exclude_strings"`
+	Workers        int      `mapstructure:"workers"`
+
+	InputFormats []string `mapstructure:"input_formats"` // 変換元として扱う拡張子（優先する順、未設定の場合は DefaultInputFormats）
+
+	ContinueOnError bool `mapstructure:"continue_on_error"` // 作品の変換に失敗しても残りの作品の処理を継続するかどうか
+
+	Incremental         bool `mapstructure:"incremental"`          // 変更のない作品・トラックの再エンコードを省略するかどうか
+	IncrementalChecksum bool `mapstructure:"incremental_checksum"` // 変更の判定にファイル内容のハッシュを使用するかどうか
+
+	ReportHTML bool `mapstructure:"report_html"` // 実行レポートをJSONに加えてHTMLでも保存するかどうか
+
+	Progress string `mapstructure:"progress"` // 変換の進捗の表示方法（auto / tty / log / off）
+
+	OutputFormat string `mapstructure:"output_format"` // 出力形式（mp3 / m4a / opus / ogg / flac、未設定の場合は mp3）
+	Preset       string `mapstructure:"preset"`        // 使用するエンコード設定のプリセット名（未設定の場合は出力形式ごとの既定値）
+	OutputLayout string `mapstructure:"output_layout"` // サブディレクトリに分かれた作品の出力ファイルの配置（flat / subdir）
+	Audiobook    string `mapstructure:"audiobook"`     // 作品を1ファイルにまとめて出力する形式（off / m4b / mp3、未設定の場合は off）
+
+	MP3Passthrough           bool `mapstructure:"mp3_passthrough"`             // MP3 の変換元を MP3 で出力する場合に再エンコードせずにコピーするかどうか
+	MP3PassthroughMaxBitrate int  `mapstructure:"mp3_passthrough_max_bitrate"` // 変換元のビットレート（kbps）がこれを超える場合は再エンコードする（0の場合は制限なし）
+	MP3PassthroughCBROnly    bool `mapstructure:"mp3_passthrough_cbr_only"`    // 可変ビットレート（VBR）の変換元は再エンコードするかどうか
+
+	Loudness         string  `mapstructure:"loudness"`           // ラウドネスの調整方法（off / loudnorm / replaygain）
+	LoudnessTarget   float64 `mapstructure:"loudness_target"`    // 目標の統合ラウドネス（LUFS、0の場合は -18）
+	LoudnessTruePeak float64 `mapstructure:"loudness_true_peak"` // トゥルーピークの上限（dBTP、0の場合は -1）
+
+	ExtraTags bool `mapstructure:"extra_tags"` // ジャンル・販売日などの追加情報と作品キーをタグに書き込むかどうか（対応は [[tag_mapping]]）
+
+	CoverProcessing bool     `mapstructure:"cover_processing"` // メイン画像を加工（切り抜き・縮小・JPEG変換）してから埋め込むかどうか
+	CoverMaxSize    int      `mapstructure:"cover_max_size"`   // 加工後の画像の長辺の最大ピクセル数（0の場合は縮小しない）
+	CoverSquare     bool     `mapstructure:"cover_square"`     // 加工時に中央を正方形に切り抜くかどうか
+	CoverQuality    int      `mapstructure:"cover_quality"`    // 加工後のJPEGの品質（1〜100、0の場合は90）
+	CoverFiles      []string `mapstructure:"cover_files"`      // 出力アルバムに保存するフォルダ画像のファイル名（cover.jpg、folder.jpg など）
+}
+
+// サブディレクトリに分かれた作品の出力ファイルの配置
+const (
+	OutputLayoutFlat   = "flat"   // アルバムのディレクトリ直下に出力し、ファイル名が重複する場合のみサブディレクトリ名をファイル名に含める
+	OutputLayoutSubdir = "subdir" // 変換元のサブディレクトリ（形式だけを表すディレクトリを除く）と同じ構成で出力する
+)
+
+// OutputLayoutMode は出力ファイルの配置を返します。未設定の場合は flat です。
+func (s Setting) OutputLayoutMode() string {
+	if s.OutputLayout == "" {
+		return OutputLayoutFlat
+	}
+	return s.OutputLayout
+}
+
+// 作品を1ファイルにまとめて出力する形式
+const (
+	AudiobookOff = "off" // トラックごとに出力する
+	AudiobookM4B = "m4b" // AAC の M4B にまとめる
+	AudiobookMP3 = "mp3" // MP3 にまとめる（チャプターは ID3v2 の CHAP フレーム）
+)
+
+// AudiobookMode は作品を1ファイルにまとめて出力する形式を返します。未設定の場合は off です。
+func (s Setting) AudiobookMode() string {
+	if s.Audiobook == "" {
+		return AudiobookOff
+	}
+	return s.Audiobook
+}
+
+// ラウドネスの調整方法
+const (
+	LoudnessOff        = "off"        // 調整しない
+	LoudnessLoudnorm   = "loudnorm"   // 2パスの loudnorm でエンコード時に音量を変更する
+	LoudnessReplayGain = "replaygain" // 音量は変えず、ReplayGain のタグを書き込む
+)
+
+// ラウドネスの目標値の既定値
+const (
+	DefaultLoudnessTarget   = -18.0 // loudnorm の目標（ReplayGain 2.0 の基準と同じ値）
+	DefaultLoudnessTruePeak = -1.0
+)
+
+// LoudnessMode はラウドネスの調整方法を返します。未設定の場合は off です。
+func (s Setting) LoudnessMode() string {
+	if s.Loudness == "" {
+		return LoudnessOff
+	}
+	return s.Loudness
+}
+
+// LoudnessTargetLUFS は目標の統合ラウドネスを返します。未設定（0）の場合は -18 LUFS です。
+func (s Setting) LoudnessTargetLUFS() float64 {
+	if s.LoudnessTarget == 0 {
+		return DefaultLoudnessTarget
+	}
+	return s.LoudnessTarget
+}
+
+// LoudnessTruePeakDB はトゥルーピークの上限を返します。未設定（0）の場合は -1 dBTP です。
+func (s Setting) LoudnessTruePeakDB() float64 {
+	if s.LoudnessTruePeak == 0 {
+		return DefaultLoudnessTruePeak
+	}
+	return s.LoudnessTruePeak
+}
+
+// 進捗の表示方法
+const (
+	ProgressAuto = "auto" // 標準出力が端末なら tty、それ以外は log
+	ProgressTTY  = "tty"  // 端末の末尾に進捗を表示して更新し続ける
+	ProgressLog  = "log"  // 一定間隔で進捗をログに出力する
+	ProgressOff  = "off"  // 進捗を表示しない
+)
+
+// ProgressMode は進捗の表示方法を返します。未設定の場合は auto です。
+func (s Setting) ProgressMode() string {
+	if s.Progress == "" {
+		return ProgressAuto
+	}
+	return s.Progress
+}
+
+// WorkerCount は変換処理を並列実行するワーカー数を返します。
+// workers が未設定（0）の場合はCPU数を使用します。
+func (s Setting) WorkerCount() int {
+	if s.Workers > 0 {
+		return s.Workers
+	}
+	return runtime.NumCPU()
 }
 
 type SanitizeRules struct {
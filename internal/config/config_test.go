@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -95,6 +97,7 @@ mp3_output_dir_name = "mp3-output"
 func TestLoadConfig_Error(t *testing.T) {
 	// 設定ファイルが存在しない状態でのテスト
 	tempDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
 
 	originalWd, err := os.Getwd()
 	if err != nil {
@@ -115,3 +118,298 @@ func TestLoadConfig_Error(t *testing.T) {
 		t.Error("設定ファイルが存在しない場合にエラーが発生すべき")
 	}
 }
+
+func TestWorkerCount(t *testing.T) {
+	if got := (Setting{Workers: 6}).WorkerCount(); got != 6 {
+		t.Errorf("WorkerCount: got %d, want %d", got, 6)
+	}
+	if got := (Setting{}).WorkerCount(); got < 1 {
+		t.Errorf("未設定時のWorkerCountは1以上であるべき: got %d", got)
+	}
+}
+
+func TestValidate_NegativeWorkers(t *testing.T) {
+	cfg := &Config{Setting: Setting{Workers: -1}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("workersが負の値の場合にエラーが発生すべき")
+	}
+}
+
+func TestFields(t *testing.T) {
+	cfg := &Config{
+		Setting:    Setting{Debug: true, Workers: 4},
+		DirSetting: DirSetting{SourceDir: "./data/source"},
+	}
+
+	got := make(map[string]string)
+	for _, field := range cfg.Fields() {
+		got[field.Key] = field.String()
+	}
+
+	expected := map[string]string{
+		"setting.debug":          "setting.debug = true",
+		"setting.workers":        "setting.workers = 4",
+		"dir_setting.source_dir": `dir_setting.source_dir = "./data/source"`,
+	}
+	for key, want := range expected {
+		if got[key] != want {
+			t.Errorf("%s: got %q, want %q", key, got[key], want)
+		}
+	}
+}
+
+// writeConfigFile はテスト用の設定ファイルを作成してパスを返します。
+func writeConfigFile(t *testing.T, dir, content string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("テスト用ディレクトリの作成に失敗: %v", err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("テスト用設定ファイルの作成に失敗: %v", err)
+	}
+	return path
+}
+
+const profileConfigContent = `
+[setting]
+debug = false
+workers = 2
+exclude_strings = ["SEなし"]
+
+[dir_setting]
+source_dir = "./data/source"
+output_dir = "./data/output"
+
+[profile.nas.dir_setting]
+source_dir = "/mnt/nas/source"
+
+[profile.nas.setting]
+workers = 8
+`
+
+func TestLoad_PathAndProfile(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), profileConfigContent)
+
+	cfg, err := Load(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("設定の読み込みに失敗: %v", err)
+	}
+	if cfg.File != path || cfg.DirSetting.SourceDir != "./data/source" || cfg.Setting.Workers != 2 {
+		t.Errorf("プロファイル未指定時の値が正しくありません: %+v", cfg)
+	}
+
+	cfg, err = Load(LoadOptions{Path: path, Profile: "nas"})
+	if err != nil {
+		t.Fatalf("プロファイル付きの読み込みに失敗: %v", err)
+	}
+	if cfg.DirSetting.SourceDir != "/mnt/nas/source" {
+		t.Errorf("SourceDir: got %v, want %v", cfg.DirSetting.SourceDir, "/mnt/nas/source")
+	}
+	if cfg.Setting.Workers != 8 {
+		t.Errorf("Workers: got %v, want %v", cfg.Setting.Workers, 8)
+	}
+	if cfg.DirSetting.OutputDir != "./data/output" {
+		t.Errorf("プロファイルにない項目は設定ファイルの値を使用すべき: got %v", cfg.DirSetting.OutputDir)
+	}
+	if cfg.Profile != "nas" {
+		t.Errorf("Profile: got %v, want %v", cfg.Profile, "nas")
+	}
+
+	if _, err := Load(LoadOptions{Path: path, Profile: "laptop"}); err == nil {
+		t.Error("存在しないプロファイルを指定した場合はエラーになるべき")
+	}
+}
+
+func TestLoad_EnvOverrides(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), profileConfigContent)
+	t.Setenv(ProfileEnv, "nas")
+	t.Setenv("DLS_ENCODER_SETTING_DEBUG", "true")
+	t.Setenv("DLS_ENCODER_SETTING_WORKERS", "3")
+	t.Setenv("DLS_ENCODER_SETTING_EXCLUDE_STRINGS", "SE無し,効果音なし")
+	t.Setenv("DLS_ENCODER_DIR_SETTING_OUTPUT_DIR", "/tmp/output")
+
+	cfg, err := Load(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("設定の読み込みに失敗: %v", err)
+	}
+	if !cfg.Setting.Debug {
+		t.Error("環境変数で debug が上書きされていません")
+	}
+	if cfg.Setting.Workers != 3 {
+		t.Errorf("環境変数はプロファイルより優先されるべき: got %v, want %v", cfg.Setting.Workers, 3)
+	}
+	if want := []string{"SE無し", "効果音なし"}; !reflect.DeepEqual(cfg.Setting.ExcludeStrings, want) {
+		t.Errorf("ExcludeStrings: got %v, want %v", cfg.Setting.ExcludeStrings, want)
+	}
+	if cfg.DirSetting.OutputDir != "/tmp/output" {
+		t.Errorf("OutputDir: got %v, want %v", cfg.DirSetting.OutputDir, "/tmp/output")
+	}
+	if cfg.DirSetting.SourceDir != "/mnt/nas/source" {
+		t.Errorf("環境変数で指定したプロファイルが適用されていません: got %v", cfg.DirSetting.SourceDir)
+	}
+}
+
+func TestLoad_XDGConfigHome(t *testing.T) {
+	configHome := t.TempDir()
+	writeConfigFile(t, filepath.Join(configHome, "dls-encoder"), profileConfigContent)
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Chdir(t.TempDir())
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("XDG_CONFIG_HOME からの読み込みに失敗: %v", err)
+	}
+	if want := filepath.Join(configHome, "dls-encoder", "config.toml"); cfg.File != want {
+		t.Errorf("File: got %v, want %v", cfg.File, want)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("dir_setting.source_dir"); got != "DLS_ENCODER_DIR_SETTING_SOURCE_DIR" {
+		t.Errorf("EnvName: got %v", got)
+	}
+}
+
+func TestValidate_Progress(t *testing.T) {
+	if got := (Setting{}).ProgressMode(); got != ProgressAuto {
+		t.Errorf("未設定時のProgressMode: got %q, want %q", got, ProgressAuto)
+	}
+	cfg := &Config{Setting: Setting{Progress: "fancy"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("progressが不正な値の場合にエラーが発生すべき")
+	}
+}
+
+func TestValidate_OutputLayout(t *testing.T) {
+	if got := (Setting{}).OutputLayoutMode(); got != OutputLayoutFlat {
+		t.Errorf("未設定時のOutputLayoutMode: got %q, want %q", got, OutputLayoutFlat)
+	}
+	cfg := &Config{Setting: Setting{OutputLayout: "nested"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "output_layout") {
+		t.Errorf("output_layoutが不正な値の場合にエラーが発生すべき: %v", err)
+	}
+}
+
+func TestValidate_Audiobook(t *testing.T) {
+	if got := (Setting{}).AudiobookMode(); got != AudiobookOff {
+		t.Errorf("未設定時のAudiobookMode: got %q, want %q", got, AudiobookOff)
+	}
+	cfg := &Config{Setting: Setting{Audiobook: "aac"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "audiobook") {
+		t.Errorf("audiobookが不正な値の場合にエラーが発生すべき: %v", err)
+	}
+	cfg = &Config{Setting: Setting{Audiobook: AudiobookM4B, Loudness: LoudnessLoudnorm}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "loudness") {
+		t.Errorf("audiobookとloudnessを併用した場合にエラーが発生すべき: %v", err)
+	}
+}
+
+func TestValidate_MP3Passthrough(t *testing.T) {
+	cfg := &Config{Setting: Setting{MP3Passthrough: true, MP3PassthroughMaxBitrate: -1}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mp3_passthrough_max_bitrate") {
+		t.Errorf("mp3_passthrough_max_bitrateが負の値の場合にエラーが発生すべき: %v", err)
+	}
+}
+
+func TestValidate_Loudness(t *testing.T) {
+	s := Setting{}
+	if s.LoudnessMode() != LoudnessOff || s.LoudnessTargetLUFS() != DefaultLoudnessTarget || s.LoudnessTruePeakDB() != DefaultLoudnessTruePeak {
+		t.Errorf("未設定時のラウドネスの設定: got %q, %g, %g", s.LoudnessMode(), s.LoudnessTargetLUFS(), s.LoudnessTruePeakDB())
+	}
+
+	testCases := []struct {
+		name    string
+		setting Setting
+	}{
+		{"不正な調整方法", Setting{Loudness: "normalize"}},
+		{"目標値が大きすぎる", Setting{Loudness: LoudnessLoudnorm, LoudnessTarget: -3}},
+		{"目標値が小さすぎる", Setting{Loudness: LoudnessLoudnorm, LoudnessTarget: -80}},
+		{"ピークが正の値", Setting{Loudness: LoudnessReplayGain, LoudnessTruePeak: 1}},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Setting: tc.setting}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "loudness") {
+			t.Errorf("%s: ラウドネスの設定のエラーが発生すべき: %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidate_Cover(t *testing.T) {
+	valid := Setting{CoverProcessing: true, CoverMaxSize: 1000, CoverQuality: 85, CoverFiles: []string{"cover.jpg", "Folder.JPG"}}
+	if err := valid.validateCover(); err != nil {
+		t.Errorf("正しい設定でエラーが発生しました: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		setting Setting
+	}{
+		{"最大サイズが負の値", Setting{CoverMaxSize: -1}},
+		{"品質が大きすぎる", Setting{CoverQuality: 101}},
+		{"ディレクトリを含む", Setting{CoverFiles: []string{"art/cover.jpg"}}},
+		{"JPEG以外の拡張子", Setting{CoverFiles: []string{"cover.png"}}},
+		{"ドットで始まる", Setting{CoverFiles: []string{".cover.jpg"}}},
+		{"重複", Setting{CoverFiles: []string{"cover.jpg", "COVER.jpg"}}},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Setting: tc.setting}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "cover_") {
+			t.Errorf("%s: 画像の設定のエラーが発生すべき: %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidate_Variant(t *testing.T) {
+	valid := &Config{VariantRules: []VariantRule{{Name: "SE", Labels: []string{"SE有", "SE無"}}, {Labels: []string{"24bit", "16bit"}}}}
+	if err := valid.validateVariants(); err != nil {
+		t.Errorf("正しい設定でエラーが発生しました: %v", err)
+	}
+	if got := valid.VariantRules[1].DisplayName(); got != "24bit" {
+		t.Errorf("名前が未設定の規則は最初のラベルを名前にするべき: got %q", got)
+	}
+
+	testCases := []struct {
+		name  string
+		rules []VariantRule
+	}{
+		{"ラベルが1つだけ", []VariantRule{{Labels: []string{"SE有"}}}},
+		{"空のラベル", []VariantRule{{Labels: []string{"SE有", " "}}}},
+		{"パスの区切りを含む", []VariantRule{{Labels: []string{"SE有/wav", "SE無"}}}},
+		{"重複", []VariantRule{{Labels: []string{"bgm無", "BGM無"}}}},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{VariantRules: tc.rules}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "variant") {
+			t.Errorf("%s: variant のエラーが発生すべき: %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidate_InputFormats(t *testing.T) {
+	if got := (Setting{}).InputFormatList(); !reflect.DeepEqual(got, DefaultInputFormats) {
+		t.Errorf("未設定時のInputFormatList: got %v, want %v", got, DefaultInputFormats)
+	}
+	s := Setting{InputFormats: []string{".FLAC", " mp3"}}
+	if got, want := s.InputFormatList(), []string{"flac", "mp3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("InputFormatList: got %v, want %v", got, want)
+	}
+	if err := s.validateInputFormats(); err != nil {
+		t.Errorf("正しい設定でエラーが発生しました: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		formats []string
+	}{
+		{"対応していない形式", []string{"wav", "wma"}},
+		{"重複", []string{"wav", ".WAV"}},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Setting: Setting{InputFormats: tc.formats}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "input_formats") {
+			t.Errorf("%s: input_formats のエラーが発生すべき: %v", tc.name, err)
+		}
+	}
+}
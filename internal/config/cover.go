@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// validateCover はメイン画像の加工とフォルダ画像の設定を確認します。
+func (s Setting) validateCover() error {
+	if s.CoverMaxSize < 0 {
+		return fmt.Errorf("cover_max_sizeには0以上の値を指定してください: %d", s.CoverMaxSize)
+	}
+	if s.CoverQuality < 0 || s.CoverQuality > 100 {
+		return fmt.Errorf("cover_qualityには1〜100の値を指定してください: %d", s.CoverQuality)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range s.CoverFiles {
+		lower := strings.ToLower(name)
+		switch {
+		case name == "" || name != filepath.Base(name) || strings.HasPrefix(name, "."):
+			return fmt.Errorf("cover_filesにはディレクトリを含まないファイル名を指定してください: %q", name)
+		case filepath.Ext(lower) != ".jpg" && filepath.Ext(lower) != ".jpeg":
+			return fmt.Errorf("cover_filesのファイル名の拡張子は .jpg または .jpeg にしてください: %q", name)
+		case seen[lower]:
+			return fmt.Errorf("cover_filesのファイル名 %q が重複しています", name)
+		}
+		seen[lower] = true
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Field は設定項目のキーと値の組です。
+type Field struct {
+	Key   string      // 設定ファイル上のキー（例: setting.debug）
+	Value interface{} // 設定値
+}
+
+// Fields は setting と dir_setting の全項目を設定ファイル上のキーとともに返します。
+func (c *Config) Fields() []Field {
+	var fields []Field
+	fields = append(fields, structFields("setting", reflect.ValueOf(c.Setting))...)
+	fields = append(fields,
+		Field{Key: "setting.sanitize_rules.any", Value: c.SanitizeRules.Any},
+		Field{Key: "setting.sanitize_rules.end", Value: c.SanitizeRules.End},
+	)
+	fields = append(fields, structFields("dir_setting", reflect.ValueOf(c.DirSetting))...)
+	return fields
+}
+
+// structFields は構造体の各フィールドを mapstructure タグのキーで列挙します。
+func structFields(prefix string, v reflect.Value) []Field {
+	t := v.Type()
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, Field{Key: prefix + "." + name, Value: v.Field(i).Interface()})
+	}
+	return fields
+}
+
+// String は設定項目を「キー = 値」の形式で返します。
+func (f Field) String() string {
+	switch value := f.Value.(type) {
+	case string:
+		return fmt.Sprintf("%s = %q", f.Key, value)
+	case map[string]string:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%q = %q", k, value[k]))
+		}
+		return fmt.Sprintf("%s = {%s}", f.Key, strings.Join(pairs, ", "))
+	default:
+		return fmt.Sprintf("%s = %v", f.Key, value)
+	}
+}
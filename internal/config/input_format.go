@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inputFormats は input_formats に指定できる拡張子と、可逆（非圧縮を含む）の形式かどうかです。
+// m4a は ALAC の場合もありますが、拡張子では区別できないため非可逆として扱います。
+var inputFormats = map[string]bool{
+	"wav":  true,
+	"aiff": true,
+	"aif":  true,
+	"flac": true,
+	"wv":   true,
+	"ape":  true,
+	"dsf":  true,
+	"mp3":  false,
+	"m4a":  false,
+	"ogg":  false,
+	"opus": false,
+}
+
+// DefaultInputFormats は input_formats を指定していない場合に変換元として扱う拡張子です（優先する順）。
+var DefaultInputFormats = []string{"wav", "aiff", "aif", "flac", "wv", "ape", "dsf", "mp3", "m4a", "ogg", "opus"}
+
+// InputFormat は拡張子（"." なし、小文字）が変換元として扱える形式かどうかと、可逆の形式かどうかを返します。
+func InputFormat(name string) (lossless, ok bool) {
+	lossless, ok = inputFormats[name]
+	return lossless, ok
+}
+
+// InputFormatList は変換元として扱う拡張子（"." なし、小文字）を優先する順に返します。
+// input_formats が未設定の場合は DefaultInputFormats です。
+func (s Setting) InputFormatList() []string {
+	if len(s.InputFormats) == 0 {
+		return DefaultInputFormats
+	}
+	formats := make([]string, 0, len(s.InputFormats))
+	for _, format := range s.InputFormats {
+		formats = append(formats, normalizeInputFormat(format))
+	}
+	return formats
+}
+
+// normalizeInputFormat は input_formats の値を "." なしの小文字の拡張子にします。
+func normalizeInputFormat(format string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+}
+
+// validateInputFormats は input_formats の各項目を確認します。
+func (s Setting) validateInputFormats() error {
+	seen := make(map[string]bool)
+	for _, format := range s.InputFormats {
+		name := normalizeInputFormat(format)
+		if _, ok := inputFormats[name]; !ok {
+			return fmt.Errorf("input_formatsに対応していない形式が指定されています: %q", format)
+		}
+		if seen[name] {
+			return fmt.Errorf("input_formatsの形式 %q が重複しています", format)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -1,29 +1,143 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
-// LoadConfig は設定ファイル（config.toml）を読み込み、設定構造体を返します。
+const (
+	// EnvPrefix は設定値を上書きする環境変数の接頭辞です。
+	EnvPrefix = "DLS_ENCODER"
+	// ProfileEnv はプロファイル名を指定する環境変数です。
+	ProfileEnv = EnvPrefix + "_PROFILE"
+
+	configFileName = "config.toml"
+	appDirName     = "dls-encoder"
+)
+
+// LoadOptions は設定ファイルの読み込み方法を指定します。
+type LoadOptions struct {
+	Path    string // 設定ファイルのパス（空の場合は既定の場所を検索）
+	Profile string // 適用するプロファイル名（空の場合は環境変数 DLS_ENCODER_PROFILE）
+}
+
+// LoadConfig は既定の場所から設定ファイル（config.toml）を読み込み、設定構造体を返します。
 func LoadConfig() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("toml")
-	viper.AddConfigPath("./config")
+	return Load(LoadOptions{})
+}
+
+// Load は設定ファイルを読み込み、プロファイルと環境変数による上書きを反映した設定構造体を返します。
+// 優先順位は 環境変数 > プロファイル > 設定ファイル の順です。
+func Load(opts LoadOptions) (*Config, error) {
+	path := opts.Path
+	if path == "" {
+		found, err := findConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
 
-	if err := viper.ReadInConfig(); err != nil {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("設定ファイルの読み込みエラー: %w", err)
 	}
 
+	profile := opts.Profile
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile != "" {
+		if err := applyProfile(v, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range (&Config{}).Fields() {
+		if err := v.BindEnv(field.Key, EnvName(field.Key)); err != nil {
+			return nil, fmt.Errorf("環境変数の設定エラー: %w", err)
+		}
+	}
+
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("設定のパースエラー: %w", err)
 	}
 
 	// SanitizeRules
-	cfg.SanitizeRules.Any = viper.GetStringMapString("setting.sanitize_rules.any")
-	cfg.SanitizeRules.End = viper.GetStringMapString("setting.sanitize_rules.end")
+	cfg.SanitizeRules.Any = v.GetStringMapString("setting.sanitize_rules.any")
+	cfg.SanitizeRules.End = v.GetStringMapString("setting.sanitize_rules.end")
 
+	cfg.File = path
+	cfg.Profile = profile
 	return &cfg, nil
 }
+
+// EnvName は設定キーに対応する環境変数名を返します（例: setting.debug → DLS_ENCODER_SETTING_DEBUG）。
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// SearchPaths は -config 未指定時に設定ファイルを検索するパスを優先順に返します。
+// ./config/config.toml の次に $XDG_CONFIG_HOME/dls-encoder/config.toml
+// （XDG_CONFIG_HOME 未設定時は ~/.config/dls-encoder/config.toml）を検索します。
+func SearchPaths() []string {
+	paths := []string{filepath.Join("config", configFileName)}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, appDirName, configFileName))
+	}
+	return paths
+}
+
+// findConfigFile は検索パスから最初に見つかった設定ファイルのパスを返します。
+func findConfigFile() (string, error) {
+	paths := SearchPaths()
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("設定ファイルの確認に失敗: %w", err)
+		}
+	}
+	return "", fmt.Errorf("設定ファイルが見つかりません（検索パス: %s）", strings.Join(paths, ", "))
+}
+
+// applyProfile は [profile.<name>] セクションの設定値を上書きとして反映します。
+func applyProfile(v *viper.Viper, name string) error {
+	profiles := v.GetStringMap("profile")
+	if _, ok := profiles[strings.ToLower(name)]; !ok {
+		if len(profiles) == 0 {
+			return fmt.Errorf("プロファイル %q が設定ファイルに存在しません（[profile.<名前>] セクションが定義されていません）", name)
+		}
+		available := make([]string, 0, len(profiles))
+		for profile := range profiles {
+			available = append(available, profile)
+		}
+		sort.Strings(available)
+		return fmt.Errorf("プロファイル %q が設定ファイルに存在しません（利用可能: %s）", name, strings.Join(available, ", "))
+	}
+
+	sub := v.Sub("profile." + name)
+	if sub == nil {
+		return nil
+	}
+	if err := v.MergeConfigMap(sub.AllSettings()); err != nil {
+		return fmt.Errorf("プロファイル %q の適用に失敗: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Preset はエンコード設定のプリセットです（[preset.<名前>] セクション）。
+// 指定しなかった項目は出力形式ごとの既定値を使用します。
+type Preset struct {
+	Bitrate    string   `mapstructure:"bitrate"`     // 固定ビットレート（例: "192k"）。quality とは同時に指定できない
+	Quality    *float64 `mapstructure:"quality"`     // 可変ビットレートの品質（ffmpeg の -q:a。MP3 では 0〜9 で小さいほど高品質）
+	SampleRate int      `mapstructure:"sample_rate"` // サンプリングレート（Hz）
+	Channels   int      `mapstructure:"channels"`    // チャンネル数（1: モノラル、2: ステレオ）
+	Resampler  string   `mapstructure:"resampler"`   // リサンプラー（swr / soxr）
+}
+
+// WorkPreset は作品キーごとに使用するプリセットです（[[work_preset]] セクション）。
+type WorkPreset struct {
+	Match  string `mapstructure:"match"`  // 作品キーまたはグロブパターン（例: "RJ01234567", "d_*"）
+	Preset string `mapstructure:"preset"` // プリセット名
+}
+
+// リサンプラー
+const (
+	ResamplerSWR  = "swr"  // ffmpeg 標準のリサンプラー
+	ResamplerSoXR = "soxr" // libsoxr による高品質なリサンプラー
+)
+
+// validate はプリセットの各項目が出力形式によらず正しいかどうかを確認します。
+func (p Preset) validate() error {
+	if p.Bitrate != "" && p.Quality != nil {
+		return fmt.Errorf("bitrate と quality は同時に指定できません")
+	}
+	if p.SampleRate < 0 {
+		return fmt.Errorf("sample_rate には0以上の値を指定してください: %d", p.SampleRate)
+	}
+	if p.Channels < 0 {
+		return fmt.Errorf("channels には0以上の値を指定してください: %d", p.Channels)
+	}
+	switch p.Resampler {
+	case "", ResamplerSWR, ResamplerSoXR:
+	default:
+		return fmt.Errorf("resampler には swr / soxr のいずれかを指定してください: %s", p.Resampler)
+	}
+	return nil
+}
+
+// LookupPreset はプリセット名からプリセットを返します。
+// 設定ファイルのキーは大文字小文字を区別しないため、名前も区別せずに検索します。
+func (c *Config) LookupPreset(name string) (Preset, error) {
+	preset, ok := c.Presets[strings.ToLower(name)]
+	if !ok {
+		if len(c.Presets) == 0 {
+			return Preset{}, fmt.Errorf("プリセット %q が設定ファイルに存在しません（[preset.<名前>] セクションが定義されていません）", name)
+		}
+		return Preset{}, fmt.Errorf("プリセット %q が設定ファイルに存在しません（利用可能: %s）", name, strings.Join(c.PresetNames(), ", "))
+	}
+	return preset, nil
+}
+
+// PresetNames は定義されているプリセット名を名前順で返します。
+func (c *Config) PresetNames() []string {
+	names := make([]string, 0, len(c.Presets))
+	for name := range c.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetFor は作品に使用するプリセット名を返します。プリセットを使用しない場合は空文字列を返します。
+// 優先順位はコマンドラインの指定、[[work_preset]] で最初に一致したもの、setting.preset の順です。
+func (c *Config) PresetFor(key string) string {
+	if c.PresetOverride != "" {
+		return c.PresetOverride
+	}
+	for _, work := range c.WorkPresets {
+		if matched, _ := path.Match(work.Match, key); matched {
+			return work.Preset
+		}
+	}
+	return c.Setting.Preset
+}
+
+// validatePresets はプリセットの定義と参照を確認します。
+func (c *Config) validatePresets() error {
+	for _, name := range c.PresetNames() {
+		if err := c.Presets[name].validate(); err != nil {
+			return fmt.Errorf("プリセット %q: %w", name, err)
+		}
+	}
+
+	refs := []string{c.Setting.Preset, c.PresetOverride}
+	for _, work := range c.WorkPresets {
+		if work.Match == "" || work.Preset == "" {
+			return fmt.Errorf("work_preset には match と preset を指定してください: %+v", work)
+		}
+		if _, err := path.Match(work.Match, ""); err != nil {
+			return fmt.Errorf("work_preset の match %q が正しくありません: %w", work.Match, err)
+		}
+		refs = append(refs, work.Preset)
+	}
+	for _, name := range refs {
+		if name == "" {
+			continue
+		}
+		if _, err := c.LookupPreset(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import "testing"
+
+const presetConfigContent = `
+[setting]
+preset = "archive"
+
+[dir_setting]
+source_dir = "./data/source"
+
+[preset.archive]
+bitrate = "320k"
+
+[preset.V2]
+quality = 2
+sample_rate = 44100
+resampler = "soxr"
+
+[[work_preset]]
+match = "RJ01234567"
+preset = "v2"
+
+[[work_preset]]
+match = "d_*"
+preset = "V2"
+`
+
+func TestLoad_Presets(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), presetConfigContent)
+
+	cfg, err := Load(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("設定の読み込みに失敗: %v", err)
+	}
+	preset, err := cfg.LookupPreset("V2")
+	if err != nil {
+		t.Fatalf("プリセットの取得に失敗: %v", err)
+	}
+	if preset.Quality == nil || *preset.Quality != 2 || preset.SampleRate != 44100 || preset.Resampler != ResamplerSoXR {
+		t.Errorf("プリセットの値が正しくありません: %+v", preset)
+	}
+	if len(cfg.WorkPresets) != 2 {
+		t.Errorf("work_preset の件数: got %d, want 2", len(cfg.WorkPresets))
+	}
+	if err := cfg.validatePresets(); err != nil {
+		t.Errorf("正しいプリセットでエラーが発生しました: %v", err)
+	}
+}
+
+func TestPresetFor(t *testing.T) {
+	cfg := &Config{
+		Setting: Setting{Preset: "archive"},
+		WorkPresets: []WorkPreset{
+			{Match: "RJ01234567", Preset: "v2"},
+			{Match: "RJ*", Preset: "mobile"},
+		},
+	}
+
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{"RJ01234567", "v2"},
+		{"RJ07654321", "mobile"},
+		{"d_123456", "archive"},
+	}
+	for _, tc := range testCases {
+		if got := cfg.PresetFor(tc.key); got != tc.want {
+			t.Errorf("PresetFor(%q): got %q, want %q", tc.key, got, tc.want)
+		}
+	}
+
+	cfg.PresetOverride = "override"
+	if got := cfg.PresetFor("RJ01234567"); got != "override" {
+		t.Errorf("コマンドラインの指定が優先されるべき: got %q", got)
+	}
+}
+
+func TestValidatePresets_Error(t *testing.T) {
+	quality := 2.0
+	testCases := []struct {
+		name string
+		cfg  Config
+	}{
+		{"bitrateとqualityの同時指定", Config{Presets: map[string]Preset{"v2": {Bitrate: "192k", Quality: &quality}}}},
+		{"不正なリサンプラー", Config{Presets: map[string]Preset{"v2": {Resampler: "best"}}}},
+		{"負のサンプリングレート", Config{Presets: map[string]Preset{"v2": {SampleRate: -1}}}},
+		{"存在しないプリセット", Config{Setting: Setting{Preset: "missing"}}},
+		{"work_presetのプリセットなし", Config{WorkPresets: []WorkPreset{{Match: "RJ*"}}}},
+		{"work_presetの不正なパターン", Config{
+			Presets:     map[string]Preset{"v2": {}},
+			WorkPresets: []WorkPreset{{Match: "[RJ", Preset: "v2"}},
+		}},
+	}
+	for _, tc := range testCases {
+		if err := tc.cfg.validatePresets(); err == nil {
+			t.Errorf("%s: エラーが発生すべき", tc.name)
+		}
+	}
+}
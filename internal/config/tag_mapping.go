@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagMapping は作品データから出力ファイルのタグへの対応です（[[tag_mapping]] セクション）。
+type TagMapping struct {
+	Tag       string   `mapstructure:"tag"`       // タグ名（ffmpeg の -metadata のキー。ID3v2 で対応するフレームがない名前は TXXX になる）
+	Source    []string `mapstructure:"source"`    // 値を取得する追加情報のキー（最初に値のあるものを使用。"@key" は作品キー）
+	Convert   string   `mapstructure:"convert"`   // 値の変換（"": そのまま / date: YYYY-MM-DD / year: YYYY）
+	Multiple  bool     `mapstructure:"multiple"`  // ", " または "、" 区切りの値を複数の値として扱うかどうか
+	Separator string   `mapstructure:"separator"` // 複数の値を書き込む際の区切り（未設定の場合は "; "）
+}
+
+// 値の変換
+const (
+	TagConvertDate = "date" // 日付を YYYY-MM-DD にする（"2023年05月12日 0時" など）
+	TagConvertYear = "year" // 日付から年（YYYY）を取り出す
+)
+
+// TagSourceKey は作品キー（RJ01234567、d_123456 など）を表す tag_mapping の source です。
+const TagSourceKey = "@key"
+
+// DefaultTagSeparator は複数の値を書き込む際の既定の区切りです。
+const DefaultTagSeparator = "; "
+
+// DefaultTagMappings は [[tag_mapping]] を定義していない場合に使用する対応です。
+var DefaultTagMappings = []TagMapping{
+	{Tag: "genre", Source: []string{"ジャンル"}, Multiple: true},
+	{Tag: "date", Source: []string{"販売日", "配信開始日"}, Convert: TagConvertDate},
+	{Tag: "composer", Source: []string{"シナリオ"}},
+	{Tag: "lyricist", Source: []string{"シナリオ"}},
+	{Tag: "comment", Source: []string{TagSourceKey}},
+	{Tag: "CATALOGNUMBER", Source: []string{TagSourceKey}},
+}
+
+// reservedTags は作品データの基本項目やトラック番号、ReplayGain で設定するため、tag_mapping では指定できないタグ名です。
+var reservedTags = []string{
+	"artist", "album_artist", "album", "title", "track", "disc",
+	"replaygain_track_gain", "replaygain_track_peak", "replaygain_album_gain", "replaygain_album_peak",
+}
+
+// TagRules は出力ファイルに書き込む追加のタグの対応を返します。
+// extra_tags が false の場合は nil、[[tag_mapping]] を定義していない場合は DefaultTagMappings を返します。
+func (c *Config) TagRules() []TagMapping {
+	if !c.Setting.ExtraTags {
+		return nil
+	}
+	if len(c.TagMappings) == 0 {
+		return DefaultTagMappings
+	}
+	return c.TagMappings
+}
+
+// SeparatorOrDefault は複数の値を書き込む際の区切りを返します。
+func (m TagMapping) SeparatorOrDefault() string {
+	if m.Separator == "" {
+		return DefaultTagSeparator
+	}
+	return m.Separator
+}
+
+// validateTagMappings は [[tag_mapping]] の各項目を確認します。
+func (c *Config) validateTagMappings() error {
+	seen := make(map[string]bool)
+	for _, mapping := range c.TagMappings {
+		name := strings.ToLower(mapping.Tag)
+		switch {
+		case mapping.Tag == "":
+			return fmt.Errorf("tag_mapping には tag を指定してください: %+v", mapping)
+		case strings.ContainsAny(mapping.Tag, "= "):
+			return fmt.Errorf("tag_mapping の tag には空白や = を含められません: %q", mapping.Tag)
+		case len(mapping.Source) == 0:
+			return fmt.Errorf("tag_mapping %q には source を指定してください", mapping.Tag)
+		case seen[name]:
+			return fmt.Errorf("tag_mapping の tag %q が重複しています", mapping.Tag)
+		}
+		for _, reserved := range reservedTags {
+			if name == reserved {
+				return fmt.Errorf("tag_mapping の tag %q は作品データの基本項目などで設定するため指定できません", mapping.Tag)
+			}
+		}
+		switch mapping.Convert {
+		case "", TagConvertDate, TagConvertYear:
+		default:
+			return fmt.Errorf("tag_mapping %q の convert には date / year のいずれかを指定してください: %s", mapping.Tag, mapping.Convert)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad_TagMappings(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), `
+[setting]
+extra_tags = true
+
+[[tag_mapping]]
+tag = "genre"
+source = "ジャンル"
+multiple = true
+separator = "/"
+
+[[tag_mapping]]
+tag = "date"
+source = ["販売日", "配信開始日"]
+convert = "date"
+`)
+
+	cfg, err := Load(LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("設定の読み込みに失敗: %v", err)
+	}
+	want := []TagMapping{
+		{Tag: "genre", Source: []string{"ジャンル"}, Multiple: true, Separator: "/"},
+		{Tag: "date", Source: []string{"販売日", "配信開始日"}, Convert: TagConvertDate},
+	}
+	if got := cfg.TagRules(); !reflect.DeepEqual(got, want) {
+		t.Errorf("tag_mapping の値:\ngot  %+v\nwant %+v", got, want)
+	}
+	if err := cfg.validateTagMappings(); err != nil {
+		t.Errorf("正しい tag_mapping でエラーが発生しました: %v", err)
+	}
+}
+
+func TestValidate_TagMappings(t *testing.T) {
+	cfg := &Config{}
+	if rules := cfg.TagRules(); rules != nil {
+		t.Errorf("extra_tags が false の場合は対応なし: %v", rules)
+	}
+	cfg.Setting.ExtraTags = true
+	if rules := cfg.TagRules(); !reflect.DeepEqual(rules, DefaultTagMappings) {
+		t.Errorf("tag_mapping 未定義の場合は既定の対応: %v", rules)
+	}
+
+	testCases := []struct {
+		name    string
+		mapping []TagMapping
+	}{
+		{"tagなし", []TagMapping{{Source: []string{"ジャンル"}}}},
+		{"sourceなし", []TagMapping{{Tag: "genre"}}},
+		{"基本項目のタグ", []TagMapping{{Tag: "Title", Source: []string{"シナリオ"}}}},
+		{"不正な変換", []TagMapping{{Tag: "date", Source: []string{"販売日"}, Convert: "unix"}}},
+		{"重複", []TagMapping{{Tag: "genre", Source: []string{"ジャンル"}}, {Tag: "GENRE", Source: []string{"作品形式"}}}},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{TagMappings: tc.mapping}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "tag_mapping") {
+			t.Errorf("%s: tag_mapping のエラーが発生すべき: %v", tc.name, err)
+		}
+	}
+}
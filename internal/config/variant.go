@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VariantRule は同じトラックを別の版（SE有/SE無、16bit/24bit など）で収録した作品から、変換する版を選ぶ規則です（[[variant]] セクション）。
+type VariantRule struct {
+	Name   string   `mapstructure:"name"`   // 規則の名前（ログ・レポート用、未設定の場合は最初のラベル）
+	Labels []string `mapstructure:"labels"` // 版を表す語（ディレクトリ名・ファイル名に含まれる文字列、優先する順）
+}
+
+// DisplayName は規則の名前を返します。未設定の場合は最初のラベルです。
+func (r VariantRule) DisplayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if len(r.Labels) > 0 {
+		return r.Labels[0]
+	}
+	return ""
+}
+
+// validateVariants は [[variant]] の各項目を確認します。
+func (c *Config) validateVariants() error {
+	for _, rule := range c.VariantRules {
+		if len(rule.Labels) < 2 {
+			return fmt.Errorf("variant %q の labels には2つ以上の語を指定してください: %v", rule.DisplayName(), rule.Labels)
+		}
+		seen := make(map[string]bool)
+		for _, label := range rule.Labels {
+			lower := strings.ToLower(label)
+			switch {
+			case strings.TrimSpace(label) == "":
+				return fmt.Errorf("variant %q の labels に空の語があります", rule.DisplayName())
+			case strings.ContainsAny(label, `/\`):
+				return fmt.Errorf("variant %q の labels にはパスの区切りを含められません: %q", rule.DisplayName(), label)
+			case seen[lower]:
+				return fmt.Errorf("variant %q の labels の %q が重複しています", rule.DisplayName(), label)
+			}
+			seen[lower] = true
+		}
+	}
+	return nil
+}
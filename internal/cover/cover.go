@@ -0,0 +1,118 @@
+// Package cover はメイン画像を埋め込み用・フォルダ画像用の JPEG に加工します。
+// 大きすぎる画像を埋め込むと表示できないプレーヤー（カーオーディオなど）があるため、
+// 必要に応じて正方形への切り抜きと縮小を行い、ベースライン JPEG で保存します。
+package cover
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // PNG のメイン画像を読み込むため
+	"os"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // WebP のメイン画像を読み込むため
+)
+
+// DefaultQuality は JPEG の品質の既定値です。
+const DefaultQuality = 90
+
+// Options は画像の加工方法です。
+type Options struct {
+	MaxSize int  // 長辺の最大ピクセル数（0 の場合は縮小しない）
+	Square  bool // 中央を正方形に切り抜くかどうか
+	Quality int  // JPEG の品質（1〜100、0 の場合は DefaultQuality）
+}
+
+// quality は JPEG の品質を返します。
+func (o Options) quality() int {
+	if o.Quality == 0 {
+		return DefaultQuality
+	}
+	return o.Quality
+}
+
+// Signature は加工方法を識別する文字列を返します。
+// 加工方法が変わった場合に再エンコードが必要かどうかの判定に使用します。
+func (o Options) Signature() string {
+	return fmt.Sprintf("jpeg: max_size=%d square=%t quality=%d", o.MaxSize, o.Square, o.quality())
+}
+
+// Process は画像ファイル（JPEG・PNG・WebP）を読み込み、加工した JPEG のデータを返します。
+func Process(path string, opts Options) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("画像ファイルのオープンに失敗: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("画像の読み込みに失敗 %s: %w", path, err)
+	}
+	return Encode(img, opts)
+}
+
+// Encode は画像を加工して JPEG にエンコードします。Go の image/jpeg はベースライン JPEG のみを出力します。
+// JPEG は透過を扱えないため、透過のある画像（PNG・WebP）は白い背景に重ねてからエンコードします。
+func Encode(img image.Image, opts Options) ([]byte, error) {
+	if opts.Square {
+		img = cropSquare(img)
+	}
+	if opts.MaxSize > 0 {
+		img = shrink(img, opts.MaxSize)
+	}
+	img = flatten(img)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.quality()}); err != nil {
+		return nil, fmt.Errorf("JPEG のエンコードに失敗: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// flatten は透過のある画像を白い背景に重ねた画像を返します。不透明な画像はそのまま返します。
+// そのまま JPEG にエンコードすると透過部分が黒くなるためです。
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// cropSquare は画像の中央を短辺の長さの正方形に切り抜きます。
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	size := min(b.Dx(), b.Dy())
+	if b.Dx() == b.Dy() {
+		return img
+	}
+	x := b.Min.X + (b.Dx()-size)/2
+	y := b.Min.Y + (b.Dy()-size)/2
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), img, image.Point{X: x, Y: y}, draw.Src)
+	return dst
+}
+
+// shrink は長辺が maxSize を超える画像を、縦横比を保って縮小します。
+func shrink(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= maxSize && b.Dy() <= maxSize {
+		return img
+	}
+	width, height := maxSize, maxSize
+	if b.Dx() > b.Dy() {
+		height = max(1, b.Dy()*maxSize/b.Dx())
+	} else {
+		width = max(1, b.Dx()*maxSize/b.Dy())
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,114 @@
+package cover
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePNG は指定した大きさの PNG ファイルを作成します。
+func writePNG(t *testing.T, width, height int) string {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "main.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("画像ファイルの作成に失敗: %v", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("PNG のエンコードに失敗: %v", err)
+	}
+	return path
+}
+
+func TestProcess(t *testing.T) {
+	t.Parallel()
+
+	path := writePNG(t, 400, 200)
+	testCases := []struct {
+		name          string
+		opts          Options
+		width, height int
+	}{
+		{"変換のみ", Options{}, 400, 200},
+		{"縮小", Options{MaxSize: 100}, 100, 50},
+		{"正方形", Options{Square: true}, 200, 200},
+		{"正方形と縮小", Options{Square: true, MaxSize: 100, Quality: 80}, 100, 100},
+		{"拡大はしない", Options{MaxSize: 1000}, 400, 200},
+	}
+
+	for _, tc := range testCases {
+		data, err := Process(path, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: Process に失敗: %v", tc.name, err)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: JPEG として読み込めません: %v", tc.name, err)
+		}
+		if config.Width != tc.width || config.Height != tc.height {
+			t.Errorf("%s: 大きさ got %dx%d, want %dx%d", tc.name, config.Width, config.Height, tc.width, tc.height)
+		}
+	}
+}
+
+func TestEncodeTransparent(t *testing.T) {
+	t.Parallel()
+
+	// 左半分は透明、右半分は不透明な赤
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 32; x < 64; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	data, err := Encode(img, Options{Quality: 100})
+	if err != nil {
+		t.Fatalf("Encode に失敗: %v", err)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("JPEG として読み込めません: %v", err)
+	}
+	if r, g, b, _ := decoded.At(8, 16).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("透明な部分は白い背景にすべき: got (%d, %d, %d)", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := decoded.At(56, 16).RGBA(); r>>8 < 240 || g>>8 > 15 || b>>8 > 15 {
+		t.Errorf("不透明な部分は元の色を保つべき: got (%d, %d, %d)", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestProcessInvalidImage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "broken.webp")
+	if err := os.WriteFile(path, []byte("not an image"), 0644); err != nil {
+		t.Fatalf("ファイルの作成に失敗: %v", err)
+	}
+	if _, err := Process(path, Options{}); err == nil {
+		t.Error("画像として読み込めないファイルはエラーにするべき")
+	}
+}
+
+func TestOptionsSignature(t *testing.T) {
+	t.Parallel()
+
+	if (Options{}).Signature() != (Options{Quality: DefaultQuality}).Signature() {
+		t.Error("品質の未設定は既定値と同じ加工方法として扱うべき")
+	}
+	if (Options{MaxSize: 500}).Signature() == (Options{MaxSize: 1000}).Signature() {
+		t.Error("最大サイズが異なる場合は別の加工方法として扱うべき")
+	}
+}
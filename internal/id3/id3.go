@@ -0,0 +1,455 @@
+// Package id3 は MP3 ファイルの ID3v2 タグを、音声データを変更せずに書き換えます。
+// ffmpeg で変換した MP3（ID3v2.3）のタグを、再エンコードせずに更新するために使用します。
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrInvalidTag は ID3v2 タグのヘッダーが壊れていることを表します。
+var ErrInvalidTag = errors.New("ID3v2 タグを解析できません")
+
+// headerSize は ID3v2 タグのヘッダー（およびフッター）の長さです。
+const headerSize = 10
+
+// テキストの文字コード
+const (
+	encodingLatin1 = 0x00 // ISO-8859-1
+	encodingUTF16  = 0x01 // BOM 付きの UTF-16
+)
+
+// PictureTypeFrontCover は APIC フレームの画像の種類「表紙」です。
+const PictureTypeFrontCover = 0x03
+
+// Field はタグ名と値の組です。タグ名は ffmpeg の -metadata と同じ名前を使用します。
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Picture は埋め込む画像です。
+type Picture struct {
+	MIME        string // MIME タイプ（image/jpeg など）
+	Type        byte   // 画像の種類（PictureTypeFrontCover など）
+	Description string // 説明
+	Data        []byte // 画像データ
+}
+
+// Tag は書き込むタグの内容です。
+type Tag struct {
+	Fields  []Field  // テキストのタグ（ffmpeg の -metadata と同じ順序・名前）
+	Picture *Picture // 埋め込む画像（nil の場合は画像なし）
+}
+
+// Frame は ID3v2 タグの1フレームです。
+type Frame struct {
+	ID   string // フレームID（TIT2、TXXX など）
+	Data []byte // フレームの内容
+}
+
+// textFrames はタグ名と ID3v2.3 のテキストフレームの対応です。変換時に ffmpeg が書き込むフレームと揃えるため、
+// ffmpeg が ID3v2.3 で使用する対応（libavformat の ff_id3v2_34_metadata_conv）と同じにしています。
+// ここにないタグ名は、フレームIDそのもの（frameIDs）を除いて TXXX（ユーザー定義テキスト）として書き込みます。
+var textFrames = map[string]string{
+	"album":        "TALB",
+	"composer":     "TCOM",
+	"genre":        "TCON",
+	"copyright":    "TCOP",
+	"encoded_by":   "TENC",
+	"title":        "TIT2",
+	"language":     "TLAN",
+	"artist":       "TPE1",
+	"album_artist": "TPE2",
+	"performer":    "TPE3",
+	"disc":         "TPOS",
+	"publisher":    "TPUB",
+	"track":        "TRCK",
+	"encoder":      "TSSE",
+}
+
+// frameIDs はタグ名に指定した場合に、ffmpeg がそのフレームとして書き込む ID3v2.3 のテキストフレームIDです（大文字小文字を区別します）。
+// 作詞者（TEXT）などの ffmpeg に対応するタグ名がないフレームは、フレームIDをタグ名にして書き込みます。
+var frameIDs = map[string]bool{
+	"TALB": true, "TBPM": true, "TCOM": true, "TCON": true, "TCOP": true, "TDLY": true, "TENC": true, "TEXT": true,
+	"TFLT": true, "TIT1": true, "TIT2": true, "TIT3": true, "TKEY": true, "TLAN": true, "TLEN": true, "TMED": true,
+	"TOAL": true, "TOFN": true, "TOLY": true, "TOPE": true, "TOWN": true, "TPE1": true, "TPE2": true, "TPE3": true,
+	"TPE4": true, "TPOS": true, "TPUB": true, "TRCK": true, "TRSN": true, "TRSO": true, "TSRC": true, "TSSE": true,
+	"TDAT": true, "TIME": true, "TORY": true, "TRDA": true, "TSIZ": true, "TYER": true,
+}
+
+// keepFrameIDs は書き換え時に既存のタグから引き継ぐテキストフレームです。
+// ffmpeg が書き込むエンコーダー名（TSSE）と再生時間（TLEN）はタグの内容から作成しないため、そのまま残します。
+var keepFrameIDs = map[string]bool{"TSSE": true, "TLEN": true}
+
+// Frames はタグの内容を ID3v2.3 のフレームに変換します。
+// date は YYYY-MM-DD の場合に TYER（年）と TDAT（日月）に、comment は COMM フレームにします。
+func (t Tag) Frames() []Frame {
+	var frames []Frame
+	for _, field := range t.Fields {
+		name := strings.ToLower(field.Name)
+		switch {
+		case name == "date":
+			frames = append(frames, dateFrames(field.Value)...)
+		case name == "comment":
+			frames = append(frames, Frame{ID: "COMM", Data: commentFrame(field.Value)})
+		case textFrames[name] != "":
+			frames = append(frames, Frame{ID: textFrames[name], Data: encodeStrings(field.Value)})
+		case frameIDs[field.Name]:
+			frames = append(frames, Frame{ID: field.Name, Data: encodeStrings(field.Value)})
+		default:
+			frames = append(frames, Frame{ID: "TXXX", Data: encodeStrings(field.Name, field.Value)})
+		}
+	}
+	if t.Picture != nil {
+		frames = append(frames, Frame{ID: "APIC", Data: pictureFrame(*t.Picture)})
+	}
+	return frames
+}
+
+// dateFrames は日付を ID3v2.3 の TYER・TDAT フレームに変換します。
+// YYYY-MM-DD・YYYY のいずれでもない値は TYER にそのまま書き込みます。
+func dateFrames(value string) []Frame {
+	if len(value) == len("2006-01-02") && value[4] == '-' && value[7] == '-' {
+		return []Frame{
+			{ID: "TYER", Data: encodeStrings(value[:4])},
+			{ID: "TDAT", Data: encodeStrings(value[8:10] + value[5:7])},
+		}
+	}
+	return []Frame{{ID: "TYER", Data: encodeStrings(value)}}
+}
+
+// commentFrame は説明なし・言語不明の COMM フレームの内容を返します。
+func commentFrame(value string) []byte {
+	encoding, text := encodeText(value)
+	data := append([]byte{encoding}, "XXX"...)
+	data = append(data, terminator(encoding)...)
+	return append(data, text...)
+}
+
+// pictureFrame は APIC フレームの内容を返します。
+func pictureFrame(picture Picture) []byte {
+	encoding, description := encodeText(picture.Description)
+	data := []byte{encoding}
+	data = append(data, picture.MIME...)
+	data = append(data, 0, picture.Type)
+	data = append(data, description...)
+	data = append(data, terminator(encoding)...)
+	return append(data, picture.Data...)
+}
+
+// encodeStrings は文字列を1つの文字コードで、各文字列の後に終端を付けてフレームの内容にします。
+// ffmpeg と同様に、すべて ASCII の場合は ISO-8859-1、それ以外は BOM 付きの UTF-16 を使用します。
+func encodeStrings(values ...string) []byte {
+	encoding := byte(encodingLatin1)
+	for _, value := range values {
+		if !isASCII(value) {
+			encoding = encodingUTF16
+		}
+	}
+	data := []byte{encoding}
+	for _, value := range values {
+		data = append(data, encodeAs(encoding, value)...)
+		data = append(data, terminator(encoding)...)
+	}
+	return data
+}
+
+// encodeText は1つの文字列を文字コードとともに返します（終端は含みません）。
+func encodeText(value string) (byte, []byte) {
+	if isASCII(value) {
+		return encodingLatin1, []byte(value)
+	}
+	return encodingUTF16, encodeAs(encodingUTF16, value)
+}
+
+func encodeAs(encoding byte, value string) []byte {
+	if encoding == encodingLatin1 {
+		return []byte(value)
+	}
+	data := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(value)) {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return data
+}
+
+func terminator(encoding byte) []byte {
+	if encoding == encodingLatin1 {
+		return []byte{0}
+	}
+	return []byte{0, 0}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// Strings はテキストフレームの内容を文字列に戻します。
+// TXXX は説明と値の2つ、COMM は説明と本文の2つ（言語は含みません）を返します。
+func (f Frame) Strings() []string {
+	if len(f.Data) == 0 {
+		return nil
+	}
+	encoding, data := f.Data[0], f.Data[1:]
+	if f.ID == "COMM" {
+		if len(data) < 3 {
+			return nil
+		}
+		data = data[3:]
+	}
+
+	var values []string
+	for len(data) > 0 {
+		end, next := len(data), len(data)
+		if encoding == encodingLatin1 || encoding == 0x03 {
+			if i := bytes.IndexByte(data, 0); i >= 0 {
+				end, next = i, i+1
+			}
+		} else {
+			for i := 0; i+1 < len(data); i += 2 {
+				if data[i] == 0 && data[i+1] == 0 {
+					end, next = i, i+2
+					break
+				}
+			}
+		}
+		values = append(values, decodeText(encoding, data[:end]))
+		data = data[next:]
+	}
+	return values
+}
+
+// decodeText は文字コードに従ってテキストを文字列に変換します。
+func decodeText(encoding byte, data []byte) string {
+	switch encoding {
+	case encodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case encodingUTF16, 0x02:
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+			order, data = binary.LittleEndian, data[2:]
+		} else if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+			data = data[2:]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		return string(data)
+	}
+}
+
+// header は ID3v2 タグのヘッダーです。
+type header struct {
+	major byte
+	flags byte
+	size  int // ヘッダー（とフッター）を含むタグ全体の長さ
+}
+
+// readHeader は r の先頭の ID3v2 タグのヘッダーを読み取ります。タグがない場合は nil を返します。
+func readHeader(r io.ReaderAt) (*header, error) {
+	buf := make([]byte, headerSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if string(buf[:3]) != "ID3" {
+		return nil, nil
+	}
+	size, ok := syncsafe(buf[6:10])
+	if !ok || buf[3] < 2 || buf[3] > 4 {
+		return nil, ErrInvalidTag
+	}
+	h := &header{major: buf[3], flags: buf[5], size: headerSize + size}
+	if h.major == 4 && h.flags&0x10 != 0 {
+		h.size += headerSize // フッター
+	}
+	return h, nil
+}
+
+// TagSize は r の先頭の ID3v2 タグ（ヘッダーとフッターを含む）のバイト数を返します。タグがない場合は0です。
+func TagSize(r io.ReaderAt) (int64, error) {
+	h, err := readHeader(r)
+	if err != nil || h == nil {
+		return 0, err
+	}
+	return int64(h.size), nil
+}
+
+// syncsafe は7ビットずつの整数（syncsafe integer）を読み取ります。
+func syncsafe(b []byte) (int, bool) {
+	n := 0
+	for _, c := range b {
+		if c&0x80 != 0 {
+			return 0, false
+		}
+		n = n<<7 | int(c)
+	}
+	return n, true
+}
+
+func putSyncsafe(b []byte, n int) {
+	for i := 3; i >= 0; i-- {
+		b[i] = byte(n & 0x7F)
+		n >>= 7
+	}
+}
+
+// ReadFrames は MP3 ファイルの先頭の ID3v2.3 タグのフレームを読み取ります。
+// タグがない場合は nil を返します。ID3v2.3 以外のタグ、非同期化・拡張ヘッダーのあるタグのフレームは読み取りません。
+func ReadFrames(path string) ([]Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ファイルのオープンに失敗: %w", err)
+	}
+	defer file.Close()
+
+	h, err := readHeader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if h == nil {
+		return nil, nil
+	}
+	return readFrames(file, h)
+}
+
+// readFrames はタグのフレームを読み取ります。
+func readFrames(r io.ReaderAt, h *header) ([]Frame, error) {
+	if h.major != 3 || h.flags&0xC0 != 0 {
+		return nil, nil
+	}
+	body := make([]byte, h.size-headerSize)
+	if _, err := r.ReadAt(body, headerSize); err != nil {
+		return nil, fmt.Errorf("タグの読み込みに失敗: %w", err)
+	}
+
+	var frames []Frame
+	for len(body) >= headerSize && body[0] != 0 {
+		id := string(body[:4])
+		size := int(binary.BigEndian.Uint32(body[4:8]))
+		if size > len(body)-headerSize {
+			return nil, ErrInvalidTag
+		}
+		frames = append(frames, Frame{ID: id, Data: body[headerSize : headerSize+size]})
+		body = body[headerSize+size:]
+	}
+	return frames, nil
+}
+
+// replaced は書き換え時に新しいタグの内容で置き換える（引き継がない）フレームかどうかを返します。
+func replaced(id string) bool {
+	switch {
+	case keepFrameIDs[id]:
+		return false
+	case strings.HasPrefix(id, "T"), id == "COMM", id == "APIC":
+		return true
+	}
+	return false
+}
+
+// WriteFile は MP3 ファイルの ID3v2 タグを、tag の内容の ID3v2.3 タグで置き換えます。
+// 音声データ（タグより後の部分）はそのままコピーします。既存のタグのうち、テキスト・コメント・画像以外のフレームと
+// エンコーダー名は引き継ぎます。書き込みは同じディレクトリの一時ファイルに行い、完了後にリネームします。
+func WriteFile(path string, tag Tag) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ファイルのオープンに失敗: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("ファイル情報の取得に失敗: %w", err)
+	}
+
+	h, err := readHeader(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	offset := 0
+	var frames []Frame
+	if h != nil {
+		offset = h.size
+		existing, err := readFrames(src, h)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, frame := range existing {
+			if !replaced(frame.ID) {
+				frames = append(frames, frame)
+			}
+		}
+	}
+	frames = append(tag.Frames(), frames...)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("一時ファイルの作成に失敗: %w", err)
+	}
+	tmpPath := tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := tmp.Write(encodeTag(frames)); err != nil {
+		return fail(fmt.Errorf("タグの書き込みに失敗: %w", err))
+	}
+	if _, err := io.Copy(tmp, io.NewSectionReader(src, int64(offset), info.Size()-int64(offset))); err != nil {
+		return fail(fmt.Errorf("音声データのコピーに失敗: %w", err))
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return fail(fmt.Errorf("パーミッションの設定に失敗: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("一時ファイルの書き込みに失敗: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ファイルの置き換えに失敗: %w", err)
+	}
+	return nil
+}
+
+// encodeTag はフレームから ID3v2.3 タグ全体（ヘッダーを含む）を作成します。
+func encodeTag(frames []Frame) []byte {
+	size := 0
+	for _, frame := range frames {
+		size += headerSize + len(frame.Data)
+	}
+
+	data := make([]byte, headerSize, headerSize+size)
+	copy(data, "ID3")
+	data[3], data[4], data[5] = 3, 0, 0
+	putSyncsafe(data[6:10], size)
+	for _, frame := range frames {
+		data = append(data, frame.ID...)
+		data = binary.BigEndian.AppendUint32(data, uint32(len(frame.Data)))
+		data = append(data, 0, 0)
+		data = append(data, frame.Data...)
+	}
+	return data
+}
@@ -0,0 +1,169 @@
+package id3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestFile は ID3v2.3 タグと音声データを持つファイルを作成します。
+func writeTestFile(t *testing.T, frames []Frame, audio []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	content := append(encodeTag(frames), audio...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("テスト用ファイルの作成に失敗: %v", err)
+	}
+	return path
+}
+
+// frameStrings はフレームIDごとにフレームの文字列をまとめます。
+func frameStrings(frames []Frame) map[string][][]string {
+	result := make(map[string][][]string)
+	for _, frame := range frames {
+		if frame.ID == "APIC" {
+			continue
+		}
+		result[frame.ID] = append(result[frame.ID], frame.Strings())
+	}
+	return result
+}
+
+func TestTagFrames(t *testing.T) {
+	t.Parallel()
+
+	tag := Tag{Fields: []Field{
+		{Name: "artist", Value: "テスト声優"},
+		{Name: "album", Value: "Test Album"},
+		{Name: "track", Value: "1/2"},
+		{Name: "date", Value: "2023-05-12"},
+		{Name: "comment", Value: "RJ01234567"},
+		{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-1.50 dB"},
+	}}
+	got := frameStrings(tag.Frames())
+	want := map[string][][]string{
+		"TPE1": {{"テスト声優"}},
+		"TALB": {{"Test Album"}},
+		"TRCK": {{"1/2"}},
+		"TYER": {{"2023"}},
+		"TDAT": {{"1205"}},
+		"COMM": {{"", "RJ01234567"}},
+		"TXXX": {{"REPLAYGAIN_TRACK_GAIN", "-1.50 dB"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Frames() = %v, want %v", got, want)
+	}
+}
+
+// TestTagFramesMatchFFmpeg は、変換時に ffmpeg が ID3v2.3 で書き込むフレームと同じフレームを書き込むことを確認します。
+func TestTagFramesMatchFFmpeg(t *testing.T) {
+	t.Parallel()
+
+	// ffmpeg（-id3v2_version 3）がタグ名ごとに書き込むフレーム
+	tests := []struct {
+		name string
+		id   string
+		data []string
+	}{
+		{"composer", "TCOM", []string{"テスト作家"}},
+		{"genre", "TCON", []string{"テスト作家"}},
+		{"lyricist", "TXXX", []string{"lyricist", "テスト作家"}}, // ffmpeg に対応がないため TXXX になる
+		{"grouping", "TXXX", []string{"grouping", "テスト作家"}}, // TIT1 への対応は ID3v2.4 のみ
+		{"TEXT", "TEXT", []string{"テスト作家"}},                 // フレームIDはそのフレームになる
+		{"text", "TXXX", []string{"text", "テスト作家"}},
+		{"CATALOGNUMBER", "TXXX", []string{"CATALOGNUMBER", "テスト作家"}},
+	}
+	for _, tt := range tests {
+		frames := Tag{Fields: []Field{{Name: tt.name, Value: "テスト作家"}}}.Frames()
+		if len(frames) != 1 || frames[0].ID != tt.id || !reflect.DeepEqual(frames[0].Strings(), tt.data) {
+			t.Errorf("%s のフレーム: got %v, want %s %v", tt.name, frameStrings(frames), tt.id, tt.data)
+		}
+	}
+}
+
+func TestWriteFileKeepsAudio(t *testing.T) {
+	t.Parallel()
+
+	audio := []byte("\xff\xfbaudio-frames")
+	path := writeTestFile(t, []Frame{
+		{ID: "TSSE", Data: encodeStrings("Lavf61.7.100")},
+		{ID: "TIT2", Data: encodeStrings("旧タイトル")},
+		{ID: "TXXX", Data: encodeStrings("OLD", "value")},
+		{ID: "PRIV", Data: []byte("owner\x00data")},
+	}, audio)
+
+	picture := &Picture{MIME: "image/jpeg", Type: PictureTypeFrontCover, Description: "Album cover", Data: []byte{0xff, 0xd8, 0xff, 0xd9}}
+	if err := WriteFile(path, Tag{Fields: []Field{{Name: "title", Value: "新タイトル"}}, Picture: picture}); err != nil {
+		t.Fatalf("WriteFile に失敗: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ファイルの読み込みに失敗: %v", err)
+	}
+	if !bytes.HasSuffix(content, audio) {
+		t.Error("音声データが変更されています")
+	}
+
+	frames, err := ReadFrames(path)
+	if err != nil {
+		t.Fatalf("ReadFrames に失敗: %v", err)
+	}
+	var ids []string
+	for _, frame := range frames {
+		ids = append(ids, frame.ID)
+	}
+	// タイトルと画像は置き換え、エンコーダー名とテキスト以外のフレームは引き継ぐ
+	if want := []string{"TIT2", "APIC", "TSSE", "PRIV"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("フレーム: got %v, want %v", ids, want)
+	}
+	if got := frames[0].Strings(); !reflect.DeepEqual(got, []string{"新タイトル"}) {
+		t.Errorf("TIT2: got %v", got)
+	}
+	if !bytes.HasSuffix(frames[1].Data, picture.Data) {
+		t.Error("APIC に画像データが含まれていません")
+	}
+}
+
+func TestWriteFileWithoutTag(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plain.mp3")
+	audio := []byte("\xff\xfbplain")
+	if err := os.WriteFile(path, audio, 0600); err != nil {
+		t.Fatalf("テスト用ファイルの作成に失敗: %v", err)
+	}
+
+	if err := WriteFile(path, Tag{Fields: []Field{{Name: "album", Value: "A"}}}); err != nil {
+		t.Fatalf("WriteFile に失敗: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ファイルの読み込みに失敗: %v", err)
+	}
+	if !bytes.HasPrefix(content, []byte("ID3\x03")) || !bytes.HasSuffix(content, audio) {
+		t.Errorf("タグを先頭に追加し、音声データを残すべき: %q", content)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("ファイル情報の取得に失敗: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("パーミッションを引き継ぐべき: got %v", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileInvalidHeader(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "broken.mp3")
+	if err := os.WriteFile(path, []byte("ID3\x03\x00\x00\xff\xff\xff\xffaudio"), 0644); err != nil {
+		t.Fatalf("テスト用ファイルの作成に失敗: %v", err)
+	}
+	if err := WriteFile(path, Tag{}); err == nil {
+		t.Error("壊れたタグのファイルはエラーにするべき")
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -89,6 +90,24 @@ func (l *StandardLogger) SetFileOutput(logFile *os.File) {
 	l.fileLogger = log.New(logFile, "", log.LstdFlags|log.Lshortfile)
 }
 
+// SetConsoleOutput はコンソール出力先を設定
+func (l *StandardLogger) SetConsoleOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.consoleLogger = log.New(w, "", log.LstdFlags|log.Lshortfile)
+}
+
+// SetConsoleOutput はデフォルトロガーのコンソール出力先を w に変更し、標準出力に戻す関数を返します。
+// 進捗表示など、コンソールへの出力に割り込む必要がある場合に使用します。
+func SetConsoleOutput(w io.Writer) (restore func()) {
+	sl, ok := getDefaultLogger().(*StandardLogger)
+	if !ok {
+		return func() {}
+	}
+	sl.SetConsoleOutput(w)
+	return func() { sl.SetConsoleOutput(os.Stdout) }
+}
+
 // Debug はデバッグレベルのログを出力
 func (l *StandardLogger) Debug(args ...interface{}) {
 	l.mu.RLock()
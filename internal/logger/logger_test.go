@@ -247,3 +247,21 @@ func TestSimpleMessageLogging(t *testing.T) {
 		})
 	}
 }
+
+func TestSetConsoleOutput(t *testing.T) {
+	oldLogger := defaultLogger
+	defaultLogger = NewStandardLogger(INFO, false)
+	defer func() { defaultLogger = oldLogger }()
+
+	var buf bytes.Buffer
+	restore := SetConsoleOutput(&buf)
+	LogMessage("progress test")
+	restore()
+
+	if !strings.Contains(buf.String(), "progress test") {
+		t.Errorf("変更した出力先にログが出力されていません: %q", buf.String())
+	}
+	if sl := defaultLogger.(*StandardLogger); sl.consoleLogger.Writer() != os.Stdout {
+		t.Error("restore で出力先が標準出力に戻っていません")
+	}
+}
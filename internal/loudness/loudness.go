@@ -0,0 +1,214 @@
+// Package loudness は ffmpeg の loudnorm フィルタによる EBU R128 ラウドネスの測定と、
+// 測定結果を使用した2パスのラウドネス正規化・ReplayGain の計算を扱います。
+package loudness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+)
+
+// ErrMeasureFailed は ffmpeg によるラウドネスの測定が失敗したことを表します。
+var ErrMeasureFailed = errors.New("ラウドネスの測定に失敗しました")
+
+// silence は無音のファイルを測定した場合に使用するラウドネス・ピークの値です（EBU R128 の絶対ゲートと同じ -70）。
+const silence = -70.0
+
+// ReplayGain のタグ名
+const (
+	TagTrackGain = "REPLAYGAIN_TRACK_GAIN"
+	TagTrackPeak = "REPLAYGAIN_TRACK_PEAK"
+	TagAlbumGain = "REPLAYGAIN_ALBUM_GAIN"
+	TagAlbumPeak = "REPLAYGAIN_ALBUM_PEAK"
+)
+
+// Target は正規化の目標値です。
+type Target struct {
+	Integrated float64 `json:"integrated"` // 統合ラウドネス（LUFS）
+	TruePeak   float64 `json:"true_peak"`  // トゥルーピークの上限（dBTP）
+}
+
+// Measurement は1ファイル分のラウドネスの測定結果です。
+type Measurement struct {
+	Integrated float64 `json:"integrated"` // 統合ラウドネス（LUFS）
+	TruePeak   float64 `json:"true_peak"`  // トゥルーピーク（dBTP）
+	LRA        float64 `json:"lra"`        // ラウドネスレンジ（LU）
+	Threshold  float64 `json:"threshold"`  // 相対ゲートの閾値（LUFS）
+	Offset     float64 `json:"offset"`     // 2パス目に指定するゲインの補正値（dB）
+	Duration   float64 `json:"duration"`   // 再生時間（秒、不明な場合は0）。アルバム全体のラウドネスの重み付けに使用する
+	Target     Target  `json:"target"`     // 測定時に指定した目標値（Offset は目標値によって変わる）
+}
+
+// Tag は ReplayGain のタグ名と値の組です。
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Measure は ffmpeg の loudnorm フィルタの1パス目を実行し、音声ファイルのラウドネスを測定します。
+func Measure(ctx context.Context, path string, target Target) (Measurement, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-map", "0:a:0",
+		"-af", fmt.Sprintf("loudnorm=I=%s:TP=%s:print_format=json", formatFloat(target.Integrated), formatFloat(target.TruePeak)),
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return Measurement{}, fmt.Errorf("ラウドネスの測定がキャンセルされました: %w", ctx.Err())
+		}
+		return Measurement{}, fmt.Errorf("%w %s: %w", ErrMeasureFailed, path, err)
+	}
+
+	m, err := parseMeasurement(stderr.Bytes())
+	if err != nil {
+		return Measurement{}, fmt.Errorf("%w %s: %w", ErrMeasureFailed, path, err)
+	}
+	m.Target = target
+	return m, nil
+}
+
+// loudnormOutput は loudnorm フィルタが print_format=json で出力する値です。数値も文字列で出力されます。
+type loudnormOutput struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// parseMeasurement は ffmpeg の標準エラー出力の末尾にある loudnorm の JSON を解析します。
+func parseMeasurement(stderr []byte) (Measurement, error) {
+	start := bytes.LastIndexByte(stderr, '{')
+	end := bytes.LastIndexByte(stderr, '}')
+	if start < 0 || end < start {
+		return Measurement{}, fmt.Errorf("loudnorm の測定結果が出力されていません")
+	}
+
+	var out loudnormOutput
+	if err := json.Unmarshal(stderr[start:end+1], &out); err != nil {
+		return Measurement{}, fmt.Errorf("loudnorm の測定結果の解析に失敗: %w", err)
+	}
+
+	var m Measurement
+	values := []struct {
+		name  string
+		value string
+		dst   *float64
+	}{
+		{"input_i", out.InputI, &m.Integrated},
+		{"input_tp", out.InputTP, &m.TruePeak},
+		{"input_lra", out.InputLRA, &m.LRA},
+		{"input_thresh", out.InputThresh, &m.Threshold},
+		{"target_offset", out.TargetOffset, &m.Offset},
+	}
+	for _, v := range values {
+		f, err := strconv.ParseFloat(v.value, 64)
+		if err != nil {
+			return Measurement{}, fmt.Errorf("loudnorm の %s が数値ではありません: %q", v.name, v.value)
+		}
+		*v.dst = f
+	}
+
+	// 無音のファイルは -inf になり、JSON に保存できないため下限の値に置き換える
+	for _, dst := range []*float64{&m.Integrated, &m.TruePeak, &m.Threshold} {
+		if math.IsInf(*dst, 0) || math.IsNaN(*dst) || *dst < silence {
+			*dst = silence
+		}
+	}
+	if math.IsInf(m.LRA, 0) || math.IsNaN(m.LRA) {
+		m.LRA = 0
+	}
+	if math.IsInf(m.Offset, 0) || math.IsNaN(m.Offset) {
+		m.Offset = 0
+	}
+	return m, nil
+}
+
+// Filter は loudnorm フィルタの指定を返します。
+// m を指定した場合は測定結果を使用した2パス目の線形な正規化、nil の場合は1パスでの正規化になります。
+func (t Target) Filter(m *Measurement) string {
+	filter := fmt.Sprintf("loudnorm=I=%s:TP=%s", formatFloat(t.Integrated), formatFloat(t.TruePeak))
+	if m == nil {
+		return filter
+	}
+	// 目標のラウドネスレンジが変換元より狭いと動的な圧縮に切り替わるため、変換元のレンジ以上を指定する
+	// （ffmpeg 4.2 の上限は 20）
+	lra := math.Min(20, math.Max(7, math.Ceil(m.LRA)))
+	return filter + fmt.Sprintf(":LRA=%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		formatFloat(lra), formatFloat(m.Integrated), formatFloat(m.TruePeak), formatFloat(m.LRA), formatFloat(m.Threshold), formatFloat(m.Offset))
+}
+
+// Album は複数のファイルの測定結果からアルバム全体のラウドネスとピークを求めます。
+// 統合ラウドネスは再生時間で重み付けしたエネルギーの平均です（再生時間が不明なファイルがある場合は均等に扱います）。
+func Album(measurements []Measurement) Measurement {
+	if len(measurements) == 0 {
+		return Measurement{Integrated: silence, TruePeak: silence}
+	}
+
+	weighted := true
+	for _, m := range measurements {
+		if m.Duration <= 0 {
+			weighted = false
+		}
+	}
+
+	album := Measurement{TruePeak: silence}
+	var energy, total float64
+	for _, m := range measurements {
+		weight := 1.0
+		if weighted {
+			weight = m.Duration
+		}
+		energy += weight * math.Pow(10, m.Integrated/10)
+		total += weight
+		album.TruePeak = math.Max(album.TruePeak, m.TruePeak)
+		album.Duration += m.Duration
+		album.Target = m.Target
+	}
+	album.Integrated = 10 * math.Log10(energy/total)
+	return album
+}
+
+// Gain は測定結果を目標のラウドネスに合わせるためのゲイン（dB）を返します。
+func (t Target) Gain(m Measurement) float64 {
+	return t.Integrated - m.Integrated
+}
+
+// ReplayGainReference は ReplayGain 2.0 が定める基準のラウドネス（LUFS）です。
+const ReplayGainReference = -18.0
+
+// ReplayGain はトラックとアルバムの測定結果から ReplayGain のタグを返します。
+// ゲインは loudnorm の目標値によらず ReplayGainReference を基準に求め、ピークはトゥルーピークを線形の値で記録します。
+func ReplayGain(track, album Measurement) []Tag {
+	reference := Target{Integrated: ReplayGainReference}
+	return []Tag{
+		{Name: TagTrackGain, Value: formatGain(reference.Gain(track))},
+		{Name: TagTrackPeak, Value: formatPeak(track.TruePeak)},
+		{Name: TagAlbumGain, Value: formatGain(reference.Gain(album))},
+		{Name: TagAlbumPeak, Value: formatPeak(album.TruePeak)},
+	}
+}
+
+// formatGain はゲインを ReplayGain の形式（例: "-6.48 dB"）で表します。
+func formatGain(db float64) string {
+	return strconv.FormatFloat(db, 'f', 2, 64) + " dB"
+}
+
+// formatPeak は dBTP のピークを ReplayGain の形式（1.0 をフルスケールとした線形の値）で表します。
+func formatPeak(db float64) string {
+	return strconv.FormatFloat(math.Pow(10, db/20), 'f', 6, 64)
+}
+
+// formatFloat は ffmpeg のフィルタに渡す数値を表します。
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,104 @@
+package loudness
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMeasurement(t *testing.T) {
+	stderr := []byte(`Input #0, wav, from 'in.wav':
+  Duration: 00:01:00.00, bitrate: 1536 kb/s
+[Parsed_loudnorm_0 @ 0x55d0c6a0] 
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+`)
+	got, err := parseMeasurement(stderr)
+	if err != nil {
+		t.Fatalf("parseMeasurement でエラー: %v", err)
+	}
+	want := Measurement{Integrated: -27.61, TruePeak: -4.47, LRA: 18.06, Threshold: -39.2, Offset: 0.58}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMeasurement:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// 無音のファイルは -inf になるため下限の値に置き換える
+	got, err = parseMeasurement([]byte(`{"input_i" : "-inf", "input_tp" : "-inf", "input_lra" : "0.00", "input_thresh" : "-inf", "target_offset" : "inf"}`))
+	if err != nil {
+		t.Fatalf("無音のファイルの測定結果でエラー: %v", err)
+	}
+	if got.Integrated != silence || got.TruePeak != silence || got.Threshold != silence || got.Offset != 0 {
+		t.Errorf("無音のファイルの測定結果: got %+v", got)
+	}
+
+	for _, out := range []string{"", "Error opening input", `{"input_i" : "N/A"}`} {
+		if _, err := parseMeasurement([]byte(out)); err == nil {
+			t.Errorf("不正な出力 %q でエラーが発生すべき", out)
+		}
+	}
+}
+
+func TestTargetFilter(t *testing.T) {
+	target := Target{Integrated: -18, TruePeak: -1.5}
+	if got, want := target.Filter(nil), "loudnorm=I=-18:TP=-1.5"; got != want {
+		t.Errorf("1パスの指定: got %q, want %q", got, want)
+	}
+
+	m := &Measurement{Integrated: -27.61, TruePeak: -4.47, LRA: 18.06, Threshold: -39.2, Offset: 0.58}
+	want := "loudnorm=I=-18:TP=-1.5:LRA=19:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.2:offset=0.58:linear=true"
+	if got := target.Filter(m); got != want {
+		t.Errorf("2パス目の指定:\ngot  %q\nwant %q", got, want)
+	}
+
+	// ラウドネスレンジは 7〜20 の範囲に収める
+	m.LRA = 3
+	if got := target.Filter(m); !strings.Contains(got, ":LRA=7:") {
+		t.Errorf("狭いラウドネスレンジは7にすべき: %q", got)
+	}
+	m.LRA = 35
+	if got := target.Filter(m); !strings.Contains(got, ":LRA=20:") {
+		t.Errorf("広いラウドネスレンジは20にすべき: %q", got)
+	}
+}
+
+func TestAlbum(t *testing.T) {
+	// 再生時間で重み付けしたエネルギーの平均
+	album := Album([]Measurement{
+		{Integrated: -20, TruePeak: -3, Duration: 300},
+		{Integrated: -30, TruePeak: -1, Duration: 100},
+	})
+	want := 10 * math.Log10((300*math.Pow(10, -2)+100*math.Pow(10, -3))/400)
+	if math.Abs(album.Integrated-want) > 1e-9 || album.TruePeak != -1 || album.Duration != 400 {
+		t.Errorf("Album: got %+v, want integrated %v", album, want)
+	}
+
+	// 再生時間が不明なファイルがある場合は均等に扱う
+	album = Album([]Measurement{{Integrated: -20}, {Integrated: -20, Duration: 60}})
+	if math.Abs(album.Integrated+20) > 1e-9 {
+		t.Errorf("同じラウドネスのアルバム: got %v, want -20", album.Integrated)
+	}
+}
+
+func TestReplayGain(t *testing.T) {
+	tags := ReplayGain(Measurement{Integrated: -12.5, TruePeak: 0}, Measurement{Integrated: -23, TruePeak: -6})
+	want := []Tag{
+		{Name: TagTrackGain, Value: "-5.50 dB"},
+		{Name: TagTrackPeak, Value: "1.000000"},
+		{Name: TagAlbumGain, Value: "5.00 dB"},
+		{Name: TagAlbumPeak, Value: "0.501187"},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("ReplayGain:\ngot  %v\nwant %v", tags, want)
+	}
+}
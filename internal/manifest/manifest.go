@@ -0,0 +1,214 @@
+// Package manifest は出力アルバムごとの変換記録（マニフェスト）を扱います。
+// マニフェストには変換元ファイルの情報、設定したメタデータ、エンコード設定を記録し、
+// 再実行時に変更のないトラックの再エンコードを省略するために利用します。
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/loudness"
+)
+
+// FileName は出力アルバムのディレクトリに保存するマニフェストのファイル名です。
+const FileName = ".dls-encoder.json"
+
+// currentVersion はマニフェストの形式のバージョンです。
+const currentVersion = 1
+
+// Source は変換元ファイルの識別情報です。
+type Source struct {
+	Path    string    `json:"path"`             // ファイルの絶対パス
+	Size    int64     `json:"size"`             // ファイルサイズ
+	ModTime time.Time `json:"mod_time"`         // 最終更新日時
+	SHA256  string    `json:"sha256,omitempty"` // 内容のハッシュ（incremental_checksum 有効時のみ）
+}
+
+// Track は1トラック分の変換記録です。
+type Track struct {
+	Output       string            `json:"output"`                  // アルバムディレクトリからの出力ファイルの相対パス（区切りは "/"）
+	Source       Source            `json:"source"`                  // 変換元ファイル
+	Cover        *Source           `json:"cover,omitempty"`         // 埋め込んだ画像ファイル（画像なしの場合はnil）
+	CoverOptions string            `json:"cover_options,omitempty"` // 埋め込む前に画像を加工した方法（cover_processing が無効な場合は空）
+	Metadata     map[string]string `json:"metadata"`                // 設定したメタデータ
+	Encoder      string            `json:"encoder"`                 // エンコード設定
+
+	// Chapters は1ファイルにまとめたオーディオブックのチャプターです（audiobook が有効な場合のみ）。
+	// 変換元ファイルはチャプターごとに記録し、Source は使用しません。
+	Chapters []Chapter `json:"chapters,omitempty"`
+
+	// Loudness は変換元のラウドネスの測定結果です（loudness が有効な場合のみ）。
+	// 変換結果の比較には使用せず、変換元が変わっていない場合に測定を省略するために記録します。
+	Loudness *loudness.Measurement `json:"loudness,omitempty"`
+}
+
+// Chapter はオーディオブックの1チャプター分の変換記録です。
+type Chapter struct {
+	Title  string `json:"title"`  // チャプター名
+	Source Source `json:"source"` // 変換元ファイル
+}
+
+// Manifest は1アルバム分の変換記録です。
+type Manifest struct {
+	Version   int       `json:"version"`
+	Key       string    `json:"key"`        // 作品キー
+	UpdatedAt time.Time `json:"updated_at"` // 最終更新日時
+	Tracks    []Track   `json:"tracks"`
+
+	// CoverFiles はアルバムのディレクトリに保存したフォルダ画像のファイル名です。
+	CoverFiles []string `json:"cover_files,omitempty"`
+}
+
+// New は作品キーとトラック記録から新しいマニフェストを作成します。
+func New(key string, tracks []Track) *Manifest {
+	return &Manifest{
+		Version:   currentVersion,
+		Key:       key,
+		UpdatedAt: time.Now(),
+		Tracks:    tracks,
+	}
+}
+
+// Fingerprint は指定されたファイルの識別情報を取得します。
+// withChecksum が true の場合はファイル内容のSHA-256も計算します。
+func Fingerprint(path string, withChecksum bool) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Source{}, fmt.Errorf("ファイル情報の取得に失敗: %w", err)
+	}
+
+	source := Source{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC().Truncate(time.Second),
+	}
+	if withChecksum {
+		sum, err := checksum(path)
+		if err != nil {
+			return Source{}, err
+		}
+		source.SHA256 = sum
+	}
+	return source, nil
+}
+
+// checksum はファイル内容のSHA-256を16進文字列で返します。
+func checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("ファイルのオープンに失敗: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("ハッシュの計算に失敗: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Equal は2つのトラック記録が同じ変換結果になるかどうかを返します。
+// ハッシュはどちらにも記録されている場合のみ比較します。
+func (t Track) Equal(other Track) bool {
+	if t.Output != other.Output || t.Encoder != other.Encoder || t.CoverOptions != other.CoverOptions {
+		return false
+	}
+	if !t.SameSource(other) {
+		return false
+	}
+	if (t.Cover == nil) != (other.Cover == nil) {
+		return false
+	}
+	if t.Cover != nil && !t.Cover.Equal(*other.Cover) {
+		return false
+	}
+	if len(t.Metadata) == 0 && len(other.Metadata) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(t.Metadata, other.Metadata)
+}
+
+// SameSource は2つのトラック記録の変換元（オーディオブックの場合はチャプター名を含む）が同じかどうかを返します。
+func (t Track) SameSource(other Track) bool {
+	if !t.Source.Equal(other.Source) || len(t.Chapters) != len(other.Chapters) {
+		return false
+	}
+	for i, chapter := range t.Chapters {
+		if chapter.Title != other.Chapters[i].Title || !chapter.Source.Equal(other.Chapters[i].Source) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal は2つの識別情報が同じファイル内容を指しているかどうかを返します。
+func (s Source) Equal(other Source) bool {
+	if s.Path != other.Path || s.Size != other.Size {
+		return false
+	}
+	if s.SHA256 != "" && other.SHA256 != "" {
+		return s.SHA256 == other.SHA256
+	}
+	return s.ModTime.Equal(other.ModTime)
+}
+
+// Find は出力ファイル名に対応するトラック記録を返します。
+func (m *Manifest) Find(output string) (Track, bool) {
+	if m == nil {
+		return Track{}, false
+	}
+	for _, track := range m.Tracks {
+		if track.Output == output {
+			return track, true
+		}
+	}
+	return Track{}, false
+}
+
+// Load は指定されたアルバムディレクトリのマニフェストを読み込みます。
+// マニフェストが存在しない場合は nil を返します。
+func Load(dir string) (*Manifest, error) {
+	content, err := os.ReadFile(filepath.Join(dir, FileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("マニフェストの読み込みに失敗: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("マニフェストの解析に失敗: %w", err)
+	}
+	if m.Version != currentVersion {
+		// 形式が異なる記録は信用できないため、存在しないものとして扱う
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// Save はマニフェストを指定されたアルバムディレクトリに保存します。
+// 書き込み途中で中断されても壊れたマニフェストが残らないよう、一時ファイルからリネームします。
+func (m *Manifest) Save(dir string) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("マニフェストのエンコードに失敗: %w", err)
+	}
+
+	tmpPath := filepath.Join(dir, FileName+".tmp")
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return fmt.Errorf("マニフェストの書き込みに失敗: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, FileName)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("マニフェストの保存に失敗: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "track1.wav")
+	if err := os.WriteFile(path, []byte("dummy audio data"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	source, err := Fingerprint(path, false)
+	if err != nil {
+		t.Fatalf("Fingerprintの実行に失敗: %v", err)
+	}
+	if source.Size != int64(len("dummy audio data")) {
+		t.Errorf("Size: got %d, want %d", source.Size, len("dummy audio data"))
+	}
+	if source.SHA256 != "" {
+		t.Error("チェックサム無効時はSHA256を計算すべきではありません")
+	}
+
+	withSum, err := Fingerprint(path, true)
+	if err != nil {
+		t.Fatalf("チェックサム付きFingerprintの実行に失敗: %v", err)
+	}
+	if len(withSum.SHA256) != 64 {
+		t.Errorf("SHA256の形式が不正です: %q", withSum.SHA256)
+	}
+}
+
+func TestTrackEqual(t *testing.T) {
+	base := Track{
+		Output:   "track1.mp3",
+		Source:   Source{Path: "/src/track1.wav", Size: 10, ModTime: time.Unix(100, 0)},
+		Metadata: map[string]string{"title": "track1"},
+		Encoder:  "-c:a libmp3lame",
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(t *Track)
+		want   bool
+	}{
+		{"identical", func(t *Track) {}, true},
+		{"sizeChanged", func(t *Track) { t.Source.Size = 11 }, false},
+		{"modTimeChanged", func(t *Track) { t.Source.ModTime = time.Unix(200, 0) }, false},
+		{"metadataChanged", func(t *Track) { t.Metadata = map[string]string{"title": "new"} }, false},
+		{"encoderChanged", func(t *Track) { t.Encoder = "-c:a flac" }, false},
+		{"coverAdded", func(t *Track) { t.Cover = &Source{Path: "/img/cover.jpg"} }, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			other := base
+			other.Metadata = map[string]string{"title": "track1"}
+			tc.modify(&other)
+			if got := base.Equal(other); got != tc.want {
+				t.Errorf("Equal: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	withSum := base
+	withSum.Source.SHA256 = "abc"
+	touched := withSum
+	touched.Source.ModTime = time.Unix(300, 0)
+	if !withSum.Equal(touched) {
+		t.Error("ハッシュが一致する場合は更新日時が異なっても同一とみなすべき")
+	}
+
+	book := Track{Output: "作品.m4b", Chapters: []Chapter{
+		{Title: "プロローグ", Source: Source{Path: "/src/01.wav", Size: 10, ModTime: time.Unix(100, 0)}},
+		{Title: "本編", Source: Source{Path: "/src/02.wav", Size: 20, ModTime: time.Unix(100, 0)}},
+	}}
+	same := book
+	same.Chapters = []Chapter{book.Chapters[0], book.Chapters[1]}
+	if !book.Equal(same) {
+		t.Error("チャプターが同じ場合は同一とみなすべき")
+	}
+	renamed := same
+	renamed.Chapters = []Chapter{book.Chapters[0], {Title: "エピローグ", Source: book.Chapters[1].Source}}
+	if book.Equal(renamed) || book.SameSource(renamed) {
+		t.Error("チャプター名が変わった場合は変換元が異なるとみなすべき")
+	}
+	if book.SameSource(Track{Output: book.Output, Chapters: book.Chapters[:1]}) {
+		t.Error("チャプター数が変わった場合は変換元が異なるとみなすべき")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("マニフェストが存在しない場合のLoadでエラー: %v", err)
+	}
+	if loaded != nil {
+		t.Fatal("マニフェストが存在しない場合はnilを返すべき")
+	}
+
+	m := New("RJ12345678", []Track{
+		{Output: "track1.mp3", Source: Source{Path: "/src/track1.wav", Size: 10}, Encoder: "enc"},
+	})
+	if err := m.Save(dir); err != nil {
+		t.Fatalf("マニフェストの保存に失敗: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName+".tmp")); !os.IsNotExist(err) {
+		t.Error("一時ファイルが残っています")
+	}
+
+	loaded, err = Load(dir)
+	if err != nil {
+		t.Fatalf("マニフェストの読み込みに失敗: %v", err)
+	}
+	if loaded.Key != "RJ12345678" {
+		t.Errorf("Key: got %q, want %q", loaded.Key, "RJ12345678")
+	}
+	if _, ok := loaded.Find("track1.mp3"); !ok {
+		t.Error("保存したトラック記録が見つかりません")
+	}
+	if _, ok := loaded.Find("track2.mp3"); ok {
+		t.Error("存在しないトラック記録が見つかりました")
+	}
+}
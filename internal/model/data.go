@@ -14,4 +14,50 @@ type IndividualData struct {
 	MainImage  string            `json:"main_image"`  // メイン画像のパス
 	TrackList  []Track           `json:"track_list"`  // トラック一覧
 	Additional map[string]string `json:"additional"`  // 追加情報
+
+	Sources      []AudioSource `json:"sources,omitempty"`       // 変換元の音声ファイルを ffprobe で調べた結果
+	TrackMatches []TrackMatch  `json:"track_matches,omitempty"` // 変換元の音声ファイルとトラック一覧の対応
+	Warnings     []string      `json:"warnings,omitempty"`      // 変換元の音声ファイルについての注意（バイノーラル作品のモノラル音源、トラック一覧との不一致など）
+
+	Selection *AudioSelection `json:"-"` // 変換対象として選択した音声ファイル（作品ごとに1回だけ選択し、調査・対応付け・変換計画で共有する）
+}
+
+// AudioSelection は作品ディレクトリから変換対象の音声ファイルを選択した結果です。
+type AudioSelection struct {
+	Files   []string      // 変換対象の音声ファイル
+	Skipped []SkippedFile // 変換対象から外した音声ファイル
+}
+
+// SkippedFile は変換対象から外した音声ファイルです。
+type SkippedFile struct {
+	Path          string `json:"path"`                     // ファイルのパス
+	Reason        string `json:"reason"`                   // 除外理由（excluded / lower_priority / variant）
+	ExcludeString string `json:"exclude_string,omitempty"` // 一致した除外文字列
+	PreferredPath string `json:"preferred_path,omitempty"` // 代わりに選択したファイル
+
+	Variant          string `json:"variant,omitempty"`           // ファイルの版のラベル
+	PreferredVariant string `json:"preferred_variant,omitempty"` // 代わりに選択した版のラベル
+}
+
+// TrackMatch は変換元の音声ファイルに対応付けたトラック一覧のトラックです。
+type TrackMatch struct {
+	File   string `json:"file"`   // 作品ディレクトリからの相対パス
+	Track  int    `json:"track"`  // トラック一覧での番号（1始まり）
+	Title  string `json:"title"`  // トラックタイトル
+	Method string `json:"method"` // 対応付けの方法（order: 並び順 / duration: 再生時間 / title: タイトルの類似度）
+}
+
+// AudioSource は変換元の音声ファイルを ffprobe で調べた結果です。
+type AudioSource struct {
+	File          string            `json:"file"`                      // 作品ディレクトリからの相対パス
+	Codec         string            `json:"codec,omitempty"`           // コーデック名（pcm_s16le, flac, mp3 など）
+	SampleRate    int               `json:"sample_rate,omitempty"`     // サンプリングレート（Hz）
+	BitDepth      int               `json:"bit_depth,omitempty"`       // 量子化ビット数（MP3 など非可逆圧縮の場合は 0）
+	Channels      int               `json:"channels,omitempty"`        // チャンネル数
+	ChannelLayout string            `json:"channel_layout,omitempty"`  // チャンネルレイアウト（mono, stereo など）
+	Duration      float64           `json:"duration,omitempty"`        // 再生時間（秒）
+	BitRate       int               `json:"bit_rate,omitempty"`        // ファイル全体のビットレート（bps、タグや画像を含む）
+	StreamBitRate int               `json:"stream_bit_rate,omitempty"` // 音声ストリームのビットレート（bps）
+	Tags          map[string]string `json:"tags,omitempty"`            // 変換元に設定されているタグ（キーは小文字）
+	Error         string            `json:"error,omitempty"`           // ffprobe で調べられなかった場合の理由
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kkryama/dls-encoder/internal/config"
@@ -45,16 +46,18 @@ func ExtractData(targetHtmlFilePath, dirName string, cfg *config.Config) (model.
 		case "サークル名":
 			data.Brand = value
 		case "トラックリスト":
-			// トラック情報を抽出するための正規表現
-			re := regexp.MustCompile(`([^\s]+) \((\d+):(\d+)\)`)
+			// トラック情報を抽出するための正規表現（「タイトル (分:秒)」または「タイトル (時:分:秒)」を ", " 区切りで並べたもの）
+			// タイトルには空白や括弧を含むことがあるため、時間の括弧の直前までをタイトルとします
+			re := regexp.MustCompile(`(.+?) \((?:(\d+):)?(\d+):(\d+)\)(?:, |$)`)
 			matches := re.FindAllStringSubmatch(value, -1)
 
 			tracks := []model.Track{}
 			for _, match := range matches {
-				title := match[1]
-				minutes, _ := strconv.Atoi(match[2])
-				seconds, _ := strconv.Atoi(match[3])
-				duration := time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+				title := strings.TrimSpace(match[1])
+				hours, _ := strconv.Atoi(match[2])
+				minutes, _ := strconv.Atoi(match[3])
+				seconds, _ := strconv.Atoi(match[4])
+				duration := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
 
 				tracks = append(tracks, model.Track{
 					TrackTitle:    title,
@@ -371,3 +371,36 @@ func TestParseD(t *testing.T) {
 		t.Errorf("メイン画像: got %q, want %q", data["メイン画像"], "https://example.com/main.jpg")
 	}
 }
+
+func TestExtractDataTrackTitlesWithSpaces(t *testing.T) {
+	htmlFilePath := t.TempDir() + "/test.html"
+	htmlContent := `<html><body>
+	<h1 id="work_name">テストアルバム</h1>
+	<div class="work_parts type_tracklist">
+		<ul class="work_tracklist">
+			<li class="work_tracklist_item"><div class="title">01 プロローグ (前編)</div><div class="time">05:30</div></li>
+			<li class="work_tracklist_item"><div class="title">添い寝, 耳かき</div><div class="time">1:02:03</div></li>
+		</ul>
+	</div>
+</body></html>`
+	if err := os.WriteFile(htmlFilePath, []byte(htmlContent), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	result, err := ExtractData(htmlFilePath, "test", &config.Config{})
+	if err != nil {
+		t.Fatalf("ExtractDataの実行に失敗: %v", err)
+	}
+	want := []struct{ title, duration string }{
+		{"01 プロローグ (前編)", "5分30秒"},
+		{"添い寝, 耳かき", "62分3秒"},
+	}
+	if len(result.TrackList) != len(want) {
+		t.Fatalf("TrackList length: got %d, want %d (%+v)", len(result.TrackList), len(want), result.TrackList)
+	}
+	for i, w := range want {
+		if got := result.TrackList[i]; got.TrackTitle != w.title || got.TrackDuration != w.duration {
+			t.Errorf("TrackList[%d]: got %q (%s), want %q (%s)", i, got.TrackTitle, got.TrackDuration, w.title, w.duration)
+		}
+	}
+}
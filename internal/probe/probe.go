@@ -0,0 +1,132 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// Available は ffprobe が PATH 上に存在するかどうかを返します。
+func Available() bool {
+	_, err := exec.LookPath("ffprobe")
+	return err == nil
+}
+
+// Duration は ffprobe を使用して音声ファイルの再生時間を取得します。
+func Duration(ctx context.Context, path string) (time.Duration, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe の実行に失敗 (%s): %w", path, err)
+	}
+	return parseDuration(string(out))
+}
+
+// parseDuration は ffprobe が出力する秒数を変換します。
+func parseDuration(out string) (time.Duration, error) {
+	value := strings.TrimSpace(out)
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("再生時間を取得できません: %q", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Inspect は ffprobe を使用して音声ファイルのコーデック、サンプリングレート、量子化ビット数、チャンネル数、再生時間とタグを取得します。
+// 返り値の File は空のため、呼び出し側で設定してください。
+func Inspect(ctx context.Context, path string) (model.AudioSource, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "format=duration,bit_rate:format_tags:stream=codec_name,sample_rate,bits_per_raw_sample,bits_per_sample,channels,channel_layout,bit_rate:stream_tags",
+		"-of", "json",
+		path,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return model.AudioSource{}, fmt.Errorf("ffprobe の実行に失敗 (%s): %w", path, err)
+	}
+	return parseInspect(out)
+}
+
+// ffprobeOutput は ffprobe の JSON 出力のうち使用する項目です。数値も文字列で出力されます。
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecName        string            `json:"codec_name"`
+		SampleRate       string            `json:"sample_rate"`
+		BitsPerRawSample string            `json:"bits_per_raw_sample"`
+		BitsPerSample    int               `json:"bits_per_sample"`
+		Channels         int               `json:"channels"`
+		ChannelLayout    string            `json:"channel_layout"`
+		BitRate          string            `json:"bit_rate"`
+		Tags             map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// parseInspect は ffprobe の JSON 出力を変換します。
+func parseInspect(out []byte) (model.AudioSource, error) {
+	var parsed ffprobeOutput
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return model.AudioSource{}, fmt.Errorf("ffprobe の出力の解析に失敗: %w", err)
+	}
+	if len(parsed.Streams) == 0 {
+		return model.AudioSource{}, fmt.Errorf("音声ストリームが見つかりません")
+	}
+
+	stream := parsed.Streams[0]
+	source := model.AudioSource{
+		Codec:         stream.CodecName,
+		SampleRate:    atoi(stream.SampleRate),
+		BitDepth:      atoi(stream.BitsPerRawSample),
+		Channels:      stream.Channels,
+		ChannelLayout: stream.ChannelLayout,
+		BitRate:       atoi(parsed.Format.BitRate),
+		StreamBitRate: atoi(stream.BitRate),
+	}
+	// PCM の WAV では bits_per_raw_sample が出力されないため、bits_per_sample を使用する
+	if source.BitDepth == 0 {
+		source.BitDepth = stream.BitsPerSample
+	}
+	if source.BitRate == 0 {
+		source.BitRate = source.StreamBitRate
+	}
+	if duration, err := parseDuration(parsed.Format.Duration); err == nil {
+		source.Duration = duration.Seconds()
+	}
+
+	// FLAC などストリーム側にタグを持つ形式もあるため、両方をまとめる（ファイル全体のタグを優先）
+	for _, tags := range []map[string]string{stream.Tags, parsed.Format.Tags} {
+		for name, value := range tags {
+			if source.Tags == nil {
+				source.Tags = make(map[string]string)
+			}
+			source.Tags[strings.ToLower(name)] = value
+		}
+	}
+	return source, nil
+}
+
+// atoi は ffprobe が出力する整数の文字列を変換します。"N/A" など数値でない場合は0を返します。
+func atoi(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return n
+}
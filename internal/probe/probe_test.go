@@ -0,0 +1,51 @@
+package probe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	got, err := parseDuration("123.456000\n")
+	if err != nil {
+		t.Fatalf("parseDuration でエラー: %v", err)
+	}
+	if want := 123456 * time.Millisecond; got != want {
+		t.Errorf("parseDuration: got %s, want %s", got, want)
+	}
+
+	for _, out := range []string{"", "N/A", "-1"} {
+		if _, err := parseDuration(out); err == nil {
+			t.Errorf("不正な出力 %q でエラーが発生すべき", out)
+		}
+	}
+}
+
+func TestParseInspect(t *testing.T) {
+	out := `{
+		"streams": [{"codec_name": "flac", "sample_rate": "96000", "bits_per_raw_sample": "24", "bits_per_sample": 0, "channels": 2, "channel_layout": "stereo", "bit_rate": "2800000", "tags": {"ARTIST": "ストリームの値", "GENRE": "ASMR"}}],
+		"format": {"duration": "3600.250000", "bit_rate": "3000000", "tags": {"ARTIST": "声優"}}
+	}`
+	got, err := parseInspect([]byte(out))
+	if err != nil {
+		t.Fatalf("parseInspect でエラー: %v", err)
+	}
+	if got.Codec != "flac" || got.SampleRate != 96000 || got.BitDepth != 24 || got.Channels != 2 || got.ChannelLayout != "stereo" || got.Duration != 3600.25 || got.BitRate != 3000000 || got.StreamBitRate != 2800000 {
+		t.Errorf("parseInspect: got %+v", got)
+	}
+	if got.Tags["artist"] != "声優" || got.Tags["genre"] != "ASMR" {
+		t.Errorf("タグはファイル全体の値を優先してまとめるべき: %v", got.Tags)
+	}
+
+	// PCM の WAV では bits_per_sample を使用し、非可逆圧縮では 0 のまま
+	got, err = parseInspect([]byte(`{"streams": [{"codec_name": "pcm_s16le", "sample_rate": "44100", "bits_per_sample": 16, "channels": 1}], "format": {"duration": "N/A"}}`))
+	if err != nil || got.BitDepth != 16 || got.Channels != 1 || got.Duration != 0 {
+		t.Errorf("WAVの解析結果: got %+v, %v", got, err)
+	}
+
+	for _, out := range []string{`{"streams": [], "format": {}}`, "not json"} {
+		if _, err := parseInspect([]byte(out)); err == nil {
+			t.Errorf("不正な出力 %q でエラーが発生すべき", out)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+const reportTemplate = `<!DOCTYPE html>
+<html lang="ja">
+<head>
+    <meta charset="UTF-8">
+    <title>dls-encoder 実行レポート {{.StartedAt.Format "2006-01-02 15:04:05"}}</title>
+    <style>
+        body { font-family: sans-serif; margin: 2em; color: #222; }
+        table { border-collapse: collapse; margin: 0.5em 0 1em; }
+        th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; vertical-align: top; }
+        th { background: #f4f4f4; }
+        .num { text-align: right; }
+        .status-converted, .status-encoded { color: #1a7f37; }
+        .status-up_to_date, .status-unchanged, .status-not_converted { color: #666; }
+        .status-failed { color: #cf222e; font-weight: bold; }
+        .status-not_started, .status-discarded { color: #9a6700; }
+        details { margin: 0.5em 0; border-bottom: 1px solid #eee; padding-bottom: 0.5em; }
+        summary { cursor: pointer; }
+        code { font-size: 0.9em; }
+    </style>
+</head>
+<body>
+    <h1>dls-encoder 実行レポート</h1>
+    <table>
+        <tr><th>バージョン</th><td>{{.Version}}</td></tr>
+        <tr><th>コマンド</th><td>{{.Command}}</td></tr>
+        <tr><th>開始</th><td>{{.StartedAt.Format "2006-01-02 15:04:05"}}</td></tr>
+        <tr><th>終了</th><td>{{.FinishedAt.Format "2006-01-02 15:04:05"}}</td></tr>
+        {{range $status, $count := .Summary}}
+        <tr><th class="status-{{$status}}">{{$status}}</th><td class="num">{{$count}}</td></tr>
+        {{end}}
+        {{if .Error}}<tr><th>エラー</th><td class="status-failed">{{.Error}}</td></tr>{{end}}
+    </table>
+
+    <h2>作品一覧</h2>
+    {{range .Works}}
+    <details{{if eq .Status "failed"}} open{{end}}>
+        <summary><span class="status-{{.Status}}">[{{.Status}}]</span> {{.Key}}{{with .Metadata}} {{.AlbumTitle}}{{end}}{{if .Reason}} ({{.Reason}}){{end}}</summary>
+        {{if .Detail}}<p class="status-failed">{{.Detail}}</p>{{end}}
+        {{with .Metadata}}
+        <table>
+            <tr><th>タイトル</th><td>{{.AlbumTitle}}</td></tr>
+            <tr><th>声優</th><td>{{.Actor}}</td></tr>
+            <tr><th>ブランド</th><td>{{.Brand}}</td></tr>
+            {{if .MainImage}}<tr><th>メイン画像</th><td><code>{{.MainImage}}</code></td></tr>{{end}}
+            {{range $name, $value := .Additional}}<tr><th>{{$name}}</th><td>{{$value}}</td></tr>{{end}}
+            {{range .Warnings}}<tr><th>注意</th><td class="status-failed">{{.}}</td></tr>{{end}}
+        </table>
+        {{end}}
+        {{if .OutputDir}}<p>出力先: <code>{{.OutputDir}}</code></p>{{end}}
+        {{if .Tracks}}
+        <table>
+            <tr><th>状態</th><th>変換元</th><th>出力</th><th>時間(秒)</th><th>入力(byte)</th><th>出力(byte)</th><th>エラー</th></tr>
+            {{range .Tracks}}
+            <tr>
+                <td class="status-{{.Status}}">{{.Status}}</td>
+                <td><code>{{.Input}}</code></td>
+                <td><code>{{.Output}}</code></td>
+                <td class="num">{{if .DurationSeconds}}{{printf "%.1f" .DurationSeconds}}{{end}}</td>
+                <td class="num">{{.InputBytes}}</td>
+                <td class="num">{{if .OutputBytes}}{{.OutputBytes}}{{end}}</td>
+                <td>{{.Error}}</td>
+            </tr>
+            {{end}}
+        </table>
+        {{end}}
+        {{if .SkippedSources}}
+        <table>
+            <tr><th>対象外の音声ファイル</th><th>理由</th></tr>
+            {{range .SkippedSources}}
+            <tr>
+                <td><code>{{.Path}}</code></td>
+                <td>{{.Reason}}{{if .ExcludeString}} ({{.ExcludeString}}){{end}}{{if .Variant}} ({{.Variant}} より {{.PreferredVariant}} を優先){{end}}{{if .PreferredPath}} → <code>{{.PreferredPath}}</code>{{end}}</td>
+            </tr>
+            {{end}}
+        </table>
+        {{end}}
+    </details>
+    {{end}}
+</body>
+</html>`
+
+// RenderHTML はレポートを外部ファイルに依存しない単一のHTMLとして出力します。
+func (r *Report) RenderHTML() ([]byte, error) {
+	tmpl, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("レポートのテンプレート解析に失敗しました: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r); err != nil {
+		return nil, fmt.Errorf("レポートのHTML生成に失敗しました: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveHTML はレポートをHTML形式で保存します。
+func (r *Report) SaveHTML(path string) error {
+	data, err := r.RenderHTML()
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+// Status は作品ごとの処理結果です。
+type Status string
+
+const (
+	StatusConverted    Status = "converted"     // 1トラック以上を変換した
+	StatusUpToDate     Status = "up_to_date"    // 前回から変更がなく変換を省略した
+	StatusNotConverted Status = "not_converted" // convert = false のため解析のみ行った
+	StatusFailed       Status = "failed"        // 処理に失敗した
+)
+
+// TrackStatus はトラックごとの処理結果です。
+type TrackStatus string
+
+const (
+	TrackEncoded    TrackStatus = "encoded"     // 変換した
+	TrackUnchanged  TrackStatus = "unchanged"   // 前回から変更がなく変換を省略した
+	TrackFailed     TrackStatus = "failed"      // 変換に失敗した
+	TrackNotStarted TrackStatus = "not_started" // 中断や同じ作品の失敗により変換しなかった
+	TrackDiscarded  TrackStatus = "discarded"   // 変換したが、同じ作品の失敗により出力に反映しなかった（次回の実行で再利用する）
+)
+
+// Report は1回の実行結果をまとめたレポートです。
+type Report struct {
+	Version    string         `json:"version"`     // dls-encoder のバージョン
+	Command    string         `json:"command"`     // 実行したサブコマンド
+	StartedAt  time.Time      `json:"started_at"`  // 開始時刻
+	FinishedAt time.Time      `json:"finished_at"` // 終了時刻
+	Summary    map[Status]int `json:"summary"`     // 処理結果ごとの作品数
+	Error      string         `json:"error,omitempty"`
+	Works      []Work         `json:"works"`
+}
+
+// Work は1作品分の処理結果です。
+type Work struct {
+	Key            string                `json:"key"`
+	Status         Status                `json:"status"`
+	Reason         string                `json:"reason,omitempty"` // 失敗理由の分類
+	Detail         string                `json:"detail,omitempty"` // 失敗の詳細
+	Metadata       *model.IndividualData `json:"metadata,omitempty"`
+	OutputDir      string                `json:"output_dir,omitempty"`
+	Tracks         []Track               `json:"tracks,omitempty"`
+	SkippedSources []model.SkippedFile   `json:"skipped_sources,omitempty"` // 変換対象から外した音声ファイルと理由
+}
+
+// Track は1トラック分の処理結果です。
+type Track struct {
+	Input           string      `json:"input"`
+	Output          string      `json:"output"`
+	Status          TrackStatus `json:"status"`
+	DurationSeconds float64     `json:"duration_seconds,omitempty"` // 変換に要した時間
+	InputBytes      int64       `json:"input_bytes"`
+	OutputBytes     int64       `json:"output_bytes,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// New は実行開始時点のレポートを作成します。
+func New(version, command string) *Report {
+	return &Report{Version: version, Command: command, StartedAt: time.Now()}
+}
+
+// Add は作品の処理結果を追加します。同じ作品キーが既にある場合は置き換えます。
+// nil の Report に対しては何もしません。
+func (r *Report) Add(work Work) {
+	if r == nil {
+		return
+	}
+	for i := range r.Works {
+		if r.Works[i].Key == work.Key {
+			r.Works[i] = work
+			return
+		}
+	}
+	r.Works = append(r.Works, work)
+}
+
+// Finish は終了時刻と作品数の集計を記録します。err は処理全体のエラーです。
+// 作品を追加した後に再度呼び出すと、集計をやり直します。
+func (r *Report) Finish(err error) {
+	r.FinishedAt = time.Now()
+	r.Error = ""
+	if err != nil {
+		r.Error = err.Error()
+	}
+	sort.Slice(r.Works, func(i, j int) bool { return r.Works[i].Key < r.Works[j].Key })
+	r.Summary = make(map[Status]int)
+	for _, work := range r.Works {
+		r.Summary[work.Status]++
+	}
+}
+
+// SaveJSON はレポートをJSON形式で保存します。
+func (r *Report) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("レポートのJSON変換に失敗しました: %w", err)
+	}
+	return writeFile(path, data)
+}
+
+// writeFile は一時ファイルに書き込んでから置き換えます。
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("ディレクトリの作成に失敗しました: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("レポートの書き込みに失敗しました: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("レポートの保存に失敗しました: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkryama/dls-encoder/internal/model"
+)
+
+func TestReportAddAndFinish(t *testing.T) {
+	r := New("test", "encode")
+	r.Add(Work{Key: "RJ02", Status: StatusFailed})
+	r.Add(Work{Key: "RJ01", Status: StatusConverted})
+	r.Add(Work{Key: "RJ02", Status: StatusUpToDate})
+	r.Finish(errors.New("中断"))
+
+	if len(r.Works) != 2 || r.Works[0].Key != "RJ01" || r.Works[1].Status != StatusUpToDate {
+		t.Errorf("作品一覧が正しくありません: %+v", r.Works)
+	}
+	if r.Summary[StatusConverted] != 1 || r.Summary[StatusUpToDate] != 1 || r.Summary[StatusFailed] != 0 {
+		t.Errorf("集計が正しくありません: %v", r.Summary)
+	}
+	if r.Error != "中断" {
+		t.Errorf("Error: got %q", r.Error)
+	}
+
+	r.Finish(nil)
+	if r.Error != "" {
+		t.Errorf("再集計時にエラーが残っています: %q", r.Error)
+	}
+
+	var nilReport *Report
+	nilReport.Add(Work{Key: "RJ03"}) // nil の場合は何もしない
+}
+
+func TestReportSave(t *testing.T) {
+	dir := t.TempDir()
+	r := New("test", "encode")
+	r.Add(Work{
+		Key:      "RJ01234567",
+		Status:   StatusFailed,
+		Reason:   "ffmpeg_failure",
+		Metadata: &model.IndividualData{AlbumTitle: "<script>alert(1)</script>"},
+		Tracks:   []Track{{Input: "/src/01.wav", Output: "/out/01.mp3", Status: TrackFailed, InputBytes: 10, Error: "exit status 1"}},
+	})
+	r.Finish(nil)
+
+	jsonPath := filepath.Join(dir, "report.json")
+	if err := r.SaveJSON(jsonPath); err != nil {
+		t.Fatalf("JSONの保存に失敗: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("JSONの読み込みに失敗: %v", err)
+	}
+	var loaded Report
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("JSONの解析に失敗: %v", err)
+	}
+	if len(loaded.Works) != 1 || loaded.Works[0].Tracks[0].Status != TrackFailed || loaded.Summary[StatusFailed] != 1 {
+		t.Errorf("保存したJSONの内容が正しくありません: %s", data)
+	}
+
+	htmlPath := filepath.Join(dir, "report.html")
+	if err := r.SaveHTML(htmlPath); err != nil {
+		t.Fatalf("HTMLの保存に失敗: %v", err)
+	}
+	html, err := os.ReadFile(htmlPath)
+	if err != nil {
+		t.Fatalf("HTMLの読み込みに失敗: %v", err)
+	}
+	for _, want := range []string{"RJ01234567", "ffmpeg_failure", "/src/01.wav", "exit status 1", "&lt;script&gt;"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("HTMLに %q が含まれていません", want)
+		}
+	}
+	if strings.Contains(string(html), "<script>") {
+		t.Error("メタデータがエスケープされていません")
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// TargetSelector は LoadTargets で取得した作品キーから処理対象を絞り込む条件です。
+// Only と Match がどちらも空の場合はすべての作品を対象とし、その後 Exclude に一致する作品を除外します。
+type TargetSelector struct {
+	Only    []string // 対象とする作品キー（完全一致）
+	Match   []string // 対象とする作品キーのglobパターン（例: RJ01*）
+	Exclude []string // 除外する作品キー（完全一致またはglobパターン）
+}
+
+// Empty は絞り込み条件が指定されていないかどうかを返します。
+func (s TargetSelector) Empty() bool {
+	return len(s.Only) == 0 && len(s.Match) == 0 && len(s.Exclude) == 0
+}
+
+// Validate はglobパターンの構文を検証します。
+func (s TargetSelector) Validate() error {
+	for _, pattern := range append(append([]string{}, s.Match...), s.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("不正なパターンです(%s): %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Select は条件に一致する作品キーを元の順序のまま返します。
+// Only に指定されたが targets に存在しないキーは missing として返します。
+func (s TargetSelector) Select(targets []string) (selected []string, missing []string) {
+	available := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		available[target] = true
+	}
+	only := make(map[string]bool, len(s.Only))
+	for _, key := range s.Only {
+		if only[key] {
+			continue
+		}
+		only[key] = true
+		if !available[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	for _, target := range targets {
+		if len(s.Only) > 0 || len(s.Match) > 0 {
+			if !only[target] && !matchAny(s.Match, target) {
+				continue
+			}
+		}
+		if matchAny(s.Exclude, target) {
+			continue
+		}
+		selected = append(selected, target)
+	}
+	return selected, missing
+}
+
+// matchAny は key がいずれかのパターンに一致するかどうかを返します。
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadKeyList は1行に1つの作品キーを記述したリストを読み込みます。
+// 空行と # で始まる行は無視します。
+func ReadKeyList(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("作品キー一覧の読み込みに失敗しました: %w", err)
+	}
+	return keys, nil
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/kkryama/dls-encoder/internal/model"
@@ -164,3 +166,52 @@ func TestSaveJSON(t *testing.T) {
 		t.Errorf("Additional[ジャンル]: got %q, want %q", genre, expected.Additional["ジャンル"])
 	}
 }
+
+func TestTargetSelectorSelect(t *testing.T) {
+	targets := []string{"RJ01000001", "RJ01000002", "RJ02000001", "d_123456"}
+
+	tests := []struct {
+		name        string
+		selector    TargetSelector
+		wantTargets []string
+		wantMissing []string
+	}{
+		{"条件なし", TargetSelector{}, targets, nil},
+		{"キー指定", TargetSelector{Only: []string{"d_123456", "RJ09999999"}}, []string{"d_123456"}, []string{"RJ09999999"}},
+		{"globパターン", TargetSelector{Match: []string{"RJ01*"}}, []string{"RJ01000001", "RJ01000002"}, nil},
+		{"キーとパターンの併用", TargetSelector{Only: []string{"d_123456"}, Match: []string{"RJ02*"}}, []string{"RJ02000001", "d_123456"}, nil},
+		{"除外のみ", TargetSelector{Exclude: []string{"RJ01*"}}, []string{"RJ02000001", "d_123456"}, nil},
+		{"パターンと除外", TargetSelector{Match: []string{"RJ*"}, Exclude: []string{"RJ01000002"}}, []string{"RJ01000001", "RJ02000001"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := tt.selector.Select(targets)
+			if !reflect.DeepEqual(got, tt.wantTargets) {
+				t.Errorf("対象: got %v, want %v", got, tt.wantTargets)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("存在しないキー: got %v, want %v", missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestTargetSelectorValidate(t *testing.T) {
+	if err := (TargetSelector{Match: []string{"RJ[01"}}).Validate(); err == nil {
+		t.Error("不正なglobパターンはエラーになるべき")
+	}
+	if err := (TargetSelector{Match: []string{"RJ0[12]*"}}).Validate(); err != nil {
+		t.Errorf("正しいglobパターンでエラーが発生: %v", err)
+	}
+}
+
+func TestReadKeyList(t *testing.T) {
+	keys, err := ReadKeyList(strings.NewReader("RJ01234567\n# コメント\n\n  d_123456  \n"))
+	if err != nil {
+		t.Fatalf("ReadKeyListの実行に失敗: %v", err)
+	}
+	if want := []string{"RJ01234567", "d_123456"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("作品キー: got %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,242 @@
+// Package tracklist は作品ページのトラックリストと変換元の音声ファイルを対応付けます。
+package tracklist
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 対応付けの方法
+const (
+	MethodOrder    = "order"    // トラック数が同じで再生時間が矛盾しないため、並び順で対応付けた
+	MethodDuration = "duration" // 再生時間が一致した
+	MethodTitle    = "title"    // ファイル名とタイトルが似ていた
+)
+
+// タイトルだけで対応付ける場合に必要な類似度
+const (
+	minSimilarity    = 0.5 // 再生時間が分からない場合
+	strongSimilarity = 0.8 // 再生時間が一致しない場合
+)
+
+// File は対応付ける音声ファイルです。
+type File struct {
+	Name     string  // 拡張子を除いたファイル名
+	Duration float64 // 再生時間（秒、不明な場合は0）
+}
+
+// Entry はトラックリストの1トラックです。
+type Entry struct {
+	Title    string  // タイトル
+	Duration float64 // 再生時間（秒、不明な場合は0）
+}
+
+// Pair は音声ファイルに対応付けたトラックです。
+type Pair struct {
+	Entry  int    // トラックリストでの位置（0始まり、対応するトラックがない場合は -1）
+	Method string // 対応付けの方法
+}
+
+// Result は対応付けの結果です。
+type Result struct {
+	Pairs  []Pair   // 音声ファイルごとの対応付け（File と同じ順序）
+	Issues []string // 対応付けできなかったファイルやトラック、曖昧な対応付けについての注意
+}
+
+// Match は音声ファイルとトラックリストを、再生時間・並び順・タイトルの類似度から対応付けます。
+// files は FindAudioFiles が返した順序（トラック番号の順）で渡します。
+//
+// トラック数が同じで、どのファイルも並び順で対応するトラックと再生時間が矛盾しない場合は並び順で対応付けます。
+// それ以外の場合は、再生時間が一致する組とタイトルが似ている組を、評価の高い順に1対1で対応付けます。
+func Match(files []File, entries []Entry) Result {
+	result := Result{Pairs: make([]Pair, len(files))}
+	for i := range result.Pairs {
+		result.Pairs[i].Entry = -1
+	}
+	if len(files) == 0 || len(entries) == 0 {
+		return result
+	}
+
+	if len(files) == len(entries) && orderConsistent(files, entries) {
+		for i := range files {
+			result.Pairs[i] = Pair{Entry: i, Method: MethodOrder}
+		}
+		return result
+	}
+	if len(files) != len(entries) {
+		result.Issues = append(result.Issues, fmt.Sprintf("トラックリストは %d トラックですが、音声ファイルは %d ファイルです", len(entries), len(files)))
+	}
+
+	type candidate struct {
+		file, entry int
+		score       float64
+		method      string
+	}
+	var candidates []candidate
+	titles := make([]string, len(entries))
+	for j, entry := range entries {
+		titles[j] = normalize(entry.Title)
+	}
+	for i, file := range files {
+		name := normalize(file.Name)
+		for j, entry := range entries {
+			sim := similarity(name, titles[j])
+			// 並び順の近さ（相対的な位置の差）は、同じ評価の組の中から選ぶためにのみ使用する
+			proximity := 1 - math.Abs(float64(i)/float64(len(files))-float64(j)/float64(len(entries)))
+			switch known, ok := durationMatch(file.Duration, entry.Duration); {
+			case known && ok:
+				candidates = append(candidates, candidate{i, j, 1 + sim + 0.1*proximity, MethodDuration})
+			case !known && sim >= minSimilarity, known && sim >= strongSimilarity:
+				// 再生時間が一致しなくても、タイトルがほぼ同じ場合は対応付ける（再生時間の表記の誤りに備える）
+				candidates = append(candidates, candidate{i, j, sim + 0.1*proximity, MethodTitle})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	used := make([]bool, len(entries))
+	for _, c := range candidates {
+		if result.Pairs[c.file].Entry >= 0 || used[c.entry] {
+			continue
+		}
+		result.Pairs[c.file] = Pair{Entry: c.entry, Method: c.method}
+		used[c.entry] = true
+
+		if c.method == MethodDuration && similarity(normalize(files[c.file].Name), titles[c.entry]) < minSimilarity {
+			var same []string
+			for j, entry := range entries {
+				if _, ok := durationMatch(files[c.file].Duration, entry.Duration); ok && j != c.entry {
+					same = append(same, entry.Title)
+				}
+			}
+			if len(same) > 0 {
+				result.Issues = append(result.Issues, fmt.Sprintf("%s と再生時間が一致するトラックが複数あるため、並び順の近い「%s」を選びました（ほかの候補: %s）",
+					files[c.file].Name, entries[c.entry].Title, strings.Join(same, "、")))
+			}
+		}
+	}
+
+	var unmatchedFiles, unmatchedEntries []string
+	for i, pair := range result.Pairs {
+		if pair.Entry < 0 {
+			unmatchedFiles = append(unmatchedFiles, files[i].Name)
+		}
+	}
+	for j, entry := range entries {
+		if !used[j] {
+			unmatchedEntries = append(unmatchedEntries, entry.Title)
+		}
+	}
+	if len(unmatchedFiles) > 0 {
+		result.Issues = append(result.Issues, "トラックリストと対応付けられない音声ファイル: "+strings.Join(unmatchedFiles, "、"))
+	}
+	if len(unmatchedEntries) > 0 {
+		result.Issues = append(result.Issues, "対応する音声ファイルがないトラック: "+strings.Join(unmatchedEntries, "、"))
+	}
+	return result
+}
+
+// orderConsistent は並び順で対応付けた場合に、再生時間が分かっているすべての組で再生時間が一致するかどうかを返します。
+func orderConsistent(files []File, entries []Entry) bool {
+	for i := range files {
+		if known, ok := durationMatch(files[i].Duration, entries[i].Duration); known && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// durationMatch は両方の再生時間が分かっているかどうかと、分かっている場合に一致するとみなせるかどうかを返します。
+// トラックリストの再生時間は秒単位に丸められているため、3秒または1%の差までは一致とみなします。
+func durationMatch(file, entry float64) (known, ok bool) {
+	if file <= 0 || entry <= 0 {
+		return false, false
+	}
+	tolerance := math.Max(3, entry*0.01)
+	return true, math.Abs(file-entry) <= tolerance
+}
+
+// trackPrefix はファイル名の先頭のトラック番号（"01_"、"tr02 "、"トラック3："など）です。
+var trackPrefix = regexp.MustCompile(`^(?:track|tr|トラック|#)?\s*\d+\s*[-_.:：、。)）\]]*\s*`)
+
+// normalize は比較のために文字列を正規化します。
+// 全角英数字を半角に、英字を小文字にし、先頭のトラック番号と空白・記号を取り除きます。
+func normalize(s string) string {
+	s = strings.ToLower(norm.NFKC.String(s))
+	if stripped := trackPrefix.ReplaceAllString(s, ""); stripped != "" {
+		s = stripped
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// similarity は正規化した2つの文字列の類似度（0〜1）を返します。
+// 一方がもう一方を含む場合は0.8以上、それ以外は2文字ずつの組（バイグラム）の Dice 係数です。
+func similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	dice := diceCoefficient([]rune(a), []rune(b))
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return math.Max(0.8, dice)
+	}
+	return dice
+}
+
+func diceCoefficient(a, b []rune) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	bigrams := make(map[[2]rune]int)
+	for i := 0; i+1 < len(a); i++ {
+		bigrams[[2]rune{a[i], a[i+1]}]++
+	}
+	common := 0
+	for i := 0; i+1 < len(b); i++ {
+		bigram := [2]rune{b[i], b[i+1]}
+		if bigrams[bigram] > 0 {
+			bigrams[bigram]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)-1+len(b)-1)
+}
+
+// 作品データの再生時間の形式（"4分30秒"、"1時間2分3秒"、"4:30"、"1:02:03"）
+var (
+	japaneseDuration = regexp.MustCompile(`^(?:(\d+)時間)?(?:(\d+)分)?(?:(\d+)秒)?$`)
+	clockDuration    = regexp.MustCompile(`^(?:(\d+):)?(\d+):(\d+)$`)
+)
+
+// ParseDuration は作品データのトラックの再生時間を秒で返します。解析できない場合は0を返します。
+func ParseDuration(s string) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	match := japaneseDuration.FindStringSubmatch(s)
+	if match == nil {
+		match = clockDuration.FindStringSubmatch(s)
+	}
+	if match == nil {
+		return 0
+	}
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.Atoi(match[3])
+	return float64(hours*3600 + minutes*60 + seconds)
+}
@@ -0,0 +1,123 @@
+package tracklist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func entries(titles ...string) []Entry {
+	result := make([]Entry, len(titles))
+	for i, title := range titles {
+		result[i] = Entry{Title: title}
+	}
+	return result
+}
+
+func entriesOf(result Result) []int {
+	indexes := make([]int, len(result.Pairs))
+	for i, pair := range result.Pairs {
+		indexes[i] = pair.Entry
+	}
+	return indexes
+}
+
+func TestMatchByOrder(t *testing.T) {
+	files := []File{{Name: "01", Duration: 330}, {Name: "02", Duration: 600}}
+	list := []Entry{{Title: "プロローグ", Duration: 331}, {Title: "添い寝", Duration: 598}}
+	result := Match(files, list)
+	want := []Pair{{Entry: 0, Method: MethodOrder}, {Entry: 1, Method: MethodOrder}}
+	if !reflect.DeepEqual(result.Pairs, want) || len(result.Issues) != 0 {
+		t.Errorf("並び順での対応付け: got %+v, issues %v", result.Pairs, result.Issues)
+	}
+
+	// 再生時間が分からなくてもトラック数が同じなら並び順で対応付ける
+	result = Match([]File{{Name: "a"}, {Name: "b"}}, entries("プロローグ", "添い寝"))
+	if got := entriesOf(result); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("再生時間がない場合の並び順での対応付け: got %v", got)
+	}
+}
+
+func TestMatchByDuration(t *testing.T) {
+	// おまけのファイルがトラック一覧にない場合は、再生時間で対応付ける
+	files := []File{
+		{Name: "01", Duration: 330.4},
+		{Name: "02", Duration: 600.2},
+		{Name: "おまけ", Duration: 120},
+		{Name: "03", Duration: 905},
+	}
+	list := []Entry{
+		{Title: "プロローグ", Duration: 330},
+		{Title: "添い寝", Duration: 600},
+		{Title: "エピローグ", Duration: 906},
+	}
+	result := Match(files, list)
+	if got := entriesOf(result); !reflect.DeepEqual(got, []int{0, 1, -1, 2}) {
+		t.Errorf("再生時間での対応付け: got %v", got)
+	}
+	if result.Pairs[3].Method != MethodDuration {
+		t.Errorf("対応付けの方法: got %q, want %q", result.Pairs[3].Method, MethodDuration)
+	}
+	issues := strings.Join(result.Issues, "\n")
+	if !strings.Contains(issues, "3 トラックですが、音声ファイルは 4 ファイル") || !strings.Contains(issues, "対応付けられない音声ファイル: おまけ") {
+		t.Errorf("トラック数の違いと対応しないファイルを報告すべき: %v", result.Issues)
+	}
+}
+
+func TestMatchByTitle(t *testing.T) {
+	// ファイルの並び順がトラック一覧と異なっても、タイトルが似ていれば対応付ける
+	files := []File{{Name: "01_ぷろろーぐ"}, {Name: "track02 添い寝と耳かき"}, {Name: "ＥＸ　Ｆｒｅｅ　Ｔａｌｋ"}}
+	list := entries("添い寝と耳かき", "ぷろろーぐ")
+	result := Match(files, list)
+	if got := entriesOf(result); !reflect.DeepEqual(got, []int{1, 0, -1}) {
+		t.Errorf("タイトルでの対応付け: got %v", got)
+	}
+	if result.Pairs[0].Method != MethodTitle {
+		t.Errorf("対応付けの方法: got %q, want %q", result.Pairs[0].Method, MethodTitle)
+	}
+}
+
+func TestMatchAmbiguousDuration(t *testing.T) {
+	files := []File{{Name: "a", Duration: 60}, {Name: "b", Duration: 300}, {Name: "c", Duration: 61}}
+	list := []Entry{{Title: "ジングル1", Duration: 60}, {Title: "ジングル2", Duration: 60}}
+	result := Match(files, list)
+	if got := entriesOf(result); !reflect.DeepEqual(got, []int{0, -1, 1}) {
+		t.Errorf("同じ再生時間のトラックは並び順の近いものを選ぶ: got %v", got)
+	}
+	if !strings.Contains(strings.Join(result.Issues, "\n"), "再生時間が一致するトラックが複数") {
+		t.Errorf("曖昧な対応付けを報告すべき: %v", result.Issues)
+	}
+}
+
+func TestMatchMissingFiles(t *testing.T) {
+	files := []File{{Name: "01", Duration: 100}}
+	list := []Entry{{Title: "本編", Duration: 100}, {Title: "おまけ", Duration: 50}}
+	result := Match(files, list)
+	if got := entriesOf(result); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("対応付け: got %v", got)
+	}
+	if !strings.Contains(strings.Join(result.Issues, "\n"), "対応する音声ファイルがないトラック: おまけ") {
+		t.Errorf("対応するファイルがないトラックを報告すべき: %v", result.Issues)
+	}
+
+	if result := Match(files, nil); result.Pairs[0].Entry != -1 || len(result.Issues) != 0 {
+		t.Errorf("トラック一覧がない場合は対応付けない: %+v", result)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	testCases := map[string]float64{
+		"4分30秒":    270,
+		"62分3秒":    3723,
+		"1時間2分3秒":  3723,
+		"4:30":     270,
+		"01:02:03": 3723,
+		"":         0,
+		"約5分":      0,
+	}
+	for input, want := range testCases {
+		if got := ParseDuration(input); got != want {
+			t.Errorf("ParseDuration(%q): got %v, want %v", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package watcher
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Queue は変更のあった作品キーを、書き込みが落ち着くまで保持するキューです。
+type Queue struct {
+	mu    sync.Mutex
+	items map[string]*queueItem
+}
+
+type queueItem struct {
+	lastEvent time.Time // 最後に変更を検知した時刻
+	blocked   bool      // 落ち着いた後の確認で処理条件を満たしていなかったかどうか
+}
+
+// NewQueue は空のキューを作成します。
+func NewQueue() *Queue {
+	return &Queue{items: make(map[string]*queueItem)}
+}
+
+// Touch は作品キーの変更を記録します。キューにない場合は追加します。
+func (q *Queue) Touch(key string, at time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[key]
+	if !ok {
+		item = &queueItem{}
+		q.items[key] = item
+	}
+	item.lastEvent = at
+	item.blocked = false
+}
+
+// Take は最後の変更から settle 以上経過した作品キーを取り出します。
+// ready が false を返した作品キーはキューに残し、次に変更を検知するまで再確認しません。
+// blocked には今回新たに処理条件を満たさなかった作品キーを返します。
+func (q *Queue) Take(now time.Time, settle time.Duration, ready func(key string) bool) (taken []string, blocked []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, item := range q.items {
+		if item.blocked || now.Sub(item.lastEvent) < settle {
+			continue
+		}
+		if !ready(key) {
+			item.blocked = true
+			blocked = append(blocked, key)
+			continue
+		}
+		taken = append(taken, key)
+		delete(q.items, key)
+	}
+	sort.Strings(taken)
+	sort.Strings(blocked)
+	return taken, blocked
+}
+
+// Pending はキューに残っている作品キーを返します。
+func (q *Queue) Pending() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keys := make([]string, 0, len(q.items))
+	for key := range q.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Roots は監視対象のディレクトリです。
+type Roots struct {
+	SourceDir string // 作品ごとの音声ディレクトリを配置するディレクトリ（サブディレクトリも監視）
+	HtmlDir   string // <作品キー>.html を配置するディレクトリ
+	ImageDir  string // <作品キー>.webp / <作品キー>.jpg を配置するディレクトリ
+}
+
+// KeyForPath は変更のあったパスに対応する作品キーを返します。
+// 作品キーと関係のないパスの場合は false を返します。
+func (r Roots) KeyForPath(path string) (string, bool) {
+	if rel, ok := relativeTo(r.SourceDir, path); ok {
+		key := strings.Split(rel, string(filepath.Separator))[0]
+		if isTemporary(key) {
+			return "", false
+		}
+		return key, true
+	}
+	if rel, ok := relativeTo(r.HtmlDir, path); ok && !strings.ContainsRune(rel, filepath.Separator) {
+		if strings.EqualFold(filepath.Ext(rel), ".html") {
+			return strings.TrimSuffix(rel, filepath.Ext(rel)), true
+		}
+	}
+	if rel, ok := relativeTo(r.ImageDir, path); ok && !strings.ContainsRune(rel, filepath.Separator) {
+		switch strings.ToLower(filepath.Ext(rel)) {
+		case ".webp", ".jpg":
+			return strings.TrimSuffix(rel, filepath.Ext(rel)), true
+		}
+	}
+	return "", false
+}
+
+// relativeTo は path が root 配下の場合に root からの相対パスを返します。
+func relativeTo(root, path string) (string, bool) {
+	if root == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// isTemporary はダウンロード途中のファイルなど、作品として扱わない名前かどうかを返します。
+func isTemporary(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".crdownload")
+}
+
+// Watcher は Roots 配下の変更を監視し、対応する作品キーを通知します。
+type Watcher struct {
+	roots   Roots
+	watcher *fsnotify.Watcher
+}
+
+// New は Roots の各ディレクトリの監視を開始した Watcher を作成します。
+func New(roots Roots) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("ファイル監視の初期化に失敗しました: %w", err)
+	}
+	w := &Watcher{roots: roots, watcher: fw}
+
+	if _, err := w.addRecursive(roots.SourceDir); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	for _, dir := range []string{roots.HtmlDir, roots.ImageDir} {
+		if dir == "" {
+			continue
+		}
+		if err := fw.Add(dir); err != nil {
+			fw.Close()
+			return nil, fmt.Errorf("ディレクトリ(%s)の監視に失敗しました: %w", dir, err)
+		}
+	}
+	return w, nil
+}
+
+// addRecursive は dir とそのサブディレクトリをすべて監視対象に追加し、見つかったファイルのパスを返します。
+func (w *Watcher) addRecursive(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 監視の追加中に削除されたディレクトリは無視する
+			if os.IsNotExist(err) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+			return nil
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("ディレクトリ(%s)の監視に失敗しました: %w", path, err)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Run は ctx がキャンセルされるまで変更を監視し、作品キーごとに onChange を呼び出します。
+// 監視中のエラーは onError に渡し、監視は継続します。
+func (w *Watcher) Run(ctx context.Context, onChange func(key string), onError func(err error)) error {
+	defer w.watcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			// source_dir 配下に作成されたディレクトリは、その中の書き込みも検知できるよう監視に追加する
+			if event.Has(fsnotify.Create) {
+				if _, ok := relativeTo(w.roots.SourceDir, event.Name); ok {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						files, err := w.addRecursive(event.Name)
+						if err != nil {
+							onError(err)
+						}
+						// 監視を追加する前にサブディレクトリへ書き込まれたファイルは通知されないため、見つかったファイルも変更として扱う
+						w.notifyFiles(files, onChange)
+					}
+				}
+			}
+			if key, ok := w.roots.KeyForPath(event.Name); ok {
+				onChange(key)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			onError(fmt.Errorf("ファイル監視でエラーが発生しました: %w", err))
+		}
+	}
+}
+
+// notifyFiles はファイルに対応する作品キーごとに一度ずつ onChange を呼び出します。
+func (w *Watcher) notifyFiles(files []string, onChange func(key string)) {
+	seen := make(map[string]bool)
+	for _, path := range files {
+		key, ok := w.roots.KeyForPath(path)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		onChange(key)
+	}
+}
@@ -0,0 +1,199 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueueTake(t *testing.T) {
+	q := NewQueue()
+	start := time.Date(2025, 7, 12, 0, 0, 0, 0, time.UTC)
+	settle := 10 * time.Second
+
+	q.Touch("RJ01234567", start)
+	q.Touch("d_123456", start.Add(5*time.Second))
+
+	ready := map[string]bool{"RJ01234567": true}
+	readyFunc := func(key string) bool { return ready[key] }
+
+	taken, blocked := q.Take(start.Add(9*time.Second), settle, readyFunc)
+	if len(taken) != 0 || len(blocked) != 0 {
+		t.Fatalf("待機時間内の作品が取り出されました: taken=%v, blocked=%v", taken, blocked)
+	}
+
+	taken, blocked = q.Take(start.Add(15*time.Second), settle, readyFunc)
+	if !reflect.DeepEqual(taken, []string{"RJ01234567"}) || !reflect.DeepEqual(blocked, []string{"d_123456"}) {
+		t.Fatalf("取り出し結果: taken=%v, blocked=%v", taken, blocked)
+	}
+	if got := q.Pending(); !reflect.DeepEqual(got, []string{"d_123456"}) {
+		t.Errorf("条件を満たさない作品はキューに残るべき: got %v", got)
+	}
+
+	// 条件を満たさなかった作品は、次の変更を検知するまで再確認しない
+	ready["d_123456"] = true
+	if taken, blocked = q.Take(start.Add(30*time.Second), settle, readyFunc); len(taken) != 0 || len(blocked) != 0 {
+		t.Fatalf("変更のない作品が再確認されました: taken=%v, blocked=%v", taken, blocked)
+	}
+
+	q.Touch("d_123456", start.Add(40*time.Second))
+	taken, _ = q.Take(start.Add(50*time.Second), settle, readyFunc)
+	if !reflect.DeepEqual(taken, []string{"d_123456"}) {
+		t.Errorf("HTMLの追加後は処理されるべき: got %v", taken)
+	}
+	if got := q.Pending(); len(got) != 0 {
+		t.Errorf("キューが空になっていません: %v", got)
+	}
+}
+
+func TestRootsKeyForPath(t *testing.T) {
+	roots := Roots{SourceDir: "/data/source", HtmlDir: "/data/html", ImageDir: "/data/image"}
+
+	tests := []struct {
+		path    string
+		wantKey string
+		wantOK  bool
+	}{
+		{"/data/source/RJ01234567", "RJ01234567", true},
+		{"/data/source/RJ01234567/本編/01.wav", "RJ01234567", true},
+		{"/data/source", "", false},
+		{"/data/source/.RJ01234567.tmp", "", false},
+		{"/data/html/RJ01234567.html", "RJ01234567", true},
+		{"/data/html/RJ01234567.txt", "", false},
+		{"/data/image/d_123456.webp", "d_123456", true},
+		{"/data/image/d_123456.jpg", "d_123456", true},
+		{"/data/image/d_123456.png", "", false},
+		{"/data/other/RJ01234567", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			key, ok := roots.KeyForPath(filepath.FromSlash(tt.path))
+			if key != tt.wantKey || ok != tt.wantOK {
+				t.Errorf("KeyForPath(%q): got (%q, %v), want (%q, %v)", tt.path, key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	roots := Roots{
+		SourceDir: filepath.Join(tmpDir, "source"),
+		HtmlDir:   filepath.Join(tmpDir, "html"),
+		ImageDir:  filepath.Join(tmpDir, "image"),
+	}
+	for _, dir := range []string{roots.SourceDir, roots.HtmlDir, roots.ImageDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("ディレクトリの作成に失敗: %v", err)
+		}
+	}
+
+	w, err := New(roots)
+	if err != nil {
+		t.Fatalf("監視の開始に失敗: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keys := make(chan string, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx, func(key string) { keys <- key }, func(err error) { t.Errorf("監視エラー: %v", err) })
+	}()
+
+	waitKey := func(want string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case key := <-keys:
+				if key == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("作品キー %q の変更が通知されませんでした", want)
+			}
+		}
+	}
+
+	workDir := filepath.Join(roots.SourceDir, "RJ01234567", "本編")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("作品ディレクトリの作成に失敗: %v", err)
+	}
+	waitKey("RJ01234567")
+
+	// 作成されたサブディレクトリ内の書き込みも検知する
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(workDir, "01.wav"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("音声ファイルの作成に失敗: %v", err)
+	}
+	waitKey("RJ01234567")
+
+	if err := os.WriteFile(filepath.Join(roots.HtmlDir, "d_123456.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("HTMLファイルの作成に失敗: %v", err)
+	}
+	waitKey("d_123456")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("監視が終了しませんでした")
+	}
+}
+
+func TestWatcherRunNotifiesFilesInNewTree(t *testing.T) {
+	tmpDir := t.TempDir()
+	roots := Roots{SourceDir: filepath.Join(tmpDir, "source")}
+	if err := os.MkdirAll(roots.SourceDir, 0755); err != nil {
+		t.Fatalf("ディレクトリの作成に失敗: %v", err)
+	}
+
+	w, err := New(roots)
+	if err != nil {
+		t.Fatalf("監視の開始に失敗: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keys := make(chan string, 16)
+	go w.Run(ctx, func(key string) { keys <- key }, func(err error) { t.Errorf("監視エラー: %v", err) })
+
+	waitKey := func(want string) {
+		t.Helper()
+		select {
+		case key := <-keys:
+			if key != want {
+				t.Fatalf("作品キー: got %q, want %q", key, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("作品キー %q の変更が通知されませんでした", want)
+		}
+	}
+
+	// 監視の外で作成した作品ディレクトリを1回の操作で配置する。サブディレクトリのファイルの作成は通知されない
+	staged := filepath.Join(tmpDir, "staged")
+	if err := os.MkdirAll(filepath.Join(staged, "本編", "SE有"), 0755); err != nil {
+		t.Fatalf("作品ディレクトリの作成に失敗: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(staged, "本編", "SE有", "01.wav"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("音声ファイルの作成に失敗: %v", err)
+	}
+	if err := os.Rename(staged, filepath.Join(roots.SourceDir, "RJ01234567")); err != nil {
+		t.Fatalf("作品ディレクトリの配置に失敗: %v", err)
+	}
+
+	// ディレクトリの作成と、監視を追加した時点で見つかったファイルの両方を通知する
+	waitKey("RJ01234567")
+	waitKey("RJ01234567")
+
+	// 配置されたサブディレクトリの書き込みも検知する
+	if err := os.WriteFile(filepath.Join(roots.SourceDir, "RJ01234567", "本編", "SE有", "02.wav"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("音声ファイルの作成に失敗: %v", err)
+	}
+	waitKey("RJ01234567")
+}
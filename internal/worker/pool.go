@@ -0,0 +1,48 @@
+// Package worker は処理を並列実行するためのワーカープールを提供します。
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Job はワーカープールで実行する1つの処理です。
+type Job func(ctx context.Context) error
+
+// Run は jobs を最大 workers 個のゴルーチンで並列に実行します。
+// ジョブは渡された順に着手され、ctx がキャンセルされた後は未着手のジョブを実行せずに ctx.Err() を結果とします。
+// 戻り値は jobs と同じ順序で各ジョブの実行結果を格納したスライスです。
+func Run(ctx context.Context, workers int, jobs []Job) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	errs := make([]error, len(jobs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = jobs[i](ctx)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunExecutesAllJobs(t *testing.T) {
+	var count int32
+	jobs := make([]Job, 10)
+	for i := range jobs {
+		jobs[i] = func(ctx context.Context) error {
+			atomic.AddInt32(&count, 1)
+			return nil
+		}
+	}
+
+	errs := Run(context.Background(), 3, jobs)
+
+	if len(errs) != len(jobs) {
+		t.Fatalf("結果の件数が一致しません: got %d, want %d", len(errs), len(jobs))
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("ジョブ%dが想定外のエラーを返しました: %v", i, err)
+		}
+	}
+	if count != int32(len(jobs)) {
+		t.Errorf("実行されたジョブ数が一致しません: got %d, want %d", count, len(jobs))
+	}
+}
+
+func TestRunLimitsConcurrency(t *testing.T) {
+	const workers = 2
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+	)
+	jobs := make([]Job, 8)
+	for i := range jobs {
+		jobs[i] = func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	Run(context.Background(), workers, jobs)
+
+	if peak > workers {
+		t.Errorf("同時実行数が上限を超えました: got %d, want <= %d", peak, workers)
+	}
+}
+
+func TestRunReturnsErrorsInJobOrder(t *testing.T) {
+	errFailed := errors.New("failed")
+	jobs := []Job{
+		func(ctx context.Context) error { return nil },
+		func(ctx context.Context) error { return errFailed },
+		func(ctx context.Context) error { return nil },
+	}
+
+	errs := Run(context.Background(), 2, jobs)
+
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("成功したジョブにエラーが設定されています: %v", errs)
+	}
+	if !errors.Is(errs[1], errFailed) {
+		t.Errorf("失敗したジョブのエラーが一致しません: got %v, want %v", errs[1], errFailed)
+	}
+}
+
+func TestRunSkipsJobsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var executed int32
+	jobs := make([]Job, 5)
+	for i := range jobs {
+		jobs[i] = func(ctx context.Context) error {
+			atomic.AddInt32(&executed, 1)
+			cancel()
+			return nil
+		}
+	}
+
+	errs := Run(ctx, 1, jobs)
+
+	if executed != 1 {
+		t.Errorf("キャンセル後もジョブが実行されました: got %d, want 1", executed)
+	}
+	for i := 1; i < len(errs); i++ {
+		if !errors.Is(errs[i], context.Canceled) {
+			t.Errorf("未着手のジョブ%dの結果がキャンセルではありません: %v", i, errs[i])
+		}
+	}
+}